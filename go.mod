@@ -1 +1,3 @@
 module github.com/apparentlymart/go-onig
+
+go 1.21
@@ -1,3 +1,4 @@
+#include <stdlib.h>
 #include <bindings.h>
 
 int goonig_error_code_to_str(
@@ -14,6 +15,9 @@ int goonig_init_regex(
     OnigSyntaxType *syntax,
     OnigErrorInfo *err_info)
 {
+    if (pattern == NULL) {
+        pattern = "";
+    }
     return onig_new_without_alloc(
         reg,
         pattern,
@@ -33,25 +37,122 @@ int goonig_regex_match(
     regex_t *reg,
     const char *str,
     int str_len,
+    int at,
     OnigRegion *region,
     OnigOptionType option)
 {
-    return onig_match(reg, str, str + str_len, str, region, option);
+    if (str == NULL) {
+        str = "";
+    }
+    return onig_match(reg, str, str + str_len, str + at, region, option);
 }
 
 int goonig_regex_search(
     regex_t *reg,
     const char *str,
     int str_len,
+    int start,
     int rev,
     OnigRegion *region,
     OnigOptionType option)
 {
+    if (str == NULL) {
+        str = "";
+    }
     if (rev) {
-        return onig_search(reg, str, str, str + str_len, str, region, option);
+        return onig_search(
+            reg, str, str + str_len, str + str_len, str + start, region,
+            option);
     }
     return onig_search(
-        reg, str, str + str_len, str, str + str_len, region, option);
+        reg, str, str + str_len, str + start, str + str_len, region, option);
+}
+
+void goonig_regex_batch(
+    regex_t *reg,
+    const char **strs,
+    int *lens,
+    int count,
+    int search,
+    char *regions,
+    int region_stride,
+    int *results,
+    OnigOptionType option)
+{
+    for (int i = 0; i < count; i++) {
+        const char *str = strs[i];
+        int len = lens[i];
+        OnigRegion *region =
+            regions ? (OnigRegion *)(regions + i * region_stride) : NULL;
+        if (search) {
+            results[i] = goonig_regex_search(reg, str, len, 0, 0, region, option);
+        } else {
+            results[i] = goonig_regex_match(reg, str, len, 0, region, option);
+        }
+    }
+}
+
+// Oniguruma 5.9.6 does not have onig_scan, so this is a local equivalent
+// that finds successive non-overlapping matches in the given string,
+// starting at offset *pos.
+//
+// If spans is NULL then this just counts all of the remaining matches.
+// Otherwise, it writes the start and end offsets of each match into spans
+// and stops early once spans_cap matches have been written, updating *pos
+// so that the scan can be resumed. Once there are no more matches, *pos is
+// set to -1. The result is the number of matches found in this call.
+int goonig_regex_scan(
+    regex_t *reg,
+    const char *str,
+    int str_len,
+    int *pos,
+    int *spans,
+    int spans_cap,
+    OnigOptionType option)
+{
+    if (str == NULL) {
+        str = "";
+    }
+    const UChar *s = (const UChar *)str;
+    const UChar *end = s + str_len;
+    const UChar *start = s + *pos;
+    OnigRegion region;
+    int count = 0;
+
+    *pos = -1;
+    onig_region_init(&region);
+    while (start <= end) {
+        int r = onig_search(reg, s, end, start, end, &region, option);
+        if (r < 0) {
+            break;
+        }
+        int beg = region.beg[0];
+        int fin = region.end[0];
+
+        start = s + fin;
+        if (beg == fin) {
+            // Step over one whole UTF-8 character after an empty match,
+            // so we don't just find the same empty match again.
+            start++;
+            while (start < end && (*start & 0xC0) == 0x80) {
+                start++;
+            }
+        }
+
+        if (spans != NULL) {
+            spans[count * 2] = beg;
+            spans[count * 2 + 1] = fin;
+        }
+        count++;
+        if (spans != NULL && count == spans_cap) {
+            if (start <= end) {
+                *pos = start - s;
+            }
+            break;
+        }
+    }
+    onig_region_free(&region, 0);
+    return count;
 }
 
 int goonig_regex_capture_count(regex_t *reg)
@@ -82,6 +183,7 @@ int goonig_regex_name_table_cb(
         state->next->idx = groups[i];
         state->next++;
     }
+    return 0;
 }
 
 int goonig_regex_name_table(regex_t *reg, goonig_name_table_entry *next)
@@ -103,7 +205,31 @@ void goonig_free_region(OnigRegion *reg)
     onig_region_free(reg, 0);
 }
 
+void goonig_clear_region(OnigRegion *reg)
+{
+    onig_region_clear(reg);
+}
+
 int goonig_region_resize(OnigRegion *reg, int size)
 {
     return onig_region_resize(reg, size);
 }
+
+OnigSyntaxType *goonig_new_syntax(
+    OnigSyntaxType *base,
+    unsigned int op,
+    unsigned int op2,
+    unsigned int behavior,
+    OnigOptionType options)
+{
+    OnigSyntaxType *syntax = malloc(sizeof(OnigSyntaxType));
+    if (syntax == NULL) {
+        return NULL;
+    }
+    onig_copy_syntax(syntax, base);
+    onig_set_syntax_op(syntax, op);
+    onig_set_syntax_op2(syntax, op2);
+    onig_set_syntax_behavior(syntax, behavior);
+    onig_set_syntax_options(syntax, options);
+    return syntax;
+}
@@ -5,7 +5,6 @@ package onig
 import "C"
 
 import (
-	"reflect"
 	"runtime"
 	"unsafe"
 )
@@ -17,6 +16,16 @@ import (
 // This is the only file in this package that is allowed to import "C". All
 // other files must access C objects via unexported Go symbols defined in this
 // file. No references to "C" may be visible in the package godoc.
+//
+// Regex and Match objects free their C memory in finalizers, so any function
+// here that passes one of their C pointers to C, or reads C memory they own,
+// must call runtime.KeepAlive on the Go object afterwards. Otherwise the
+// finalizer could run while C is still using the object.
+//
+// A compiled regex_t is never modified by Go code after regexInit returns,
+// and so a Regex can be used concurrently from many goroutines. Oniguruma
+// 5.9.6 does increment a counter in regex_t on each search, but that counter
+// is used only by onig_recompile, which these bindings never call.
 
 type errorInfo [C.sizeof_OnigErrorInfo]byte
 
@@ -42,6 +51,103 @@ const (
 	optNotEOL           MatchOptions   = C.ONIG_OPTION_NOTEOL
 )
 
+// Syntax operator and behavior flags, for code that adapts to the syntax.
+const (
+	synOpVariableMetaChars  = C.ONIG_SYN_OP_VARIABLE_META_CHARACTERS
+	synOpDotAnychar         = C.ONIG_SYN_OP_DOT_ANYCHAR
+	synOpAsteriskZeroInf    = C.ONIG_SYN_OP_ASTERISK_ZERO_INF
+	synOpEscAsteriskZeroInf = C.ONIG_SYN_OP_ESC_ASTERISK_ZERO_INF
+	synOpPlusOneInf         = C.ONIG_SYN_OP_PLUS_ONE_INF
+	synOpEscPlusOneInf      = C.ONIG_SYN_OP_ESC_PLUS_ONE_INF
+	synOpQmarkZeroOne       = C.ONIG_SYN_OP_QMARK_ZERO_ONE
+	synOpEscQmarkZeroOne    = C.ONIG_SYN_OP_ESC_QMARK_ZERO_ONE
+	synOpBraceInterval      = C.ONIG_SYN_OP_BRACE_INTERVAL
+	synOpEscBraceInterval   = C.ONIG_SYN_OP_ESC_BRACE_INTERVAL
+	synOpVbarAlt            = C.ONIG_SYN_OP_VBAR_ALT
+	synOpEscVbarAlt         = C.ONIG_SYN_OP_ESC_VBAR_ALT
+	synOpLparenSubexp       = C.ONIG_SYN_OP_LPAREN_SUBEXP
+	synOpEscLparenSubexp    = C.ONIG_SYN_OP_ESC_LPAREN_SUBEXP
+	synOpBracketCC          = C.ONIG_SYN_OP_BRACKET_CC
+	synOpLineAnchor         = C.ONIG_SYN_OP_LINE_ANCHOR
+	synOpQmarkNonGreedy     = C.ONIG_SYN_OP_QMARK_NON_GREEDY
+	synOp2IneffectiveEscape = C.ONIG_SYN_OP2_INEFFECTIVE_ESCAPE
+	synOp2QmarkGroupEffect  = C.ONIG_SYN_OP2_QMARK_GROUP_EFFECT
+	synOp2QmarkLtNamedGroup = C.ONIG_SYN_OP2_QMARK_LT_NAMED_GROUP
+	synOp2OptionPerl        = C.ONIG_SYN_OP2_OPTION_PERL
+	synOp2OptionRuby        = C.ONIG_SYN_OP2_OPTION_RUBY
+	synBackslashEscapeInCC  = C.ONIG_SYN_BACKSLASH_ESCAPE_IN_CC
+	synIneffectiveMetaChar  = C.ONIG_INEFFECTIVE_META_CHAR
+)
+
+const (
+	synOpEscAZBufAnchor         = C.ONIG_SYN_OP_ESC_AZ_BUF_ANCHOR
+	synOpEscCapitalGBeginAnchor = C.ONIG_SYN_OP_ESC_CAPITAL_G_BEGIN_ANCHOR
+	synOpEscLtgtWordBeginEnd    = C.ONIG_SYN_OP_ESC_LTGT_WORD_BEGIN_END
+	synOpPosixBracket           = C.ONIG_SYN_OP_POSIX_BRACKET
+	synOpEscXBraceHex8          = C.ONIG_SYN_OP_ESC_X_BRACE_HEX8
+	synOp2EscCapitalQQuote      = C.ONIG_SYN_OP2_ESC_CAPITAL_Q_QUOTE
+	synOp2PlusPossessiveRepeat  = C.ONIG_SYN_OP2_PLUS_POSSESSIVE_REPEAT
+	synOp2PlusPossessiveIntvl   = C.ONIG_SYN_OP2_PLUS_POSSESSIVE_INTERVAL
+	synOp2CclassSetOp           = C.ONIG_SYN_OP2_CCLASS_SET_OP
+	synOp2EscKNamedBackref      = C.ONIG_SYN_OP2_ESC_K_NAMED_BACKREF
+	synOp2EscGSubexpCall        = C.ONIG_SYN_OP2_ESC_G_SUBEXP_CALL
+	synOp2EscUHex4              = C.ONIG_SYN_OP2_ESC_U_HEX4
+	synOp2EscVVtab              = C.ONIG_SYN_OP2_ESC_V_VTAB
+	synOp2EscHXdigit            = C.ONIG_SYN_OP2_ESC_H_XDIGIT
+	synOp2EscPBraceCharProperty = C.ONIG_SYN_OP2_ESC_P_BRACE_CHAR_PROPERTY
+	synAllowIntervalLowAbbrev   = C.ONIG_SYN_ALLOW_INTERVAL_LOW_ABBREV
+	synCaptureOnlyNamedGroup    = C.ONIG_SYN_CAPTURE_ONLY_NAMED_GROUP
+	synAllowMultiplexDefName    = C.ONIG_SYN_ALLOW_MULTIPLEX_DEFINITION_NAME
+)
+
+// syntaxFeatureBits gives the bits of the op, op2 and behavior fields of an
+// OnigSyntaxType that each of the SyntaxFeatures controls.
+var syntaxFeatureBits = []struct {
+	Feature           SyntaxFeatures
+	Op, Op2, Behavior uint
+}{
+	{SynBufAnchors, synOpEscAZBufAnchor, 0, 0},
+	{SynSearchStartAnchor, synOpEscCapitalGBeginAnchor, 0, 0},
+	{SynWordBeginEnd, synOpEscLtgtWordBeginEnd, 0, 0},
+	{SynPosixBracket, synOpPosixBracket, 0, 0},
+	{SynHexBrace, synOpEscXBraceHex8, 0, 0},
+	{SynQuote, 0, synOp2EscCapitalQQuote, 0},
+	{SynPerlOptions, 0, synOp2OptionPerl, 0},
+	{SynRubyOptions, 0, synOp2OptionRuby, 0},
+	{SynPossessive, 0, synOp2PlusPossessiveRepeat, 0},
+	{SynPossessiveInterval, 0, synOp2PlusPossessiveIntvl, 0},
+	{SynClassSetOps, 0, synOp2CclassSetOp, 0},
+	{SynNamedGroups, 0, synOp2QmarkLtNamedGroup, 0},
+	{SynNamedBackrefs, 0, synOp2EscKNamedBackref, 0},
+	{SynSubexpCalls, 0, synOp2EscGSubexpCall, 0},
+	{SynUnicodeEscape, 0, synOp2EscUHex4, 0},
+	{SynVerticalTab, 0, synOp2EscVVtab, 0},
+	{SynHexDigitClass, 0, synOp2EscHXdigit, 0},
+	{SynCharProperties, 0, synOp2EscPBraceCharProperty, 0},
+	{SynIntervalLowAbbrev, 0, 0, synAllowIntervalLowAbbrev},
+	{SynCaptureOnlyNamed, 0, 0, synCaptureOnlyNamedGroup},
+	{SynDuplicateNames, 0, 0, synAllowMultiplexDefName},
+}
+
+// syntaxInfo is a Go copy of the parts of an OnigSyntaxType that describe
+// which characters are special.
+type syntaxInfo struct {
+	Op       uint
+	Op2      uint
+	Behavior uint
+	Options  CompileOptions
+
+	// These are the characters from the meta character table, which are
+	// used only when Op includes synOpVariableMetaChars. Each is
+	// synIneffectiveMetaChar if the corresponding feature is disabled.
+	Esc            rune
+	AnyChar        rune
+	AnyTime        rune
+	ZeroOrOneTime  rune
+	OneOrMoreTime  rune
+	AnyCharAnyTime rune
+}
+
 type nameTableEntry struct {
 	Name string
 	Num  int
@@ -62,6 +168,42 @@ func init() {
 	SyntaxRuby = Syntax(unsafe.Pointer(&C.OnigSyntaxRuby))
 }
 
+func syntaxGetInfo(s Syntax) syntaxInfo {
+	// Syntax objects are static C data, or are allocated by syntaxNew and
+	// never freed, so we can read them directly.
+	c := s.cPtr()
+	return syntaxInfo{
+		Op:             uint(c.op),
+		Op2:            uint(c.op2),
+		Behavior:       uint(c.behavior),
+		Options:        CompileOptions(c.options),
+		Esc:            rune(c.meta_char_table.esc),
+		AnyChar:        rune(c.meta_char_table.anychar),
+		AnyTime:        rune(c.meta_char_table.anytime),
+		ZeroOrOneTime:  rune(c.meta_char_table.zero_or_one_time),
+		OneOrMoreTime:  rune(c.meta_char_table.one_or_more_time),
+		AnyCharAnyTime: rune(c.meta_char_table.anychar_anytime),
+	}
+}
+
+func syntaxNew(base Syntax, options CompileOptions, enable, disable SyntaxFeatures) Syntax {
+	info := syntaxGetInfo(base)
+	op, op2, behavior := info.Op, info.Op2, info.Behavior
+	for _, f := range syntaxFeatureBits {
+		switch {
+		case enable&f.Feature != 0:
+			op, op2, behavior = op|f.Op, op2|f.Op2, behavior|f.Behavior
+		case disable&f.Feature != 0:
+			op, op2, behavior = op&^f.Op, op2&^f.Op2, behavior&^f.Behavior
+		}
+	}
+	c := C.goonig_new_syntax(base.cPtr(), C.uint(op), C.uint(op2), C.uint(behavior), options.cVal())
+	if c == nil {
+		panic("failed to allocate syntax")
+	}
+	return Syntax(unsafe.Pointer(c))
+}
+
 func errStr(code int, info *errorInfo) string {
 	buf := make([]byte, C.ONIG_MAX_ERROR_MESSAGE_LEN)
 	l := C.goonig_error_code_to_str((*C.OnigUChar)(unsafe.Pointer(&buf[0])), C.int(code), info.cPtr())
@@ -69,24 +211,35 @@ func errStr(code int, info *errorInfo) string {
 	return string(buf)
 }
 
+// regexInit compiles the given pattern into r, which must not already hold
+// a compiled regex; r.syntax is non-zero only once r has been compiled.
+//
+// The regex is compiled directly into r.c, since a regex_t must not be
+// moved once it has been initialized.
 func regexInit(r *Regex, pattern string, options CompileOptions, syntax Syntax) error {
-	patternC := C.CString(pattern)
+	if r.syntax != 0 {
+		panic("regexInit on a regex that is already compiled")
+	}
 	var errInfo errorInfo
 	errInfoPtr := &errInfo
+
 	errCode := C.goonig_init_regex(
 		r.cPtr(),
-		patternC,
+		stringPtr(pattern),
 		C.int(len(pattern)),
 		options.cVal(),
 		syntax.cPtr(),
 		errInfoPtr.cPtr(),
 	)
+	runtime.KeepAlive(r)
 	if errCode != 0 {
+		// Oniguruma has already freed anything it allocated for r.
 		return onigError{
 			code: int(errCode),
 			info: errInfoPtr,
 		}
 	}
+
 	runtime.SetFinalizer(r, func(r *Regex) {
 		// Free any ancillary objects associated with the regex.
 		C.goonig_free_regex(r.cPtr())
@@ -94,66 +247,191 @@ func regexInit(r *Regex, pattern string, options CompileOptions, syntax Syntax)
 	return nil
 }
 
-func regexMatch(r *Regex, s string, options MatchOptions, m *Match) bool {
-	sC := C.CString(s)
-	result := C.goonig_regex_match(
-		r.cPtr(),
-		sC,
-		C.int(len(s)),
-		m.cPtr(),
-		options.cVal(),
-	)
-	return result >= 0
+// regexFree frees the native memory of r immediately, rather than waiting
+// for its finalizer. r must not be used afterwards, and is left uncompiled.
+func regexFree(r *Regex) {
+	runtime.SetFinalizer(r, nil)
+	C.goonig_free_regex(r.cPtr())
+	r.syntax = 0
+}
+
+// regexMatch matches the given string starting exactly at offset at. The
+// caller must ensure that at is in range.
+func regexMatch(r *Regex, s string, at int, options MatchOptions, m *Match) bool {
+	return regexMatchPtr(r, stringPtr(s), len(s), at, options, m)
+}
+
+func regexMatchBytes(r *Regex, b []byte, at int, options MatchOptions, m *Match) bool {
+	return regexMatchPtr(r, bytesPtr(b), len(b), at, options, m)
 }
 
-func regexMatchBytes(r *Regex, b []byte, options MatchOptions, m *Match) bool {
-	sC := (*C.char)(unsafe.Pointer(&b[0]))
+func regexMatchPtr(r *Regex, ptr *C.char, l, at int, options MatchOptions, m *Match) bool {
 	result := C.goonig_regex_match(
 		r.cPtr(),
-		sC,
-		C.int(len(b)),
+		ptr,
+		C.int(l),
+		C.int(at),
 		m.cPtr(),
 		options.cVal(),
 	)
+	runtime.KeepAlive(r)
+	runtime.KeepAlive(m)
 	return result >= 0
 }
 
-func regexSearch(r *Regex, s string, options MatchOptions, rev bool, m *Match) bool {
-	sC := C.CString(s)
+// regexSearch searches the given string for a match beginning at or after
+// offset start, or at or before it if rev is set. The caller must ensure
+// that start is in range.
+func regexSearch(r *Regex, s string, start int, options MatchOptions, rev bool, m *Match) bool {
+	return regexSearchPtr(r, stringPtr(s), len(s), start, options, rev, m)
+}
+
+func regexSearchBytes(r *Regex, b []byte, start int, options MatchOptions, rev bool, m *Match) bool {
+	return regexSearchPtr(r, bytesPtr(b), len(b), start, options, rev, m)
+}
+
+func regexSearchPtr(r *Regex, ptr *C.char, l, start int, options MatchOptions, rev bool, m *Match) bool {
 	revC := C.int(0)
 	if rev {
 		revC = C.int(1)
 	}
 	result := C.goonig_regex_search(
 		r.cPtr(),
-		sC,
-		C.int(len(s)),
+		ptr,
+		C.int(l),
+		C.int(start),
 		revC,
 		m.cPtr(),
 		options.cVal(),
 	)
+	runtime.KeepAlive(r)
+	runtime.KeepAlive(m)
 	return result >= 0
 }
 
-func regexSearchBytes(r *Regex, b []byte, options MatchOptions, rev bool, m *Match) bool {
-	sC := (*C.char)(unsafe.Pointer(&b[0]))
-	revC := C.int(0)
-	if rev {
-		revC = C.int(1)
+// regexBatch runs either a search or a match for each of the given subjects
+// in a single call into C. If ms is non-nil then it must have the same length
+// as subjects, must have been initialized with matchInitSlab, and each result
+// is written into the corresponding match.
+func regexBatch[S string | []byte](r *Regex, subjects []S, options MatchOptions, search bool, ms []Match) []bool {
+	l := len(subjects)
+	if l == 0 {
+		return nil
 	}
-	result := C.goonig_regex_search(
+
+	// Each subject is passed to C by pointer, so they must all be pinned
+	// for the duration of the call since the pointers are stored in Go
+	// memory.
+	var pinner runtime.Pinner
+	defer pinner.Unpin()
+	strsC := make([]*C.char, l)
+	lensC := make([]C.int, l)
+	for i, subj := range subjects {
+		strsC[i] = subjectPtr(subj)
+		lensC[i] = C.int(len(subj))
+		if strsC[i] != nil {
+			pinner.Pin(strsC[i])
+		}
+	}
+
+	var regionsC *C.char
+	if ms != nil {
+		regionsC = (*C.char)(unsafe.Pointer(ms[0].cPtr()))
+	}
+
+	searchC := C.int(0)
+	if search {
+		searchC = C.int(1)
+	}
+	resultsC := make([]C.int, l)
+	C.goonig_regex_batch(
 		r.cPtr(),
-		sC,
-		C.int(len(b)),
-		revC,
-		m.cPtr(),
+		&strsC[0],
+		&lensC[0],
+		C.int(l),
+		searchC,
+		regionsC,
+		C.int(unsafe.Sizeof(Match{})),
+		&resultsC[0],
 		options.cVal(),
 	)
-	return result >= 0
+	runtime.KeepAlive(r)
+	runtime.KeepAlive(ms)
+
+	ret := make([]bool, l)
+	for i, result := range resultsC {
+		ret[i] = result >= 0
+	}
+	return ret
+}
+
+func regexCount(r *Regex, s string, options MatchOptions) int {
+	return regexCountPtr(r, stringPtr(s), len(s), options)
+}
+
+func regexCountBytes(r *Regex, b []byte, options MatchOptions) int {
+	return regexCountPtr(r, bytesPtr(b), len(b), options)
+}
+
+func regexCountPtr(r *Regex, ptr *C.char, l int, options MatchOptions) int {
+	pos := C.int(0)
+	result := C.goonig_regex_scan(
+		r.cPtr(),
+		ptr,
+		C.int(l),
+		&pos,
+		nil,
+		0,
+		options.cVal(),
+	)
+	runtime.KeepAlive(r)
+	return int(result)
+}
+
+func regexScan(r *Regex, s string, options MatchOptions) []Span {
+	return regexScanPtr(r, stringPtr(s), len(s), options)
+}
+
+func regexScanBytes(r *Regex, b []byte, options MatchOptions) []Span {
+	return regexScanPtr(r, bytesPtr(b), len(b), options)
+}
+
+// scanInitialCap is the number of spans regexScanPtr makes room for on its
+// first call into C. If there are more matches than that then it resumes the
+// scan with a buffer twice the size, and so on.
+const scanInitialCap = 64
+
+func regexScanPtr(r *Regex, ptr *C.char, l int, options MatchOptions) []Span {
+	var ret []Span
+	spansC := make([]C.int, scanInitialCap*2)
+	pos := C.int(0)
+	for pos >= 0 {
+		count := int(C.goonig_regex_scan(
+			r.cPtr(),
+			ptr,
+			C.int(l),
+			&pos,
+			&spansC[0],
+			C.int(len(spansC)/2),
+			options.cVal(),
+		))
+		runtime.KeepAlive(r)
+		for i := 0; i < count; i++ {
+			ret = append(ret, Span{
+				Start: int(spansC[i*2]),
+				End:   int(spansC[i*2+1]),
+			})
+		}
+		if pos >= 0 {
+			spansC = make([]C.int, len(spansC)*2)
+		}
+	}
+	return ret
 }
 
 func regexCaptureCount(r *Regex) int {
 	result := C.goonig_regex_capture_count(r.cPtr())
+	runtime.KeepAlive(r)
 	return int(result)
 }
 
@@ -168,30 +446,56 @@ func regexNameTable(r *Regex) []nameTableEntry {
 		return nil
 	}
 	ret := make([]nameTableEntry, realLen)
-	for i, raw := range table {
-		nameLen := int(raw.len)
-		nameBytes := *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{
-			uintptr(unsafe.Pointer(raw.start)), nameLen, nameLen,
-		}))
-
+	for i, raw := range table[:realLen] {
 		ret[i] = nameTableEntry{
-			Name: string(nameBytes),
+			Name: C.GoStringN((*C.char)(unsafe.Pointer(raw.start)), raw.len),
 			Num:  int(raw.idx),
 		}
 	}
+	// The name strings belong to the regex, so we must keep it alive
+	// until we've copied them all.
+	runtime.KeepAlive(r)
 	return ret
 }
 
 func matchInit(m *Match) {
 	C.goonig_init_region(m.cPtr())
+	m.final = true
 	runtime.SetFinalizer(m, func(m *Match) {
 		// Free any buffers associated with the match.
 		C.goonig_free_region(m.cPtr())
 	})
 }
 
-func matchInitFake(m *Match, spans []Span) error {
-	matchInit(m)
+// matchInitSlab prepares a contiguous array of matches, such as for a batch
+// search. Unlike matchInit, the matches in the slab share a single finalizer
+// which frees all of them once none of them are reachable.
+func matchInitSlab(ms []Match) {
+	if len(ms) == 0 {
+		return
+	}
+	// A zeroed OnigRegion is equivalent to one passed to onig_region_init,
+	// so there is no need to call into C to initialize each match here.
+	l := len(ms)
+	for i := range ms {
+		ms[i].final = true
+	}
+	runtime.SetFinalizer(&ms[0], func(first *Match) {
+		slab := unsafe.Slice(first, l)
+		for i := range slab {
+			C.goonig_free_region(slab[i].cPtr())
+		}
+	})
+}
+
+// matchSetSpans replaces the spans in m with the given ones, first
+// initializing m if it is a zero Match, such as one allocated by
+// encoding/json. m must then be allocated separately rather than embedded in
+// another value, so that it can have a finalizer.
+func matchSetSpans(m *Match, spans []Span) error {
+	if !m.final {
+		matchInit(m)
+	}
 	c := m.cPtr()
 	l := len(spans)
 	errCode := C.goonig_region_resize(c, C.int(l))
@@ -200,22 +504,20 @@ func matchInitFake(m *Match, spans []Span) error {
 			code: int(errCode),
 		}
 	}
-	begs := *(*[]C.int)(unsafe.Pointer(&reflect.SliceHeader{
-		uintptr(unsafe.Pointer(c.beg)), l, l,
-	}))
-	ends := *(*[]C.int)(unsafe.Pointer(&reflect.SliceHeader{
-		uintptr(unsafe.Pointer(c.end)), l, l,
-	}))
+	begs, ends := regionArrays(c)
 	for i, span := range spans {
 		begs[i] = C.int(span.Start)
 		ends[i] = C.int(span.End)
 	}
+	runtime.KeepAlive(m)
 	return nil
 }
 
 func matchCaptureCount(m *Match) int {
 	c := m.cPtr()
-	return int(c.num_regs) - 1
+	ret := int(c.num_regs) - 1
+	runtime.KeepAlive(m)
+	return ret
 }
 
 func matchCapture(m *Match, idx int) Span {
@@ -224,18 +526,18 @@ func matchCapture(m *Match, idx int) Span {
 		panic("capture index out of range")
 	}
 
-	c := m.cPtr()
-	begPU := uintptr(unsafe.Pointer(c.beg))
-	endPU := uintptr(unsafe.Pointer(c.end))
-	begPU += uintptr(idx)
-	endPU += uintptr(idx)
-	begP := (*C.int)(unsafe.Pointer(begPU))
-	endP := (*C.int)(unsafe.Pointer(endPU))
-
-	return Span{
-		Start: int(*begP),
-		End:   int(*endP),
+	begs, ends := regionArrays(m.cPtr())
+	ret := Span{
+		Start: int(begs[idx]),
+		End:   int(ends[idx]),
 	}
+	runtime.KeepAlive(m)
+	return ret
+}
+
+func matchReset(m *Match) {
+	C.goonig_clear_region(m.cPtr())
+	runtime.KeepAlive(m)
 }
 
 func matchEqual(a *Match, b *Match) bool {
@@ -251,20 +553,9 @@ func matchEqual(a *Match, b *Match) bool {
 	if aC.num_regs != bC.num_regs {
 		return false
 	}
-	num := int(aC.num_regs)
-
-	aBegs := *(*[]int)(unsafe.Pointer(&reflect.SliceHeader{
-		uintptr(unsafe.Pointer(aC.beg)), num, num,
-	}))
-	bBegs := *(*[]int)(unsafe.Pointer(&reflect.SliceHeader{
-		uintptr(unsafe.Pointer(bC.beg)), num, num,
-	}))
-	aEnds := *(*[]int)(unsafe.Pointer(&reflect.SliceHeader{
-		uintptr(unsafe.Pointer(aC.end)), num, num,
-	}))
-	bEnds := *(*[]int)(unsafe.Pointer(&reflect.SliceHeader{
-		uintptr(unsafe.Pointer(bC.end)), num, num,
-	}))
+
+	aBegs, aEnds := regionArrays(aC)
+	bBegs, bEnds := regionArrays(bC)
 
 	for i := range aBegs {
 		if aBegs[i] != bBegs[i] {
@@ -277,9 +568,57 @@ func matchEqual(a *Match, b *Match) bool {
 		}
 	}
 
+	runtime.KeepAlive(a)
+	runtime.KeepAlive(b)
 	return true
 }
 
+// regionArrays returns Go slices that alias the start and end offset arrays
+// in the given region. The slices are valid only until the region is next
+// modified by Oniguruma, and the caller must keep the region's owning Match
+// alive while using them.
+func regionArrays(c *C.OnigRegion) (begs, ends []C.int) {
+	l := int(c.num_regs)
+	if l <= 0 {
+		return nil, nil
+	}
+	return unsafe.Slice(c.beg, l), unsafe.Slice(c.end, l)
+}
+
+// stringPtr returns a pointer to the bytes backing the given string, without
+// copying them. Go strings are immutable, so Oniguruma must never write
+// through this pointer.
+//
+// cgo keeps Go memory passed directly as a call argument pinned for the
+// duration of that call, so the result must be passed directly to a C
+// function and not retained after it returns.
+func stringPtr(s string) *C.char {
+	if len(s) == 0 {
+		// The bindings.c wrappers substitute an empty C string for NULL.
+		return nil
+	}
+	return (*C.char)(unsafe.Pointer(unsafe.StringData(s)))
+}
+
+// subjectPtr is like stringPtr but for either kind of subject.
+func subjectPtr[S string | []byte](subj S) *C.char {
+	switch subj := any(subj).(type) {
+	case string:
+		return stringPtr(subj)
+	case []byte:
+		return bytesPtr(subj)
+	}
+	return nil
+}
+
+// bytesPtr is like stringPtr but for byte slices.
+func bytesPtr(b []byte) *C.char {
+	if len(b) == 0 {
+		return nil
+	}
+	return (*C.char)(unsafe.Pointer(unsafe.SliceData(b)))
+}
+
 func (r *Regex) cPtr() *C.regex_t {
 	if r == nil {
 		return nil
@@ -2,6 +2,10 @@
 
 // This file contains some helper wrappers around oniguruma APIs. Any global
 // symbols defined here must be namespaced as "goonig".
+//
+// Functions that take a subject or pattern string as a pointer and length
+// accept NULL as the pointer when the length is zero, since Go does not
+// guarantee a valid pointer for empty strings and slices.
 
 typedef struct {
     UChar *start;
@@ -23,18 +27,49 @@ int goonig_regex_match(
     regex_t *reg,
     const char *str,
     int str_len,
+    int at,
     OnigRegion *region,
     OnigOptionType option);
 int goonig_regex_search(
     regex_t *reg,
     const char *str,
     int str_len,
+    int start,
     int rev, // bool
     OnigRegion *region,
     OnigOptionType option);
+void goonig_regex_batch(
+    regex_t *reg,
+    const char **strs, // count elements, each of which may be NULL
+    int *lens, // count elements
+    int count,
+    int search, // bool
+    char *regions, // count elements of region_stride bytes each, or NULL
+    int region_stride,
+    int *results,
+    OnigOptionType option);
+int goonig_regex_scan(
+    regex_t *reg,
+    const char *str,
+    int str_len,
+    int *pos, // in/out
+    int *spans, // pairs of start and end offsets, or NULL
+    int spans_cap,
+    OnigOptionType option);
 int goonig_regex_capture_count(regex_t *reg);
 int goonig_regex_name_table(regex_t *reg, goonig_name_table_entry *next);
 
 void goonig_init_region(OnigRegion *reg);
 void goonig_free_region(OnigRegion *reg);
+void goonig_clear_region(OnigRegion *reg);
 int goonig_region_resize(OnigRegion *reg, int size);
+
+// goonig_new_syntax allocates a copy of base with the given operators,
+// behavior and options, or returns NULL if allocation fails. The result is
+// never freed.
+OnigSyntaxType *goonig_new_syntax(
+    OnigSyntaxType *base,
+    unsigned int op,
+    unsigned int op2,
+    unsigned int behavior,
+    OnigOptionType options);
@@ -0,0 +1,574 @@
+package onig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Node is an element of a regular expression built programmatically, as an
+// alternative to writing a pattern string by hand.
+//
+// Nodes are created by functions like Literal, Concat and Group, and a tree
+// of nodes can be rendered into a pattern for a particular syntax using
+// BuildPattern. Rendering takes care of escaping literals and of any
+// grouping needed to preserve the structure of the tree, and tracks the
+// capture numbers assigned to each group.
+//
+// Nodes are immutable, so a node can be used in many different trees.
+type Node interface {
+	// prec returns the precedence level of the node, which decides whether
+	// it must be wrapped in a group to appear in a particular context.
+	prec() int
+
+	writePattern(w *patternWriter) error
+}
+
+// Precedence levels for Node.prec, from loosest to tightest binding.
+const (
+	precAlt = iota
+	precConcat
+	precRepeat
+	precAtom
+)
+
+// Unbounded can be passed as the max argument to Repeat or RepeatLazy to
+// allow any number of repetitions.
+const Unbounded = -1
+
+// Literal returns a node that matches the given string literally.
+func Literal(s string) Node {
+	return literalNode(s)
+}
+
+// Concat returns a node that matches each of the given nodes in sequence.
+func Concat(nodes ...Node) Node {
+	return concatNode(nodes)
+}
+
+// Alt returns a node that matches any one of the given nodes, preferring
+// earlier nodes over later ones. At least one node must be given.
+func Alt(nodes ...Node) Node {
+	return altNode(nodes)
+}
+
+// Group returns a capture group around the given node. The capture number
+// assigned to the group can be obtained from the BuiltPattern that results
+// from rendering a tree containing it.
+func Group(n Node) *CaptureGroup {
+	return &CaptureGroup{node: n}
+}
+
+// NamedGroup is like Group but gives the group a name. Each name must
+// appear only once in a pattern.
+func NamedGroup(name string, n Node) *CaptureGroup {
+	return &CaptureGroup{name: name, node: n}
+}
+
+// Repeat returns a node that greedily matches the given node at least min
+// times and at most max times. If max is Unbounded then there is no upper
+// limit.
+func Repeat(n Node, min, max int) Node {
+	return repeatNode{node: n, min: min, max: max}
+}
+
+// RepeatLazy is like Repeat but matches as few repetitions as possible.
+func RepeatLazy(n Node, min, max int) Node {
+	return repeatNode{node: n, min: min, max: max, lazy: true}
+}
+
+// Lookahead returns a zero-width node that matches only if the given node
+// matches at the current position.
+func Lookahead(n Node) Node {
+	return groupNode{prefix: "?=", node: n}
+}
+
+// NegativeLookahead returns a zero-width node that matches only if the given
+// node does not match at the current position.
+func NegativeLookahead(n Node) Node {
+	return groupNode{prefix: "?!", node: n}
+}
+
+// Atomic returns a node that matches the given node without backtracking
+// into it once it has matched.
+func Atomic(n Node) Node {
+	return groupNode{prefix: "?>", node: n}
+}
+
+// AnyChar returns a node that matches any single character. Whether this
+// includes newlines depends on the compile options.
+func AnyChar() Node {
+	return anyCharNode{}
+}
+
+// CaptureGroup is a Node representing a capture group, created by Group or
+// NamedGroup.
+type CaptureGroup struct {
+	name string
+	node Node
+}
+
+// CharClass is a Node that matches any single character from a set of
+// characters.
+type CharClass struct {
+	negated bool
+	ranges  []charRange
+}
+
+type charRange struct {
+	lo, hi rune
+}
+
+// Chars returns a character class that matches any one of the characters in
+// the given string.
+func Chars(s string) *CharClass {
+	ret := &CharClass{}
+	for _, r := range s {
+		ret.ranges = append(ret.ranges, charRange{r, r})
+	}
+	return ret
+}
+
+// CharRange returns a character class that matches any one character from
+// lo to hi inclusive.
+func CharRange(lo, hi rune) *CharClass {
+	return &CharClass{ranges: []charRange{{lo, hi}}}
+}
+
+// Union returns a new character class that adds the characters of all of
+// the others to those of the receiver. The result is negated only if the
+// receiver is; whether the others are negated is disregarded.
+func (c *CharClass) Union(others ...*CharClass) *CharClass {
+	ret := &CharClass{negated: c.negated}
+	ret.ranges = append(ret.ranges, c.ranges...)
+	for _, other := range others {
+		ret.ranges = append(ret.ranges, other.ranges...)
+	}
+	return ret
+}
+
+// Negate returns a new character class that matches any character that the
+// receiver does not.
+func (c *CharClass) Negate() *CharClass {
+	return &CharClass{negated: !c.negated, ranges: c.ranges}
+}
+
+// BuiltPattern is the result of rendering a tree of nodes into a pattern for
+// a particular syntax.
+type BuiltPattern struct {
+	// Pattern is the rendered pattern string.
+	Pattern string
+
+	// Syntax is the syntax the pattern was rendered for.
+	Syntax Syntax
+
+	// Options are the compile options that the pattern requires in order
+	// for its capture groups to be numbered as described by CaptureIndex.
+	Options CompileOptions
+
+	groups map[*CaptureGroup]int
+	names  map[string]int
+}
+
+// BuildPattern renders the given tree of nodes into a pattern for the given
+// syntax.
+//
+// An error is returned if the tree uses a feature that the syntax does not
+// support, if a capture group appears more than once in the tree, or if two
+// capture groups have the same name.
+func BuildPattern(n Node, syntax Syntax) (*BuiltPattern, error) {
+	w := &patternWriter{
+		q:      newQuoter(syntax),
+		groups: make(map[*CaptureGroup]int),
+		names:  make(map[string]int),
+	}
+	err := n.writePattern(w)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &BuiltPattern{
+		Pattern: w.buf.String(),
+		Syntax:  syntax,
+		groups:  w.groups,
+		names:   w.names,
+	}
+	if w.unnamed {
+		// Some syntaxes only capture named groups when any are present,
+		// unless this option is set.
+		ret.Options |= OptCaptureGroup
+	}
+	return ret, nil
+}
+
+// CompilePattern is a convenience wrapper around BuildPattern followed by
+// BuiltPattern.Compile.
+func CompilePattern(n Node, options CompileOptions, syntax Syntax) (*Regex, error) {
+	p, err := BuildPattern(n, syntax)
+	if err != nil {
+		return nil, err
+	}
+	return p.Compile(options)
+}
+
+// Compile compiles the pattern using its syntax, along with the required
+// options and any additional options given.
+func (p *BuiltPattern) Compile(options CompileOptions) (*Regex, error) {
+	return NewRegex(p.Pattern, p.Options|options, p.Syntax)
+}
+
+// CaptureIndex returns the capture number assigned to the given group, or
+// zero if the group does not appear in the pattern.
+func (p *BuiltPattern) CaptureIndex(g *CaptureGroup) int {
+	return p.groups[g]
+}
+
+// NamedCaptures returns a map from the names of the named groups in the
+// pattern to their capture numbers.
+func (p *BuiltPattern) NamedCaptures() map[string]int {
+	if len(p.names) == 0 {
+		return nil
+	}
+	ret := make(map[string]int, len(p.names))
+	for name, idx := range p.names {
+		ret[name] = idx
+	}
+	return ret
+}
+
+// patternWriter accumulates the state of rendering a tree of nodes.
+type patternWriter struct {
+	q       quoter
+	buf     strings.Builder
+	ncap    int
+	groups  map[*CaptureGroup]int
+	names   map[string]int
+	unnamed bool
+}
+
+// writeAt writes the given node, wrapping it in a group if its precedence is
+// lower than the given minimum.
+func (w *patternWriter) writeAt(n Node, min int) error {
+	if n.prec() >= min {
+		return n.writePattern(w)
+	}
+	return w.writeGroup("?:", n)
+}
+
+// writeGroup writes the given node inside parentheses, which start with the
+// given prefix. The "?:" prefix produces a non-capturing group if the syntax
+// supports it, or a counted capturing group otherwise.
+func (w *patternWriter) writeGroup(prefix string, n Node) error {
+	info := w.q.info
+	var open, close string
+	switch {
+	case info.Op&synOpLparenSubexp != 0:
+		open, close = "(", ")"
+	case info.Op&synOpEscLparenSubexp != 0 && w.q.esc != -1:
+		open, close = string(w.q.esc)+"(", string(w.q.esc)+")"
+	default:
+		return fmt.Errorf("syntax does not support groups")
+	}
+
+	if prefix != "" && info.Op2&synOp2QmarkGroupEffect == 0 {
+		if prefix != "?:" {
+			return fmt.Errorf("syntax does not support (%s...) groups", prefix)
+		}
+		// We'll use a capturing group instead, which will be numbered.
+		prefix = ""
+	}
+	if prefix == "" {
+		w.ncap++
+		w.unnamed = true
+	}
+
+	w.buf.WriteString(open)
+	w.buf.WriteString(prefix)
+	if err := n.writePattern(w); err != nil {
+		return err
+	}
+	w.buf.WriteString(close)
+	return nil
+}
+
+// writeOp writes an operator that is written either bare or escaped
+// depending on which of the given syntax operator flags is set.
+func (w *patternWriter) writeOp(op string, bare, escaped uint) bool {
+	switch {
+	case w.q.info.Op&bare != 0:
+		w.buf.WriteString(op)
+	case w.q.info.Op&escaped != 0 && w.q.esc != -1:
+		w.buf.WriteRune(w.q.esc)
+		w.buf.WriteString(op)
+	default:
+		return false
+	}
+	return true
+}
+
+type literalNode string
+
+func (n literalNode) prec() int {
+	if len([]rune(string(n))) == 1 {
+		return precAtom
+	}
+	return precConcat
+}
+
+func (n literalNode) writePattern(w *patternWriter) error {
+	for _, r := range string(n) {
+		w.q.writeLiteral(&w.buf, r)
+	}
+	return nil
+}
+
+type concatNode []Node
+
+func (n concatNode) prec() int {
+	if len(n) == 1 {
+		return n[0].prec()
+	}
+	return precConcat
+}
+
+func (n concatNode) writePattern(w *patternWriter) error {
+	for _, child := range n {
+		if err := w.writeAt(child, precConcat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type altNode []Node
+
+func (n altNode) prec() int {
+	if len(n) == 1 {
+		return n[0].prec()
+	}
+	return precAlt
+}
+
+func (n altNode) writePattern(w *patternWriter) error {
+	if len(n) == 0 {
+		return fmt.Errorf("alternation must have at least one alternative")
+	}
+	for i, child := range n {
+		if i > 0 && !w.writeOp("|", synOpVbarAlt, synOpEscVbarAlt) {
+			return fmt.Errorf("syntax does not support alternation")
+		}
+		if err := w.writeAt(child, precAlt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type repeatNode struct {
+	node     Node
+	min, max int
+	lazy     bool
+}
+
+func (n repeatNode) prec() int {
+	return precRepeat
+}
+
+func (n repeatNode) writePattern(w *patternWriter) error {
+	if n.min < 0 || (n.max != Unbounded && n.max < n.min) {
+		return fmt.Errorf("invalid repetition range %d to %d", n.min, n.max)
+	}
+	if err := w.writeAt(n.node, precAtom); err != nil {
+		return err
+	}
+
+	ok := false
+	switch {
+	case n.min == 0 && n.max == Unbounded:
+		ok = w.writeOp("*", synOpAsteriskZeroInf, synOpEscAsteriskZeroInf)
+	case n.min == 1 && n.max == Unbounded:
+		ok = w.writeOp("+", synOpPlusOneInf, synOpEscPlusOneInf)
+	case n.min == 0 && n.max == 1:
+		ok = w.writeOp("?", synOpQmarkZeroOne, synOpEscQmarkZeroOne)
+	}
+	if !ok {
+		interval := strconv.Itoa(n.min) + ","
+		if n.max != Unbounded {
+			interval += strconv.Itoa(n.max)
+		}
+		if !w.writeOp("{", synOpBraceInterval, synOpEscBraceInterval) {
+			return fmt.Errorf("syntax does not support repetition from %d to %d", n.min, n.max)
+		}
+		w.buf.WriteString(interval)
+		w.writeOp("}", synOpBraceInterval, synOpEscBraceInterval)
+	}
+
+	if n.lazy && n.min != n.max {
+		if w.q.info.Op&synOpQmarkNonGreedy == 0 {
+			return fmt.Errorf("syntax does not support lazy repetition")
+		}
+		w.buf.WriteByte('?')
+	}
+	return nil
+}
+
+type groupNode struct {
+	prefix string
+	node   Node
+}
+
+func (n groupNode) prec() int {
+	return precAtom
+}
+
+func (n groupNode) writePattern(w *patternWriter) error {
+	return w.writeGroup(n.prefix, n.node)
+}
+
+type anyCharNode struct{}
+
+func (n anyCharNode) prec() int {
+	return precAtom
+}
+
+func (n anyCharNode) writePattern(w *patternWriter) error {
+	if w.q.info.Op&synOpDotAnychar == 0 {
+		return fmt.Errorf("syntax does not support matching any character")
+	}
+	w.buf.WriteByte('.')
+	return nil
+}
+
+func (g *CaptureGroup) prec() int {
+	return precAtom
+}
+
+func (g *CaptureGroup) writePattern(w *patternWriter) error {
+	if _, exists := w.groups[g]; exists {
+		return fmt.Errorf("capture group appears more than once in the pattern")
+	}
+	if g.name == "" {
+		// writeGroup will assign the next capture number to this group.
+		w.groups[g] = w.ncap + 1
+		return w.writeGroup("", g.node)
+	}
+
+	if !validGroupName(g.name) {
+		return fmt.Errorf("invalid capture group name %q", g.name)
+	}
+	if _, exists := w.names[g.name]; exists {
+		return fmt.Errorf("duplicate capture group name %q", g.name)
+	}
+	if w.q.info.Op2&synOp2QmarkLtNamedGroup == 0 {
+		return fmt.Errorf("syntax does not support named groups")
+	}
+	// writeGroup only counts unnamed groups, so we count this one here.
+	w.ncap++
+	idx := w.ncap
+	w.groups[g] = idx
+	w.names[g.name] = idx
+	return w.writeGroup("?<"+g.name+">", g.node)
+}
+
+func validGroupName(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func (c *CharClass) prec() int {
+	return precAtom
+}
+
+func (c *CharClass) writePattern(w *patternWriter) error {
+	info := w.q.info
+	if len(c.ranges) == 0 {
+		return fmt.Errorf("empty character class")
+	}
+	if !c.negated && len(c.ranges) == 1 && c.ranges[0].lo == c.ranges[0].hi {
+		// A class of just one character is the same as a literal.
+		w.q.writeLiteral(&w.buf, c.ranges[0].lo)
+		return nil
+	}
+	if info.Op&synOpBracketCC == 0 {
+		return fmt.Errorf("syntax does not support character classes")
+	}
+
+	w.buf.WriteByte('[')
+	if c.negated {
+		w.buf.WriteByte('^')
+	}
+	if info.Behavior&synBackslashEscapeInCC != 0 && w.q.esc != -1 {
+		for _, rng := range c.ranges {
+			w.writeClassChar(rng.lo)
+			if rng.hi != rng.lo {
+				w.buf.WriteByte('-')
+				w.writeClassChar(rng.hi)
+			}
+		}
+	} else {
+		// Without escapes, we must rely on the POSIX rules instead: "]" is
+		// literal only at the start, "-" only at the start or end, and "^"
+		// anywhere except the start. We also put "[" near the end, so it
+		// can't be mistaken for the start of a POSIX bracket like
+		// "[:alpha:]".
+		var first, last strings.Builder
+		var caret, bracket, hyphen bool
+		for _, rng := range c.ranges {
+			if rng.lo != rng.hi {
+				if strings.ContainsRune("]^-[", rng.lo) || strings.ContainsRune("]^-[", rng.hi) {
+					return fmt.Errorf("syntax cannot represent character range %q to %q", rng.lo, rng.hi)
+				}
+				last.WriteRune(rng.lo)
+				last.WriteByte('-')
+				last.WriteRune(rng.hi)
+				continue
+			}
+			switch rng.lo {
+			case ']':
+				first.WriteByte(']')
+			case '^':
+				caret = true
+			case '[':
+				bracket = true
+			case '-':
+				hyphen = true
+			default:
+				last.WriteRune(rng.lo)
+			}
+		}
+		w.buf.WriteString(first.String())
+		w.buf.WriteString(last.String())
+		if bracket {
+			w.buf.WriteByte('[')
+		}
+		if caret {
+			if first.Len() == 0 && last.Len() == 0 && !bracket {
+				if !hyphen {
+					return fmt.Errorf("syntax cannot represent a character class of only %q", '^')
+				}
+				// A leading "-" is also literal, so it can go before "^".
+				w.buf.WriteByte('-')
+				hyphen = false
+			}
+			w.buf.WriteByte('^')
+		}
+		if hyphen {
+			w.buf.WriteByte('-')
+		}
+	}
+	w.buf.WriteByte(']')
+	return nil
+}
+
+func (w *patternWriter) writeClassChar(r rune) {
+	switch r {
+	case ']', '[', '^', '-', '&', '\\', w.q.esc:
+		w.buf.WriteRune(w.q.esc)
+	}
+	w.buf.WriteRune(r)
+}
@@ -0,0 +1,280 @@
+package onig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildPattern(t *testing.T) {
+	tests := []struct {
+		Node   Node
+		Syntax Syntax
+		Want   string
+	}{
+		{
+			Literal(`a.b`),
+			SyntaxRuby,
+			`a\.b`,
+		},
+		{
+			Concat(Literal(`ab`), Repeat(Literal(`cd`), 0, Unbounded)),
+			SyntaxRuby,
+			`ab(?:cd)*`,
+		},
+		{
+			Concat(Literal(`ab`), Repeat(Literal(`cd`), 0, Unbounded)),
+			SyntaxPosixBasic,
+			`ab\(cd\)*`,
+		},
+		{
+			Concat(Alt(Literal(`a`), Literal(`b`)), Literal(`c`)),
+			SyntaxRuby,
+			`(?:a|b)c`,
+		},
+		{
+			Alt(Literal(`a`), Alt(Literal(`b`), Literal(`c`))),
+			SyntaxRuby,
+			`a|b|c`,
+		},
+		{
+			Alt(Literal(`a`), Literal(`b`)),
+			SyntaxEmacs,
+			`a\|b`,
+		},
+		{
+			Repeat(Literal(`a`), 1, Unbounded),
+			SyntaxRuby,
+			`a+`,
+		},
+		{
+			Repeat(Literal(`a`), 0, 1),
+			SyntaxRuby,
+			`a?`,
+		},
+		{
+			Repeat(Literal(`a`), 2, 3),
+			SyntaxRuby,
+			`a{2,3}`,
+		},
+		{
+			Repeat(Literal(`a`), 2, Unbounded),
+			SyntaxPosixBasic,
+			`a\{2,\}`,
+		},
+		{
+			RepeatLazy(Literal(`a`), 0, Unbounded),
+			SyntaxRuby,
+			`a*?`,
+		},
+		{
+			Repeat(Repeat(Literal(`a`), 0, 1), 2, 2),
+			SyntaxRuby,
+			`(?:a?){2,2}`,
+		},
+		{
+			Concat(Lookahead(Literal(`x`)), Atomic(Literal(`xy`)), NegativeLookahead(Literal(`z`))),
+			SyntaxRuby,
+			`(?=x)(?>xy)(?!z)`,
+		},
+		{
+			Concat(Group(Literal(`a`)), NamedGroup("b", Literal(`b`))),
+			SyntaxRuby,
+			`(a)(?<b>b)`,
+		},
+		{
+			Concat(AnyChar(), Chars(`a-]`), CharRange('0', '9').Union(Chars(`_`)).Negate()),
+			SyntaxRuby,
+			`.[a\-\]][^0-9_]`,
+		},
+		{
+			Concat(Chars(`a-]^`), Chars(`^-`)),
+			SyntaxPosixExtended,
+			`[]a^-][-^]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Want, func(t *testing.T) {
+			got, err := BuildPattern(test.Node, test.Syntax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Pattern != test.Want {
+				t.Errorf("wrong pattern\ngot:  %s\nwant: %s", got.Pattern, test.Want)
+			}
+			if _, err := got.Compile(NoCompileOpts); err != nil {
+				t.Errorf("failed to compile: %s", err)
+			}
+		})
+	}
+}
+
+func TestBuildPatternCaptures(t *testing.T) {
+	// These are reused in each of the syntaxes below, and the capture
+	// numbers must account for any groups introduced by the builder itself.
+	year := Group(Repeat(CharRange('0', '9'), 4, 4))
+	month := Group(Repeat(CharRange('0', '9'), 2, 2))
+	node := Concat(
+		Repeat(Literal(`ab`), 0, Unbounded),
+		year,
+		Literal(`-`),
+		month,
+	)
+
+	tests := []struct {
+		Name      string
+		Syntax    Syntax
+		WantYear  int
+		WantMonth int
+	}{
+		{"Ruby", SyntaxRuby, 1, 2},
+		{"PosixBasic", SyntaxPosixBasic, 2, 3},
+		{"PosixExtended", SyntaxPosixExtended, 2, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			p, err := BuildPattern(node, test.Syntax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.CaptureIndex(year); got != test.WantYear {
+				t.Errorf("wrong index for year %d; want %d", got, test.WantYear)
+			}
+			if got := p.CaptureIndex(month); got != test.WantMonth {
+				t.Errorf("wrong index for month %d; want %d", got, test.WantMonth)
+			}
+
+			r, err := p.Compile(NoCompileOpts)
+			if err != nil {
+				t.Fatalf("failed to compile %q: %s", p.Pattern, err)
+			}
+			s := `abab2006-01`
+			m := r.Match(s, NoMatchOpts)
+			if m == nil {
+				t.Fatalf("%q does not match %q", p.Pattern, s)
+			}
+			if got, want := m.Capture(p.CaptureIndex(year)).Substr(s), `2006`; got != want {
+				t.Errorf("wrong year %q; want %q", got, want)
+			}
+			if got, want := m.Capture(p.CaptureIndex(month)).Substr(s), `01`; got != want {
+				t.Errorf("wrong month %q; want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBuildPatternNamedCaptures(t *testing.T) {
+	inner := Group(Literal(`b`))
+	outer := NamedGroup("outer", Concat(Literal(`a`), inner))
+	last := NamedGroup("last", Literal(`c`))
+	p, err := BuildPattern(Concat(outer, last), SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := p.Pattern, `(?<outer>a(b))(?<last>c)`; got != want {
+		t.Errorf("wrong pattern\ngot:  %s\nwant: %s", got, want)
+	}
+	wantNames := map[string]int{"outer": 1, "last": 3}
+	if got := p.NamedCaptures(); !reflect.DeepEqual(got, wantNames) {
+		t.Errorf("wrong NamedCaptures\ngot:  %#v\nwant: %#v", got, wantNames)
+	}
+	if got, want := p.CaptureIndex(inner), 2; got != want {
+		t.Errorf("wrong index for inner group %d; want %d", got, want)
+	}
+
+	r, err := p.Compile(NoCompileOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.NamedCapturesFirst(); !reflect.DeepEqual(got, wantNames) {
+		t.Errorf("compiled regex has wrong NamedCaptures\ngot:  %#v\nwant: %#v", got, wantNames)
+	}
+	want := mustFakeMatch([]Span{{0, 3}, {0, 2}, {1, 2}, {2, 3}})
+	if got := r.Match(`abc`, NoMatchOpts); !got.Equal(want) {
+		t.Errorf("wrong match\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestBuildPatternErrors(t *testing.T) {
+	dup := Group(Literal(`a`))
+	tests := []struct {
+		Node    Node
+		Syntax  Syntax
+		WantErr string
+	}{
+		{
+			Lookahead(Literal(`a`)),
+			SyntaxPosixBasic,
+			`syntax does not support (?=...) groups`,
+		},
+		{
+			NamedGroup("a", Literal(`a`)),
+			SyntaxPerl,
+			`syntax does not support named groups`,
+		},
+		{
+			Concat(NamedGroup("a", Literal(`a`)), NamedGroup("a", Literal(`b`))),
+			SyntaxRuby,
+			`duplicate capture group name "a"`,
+		},
+		{
+			NamedGroup("1a", Literal(`a`)),
+			SyntaxRuby,
+			`invalid capture group name "1a"`,
+		},
+		{
+			Concat(dup, dup),
+			SyntaxRuby,
+			`capture group appears more than once in the pattern`,
+		},
+		{
+			Alt(Literal(`a`), Literal(`b`)),
+			SyntaxPosixBasic,
+			`syntax does not support alternation`,
+		},
+		{
+			Alt(),
+			SyntaxRuby,
+			`alternation must have at least one alternative`,
+		},
+		{
+			Repeat(Literal(`a`), 3, 2),
+			SyntaxRuby,
+			`invalid repetition range 3 to 2`,
+		},
+		{
+			Chars(``),
+			SyntaxRuby,
+			`empty character class`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.WantErr, func(t *testing.T) {
+			_, err := BuildPattern(test.Node, test.Syntax)
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if got := err.Error(); got != test.WantErr {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.WantErr)
+			}
+		})
+	}
+}
+
+func TestCompilePattern(t *testing.T) {
+	r, err := CompilePattern(
+		Concat(Literal(`hello`), Repeat(Chars(` `), 1, Unbounded), Group(Literal(`world`))),
+		OptIgnoreCase,
+		SyntaxRuby,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustFakeMatch([]Span{{0, 13}, {8, 13}})
+	if got := r.Match(`HELLO   World`, NoMatchOpts); !got.Equal(want) {
+		t.Errorf("wrong match\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
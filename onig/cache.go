@@ -0,0 +1,237 @@
+package onig
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache is a bounded cache of compiled regular expressions, keyed by the
+// pattern, options and syntax they were compiled with. When the cache is
+// full, the least-recently-used regex is evicted to make room.
+//
+// Since a Regex is safe for concurrent use, the same cached Regex may be
+// returned to many callers at once. A Cache is itself safe for concurrent
+// use.
+//
+// A regex returned by Get may be kept by the caller indefinitely, so
+// evicting it only removes the cache's reference, and its native memory is
+// freed by the garbage collector once no other references remain. A regex
+// that is only ever obtained using Acquire remains owned by the cache, so
+// its native memory is freed as soon as it has been evicted and released.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[cacheKey]*list.Element
+	lru      *list.List // of *cacheEntry, most recently used at the front
+	stats    CacheStats
+
+	// acquired holds the entries that have been acquired and not yet
+	// released, which may include evicted entries.
+	acquired map[*Regex]*cacheEntry
+}
+
+// CacheStats is a snapshot of the counters maintained by a Cache.
+type CacheStats struct {
+	// Hits is the number of requests that were served by an existing
+	// entry in the cache.
+	Hits uint64
+
+	// Misses is the number of requests that required compiling a new
+	// regex, including those where compilation failed.
+	Misses uint64
+
+	// Evictions is the number of entries that were removed to make room
+	// for new entries. The native memory of an evicted regex is freed as
+	// described for Cache.
+	Evictions uint64
+
+	// Len is the number of entries currently in the cache.
+	Len int
+}
+
+type cacheKey struct {
+	pattern string
+	options CompileOptions
+	syntax  Syntax
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	regex *Regex
+
+	// shared is true once the regex has been returned by Get, after which
+	// the cache must never free it. refs counts the calls to Acquire that
+	// have not yet been released, and evicted is true once the entry has
+	// been removed from the cache.
+	shared  bool
+	refs    int
+	evicted bool
+}
+
+// defaultCacheSize is the capacity of the cache used by the package-level
+// helper functions.
+const defaultCacheSize = 256
+
+var defaultCache = NewCache(defaultCacheSize)
+
+// NewCache creates a new, empty cache that will retain at most the given
+// number of compiled regexes. NewCache panics if capacity is less than one.
+func NewCache(capacity int) *Cache {
+	if capacity < 1 {
+		panic("cache capacity must be at least one")
+	}
+	return &Cache{
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element, capacity),
+		lru:      list.New(),
+		acquired: make(map[*Regex]*cacheEntry),
+	}
+}
+
+// Get returns a compiled regex for the given pattern, options and syntax,
+// compiling and caching it if it is not already present.
+//
+// Compilation errors are returned as for NewRegex, and are not cached.
+func (c *Cache) Get(pattern string, options CompileOptions, syntax Syntax) (*Regex, error) {
+	return c.get(cacheKey{pattern, options, syntax}, false)
+}
+
+// Acquire is like Get except that the caller must call Release with the
+// result once it has finished using it, and must not use it after that, so
+// that the cache can free the regex once it has been evicted.
+//
+// If the same regex is also returned by Get then it is not freed, as
+// described for Cache.
+func (c *Cache) Acquire(pattern string, options CompileOptions, syntax Syntax) (*Regex, error) {
+	return c.get(cacheKey{pattern, options, syntax}, true)
+}
+
+// Release releases a regex returned by Acquire, freeing it if it has been
+// evicted and has no other users. Release panics if the regex was not
+// acquired from the receiver, or has been released as many times as it was
+// acquired.
+func (c *Cache) Release(r *Regex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.acquired[r]
+	if !ok {
+		panic("onig: Release of a regex that is not acquired from this cache")
+	}
+	e.refs--
+	if e.refs == 0 {
+		delete(c.acquired, r)
+		c.freeIfUnused(e)
+	}
+}
+
+func (c *Cache) get(key cacheKey, acquire bool) (*Regex, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.stats.Hits++
+		r := c.use(elem.Value.(*cacheEntry), acquire)
+		c.mu.Unlock()
+		return r, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// We compile without holding the lock so that a slow compile doesn't
+	// block requests for other patterns. If another goroutine compiles the
+	// same pattern concurrently then we keep whichever result arrives first
+	// and free the other, which nobody else has seen.
+	r, err := NewRegex(key.pattern, key.options, key.syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		regexFree(r)
+		c.lru.MoveToFront(elem)
+		return c.use(elem.Value.(*cacheEntry), acquire), nil
+	}
+	e := &cacheEntry{key: key, regex: r}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.capacity {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+	return c.use(e, acquire), nil
+}
+
+// use records a use of the given entry for get, returning its regex. The
+// caller must hold c.mu.
+func (c *Cache) use(e *cacheEntry, acquire bool) *Regex {
+	if acquire {
+		e.refs++
+		c.acquired[e.regex] = e
+	} else {
+		e.shared = true
+	}
+	return e.regex
+}
+
+// remove removes the given element from the cache, freeing its regex if
+// possible. The caller must hold c.mu.
+func (c *Cache) remove(elem *list.Element) {
+	e := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, e.key)
+	e.evicted = true
+	c.freeIfUnused(e)
+}
+
+// freeIfUnused frees the regex of the given entry if it has been evicted
+// and nothing else can be using it. The caller must hold c.mu.
+func (c *Cache) freeIfUnused(e *cacheEntry) {
+	if e.evicted && !e.shared && e.refs == 0 {
+		regexFree(e.regex)
+	}
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := c.stats
+	ret.Len = c.lru.Len()
+	return ret
+}
+
+// Purge removes all entries from the cache, freeing their native memory as
+// for eviction. The counters returned by Stats are not reset.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// MatchString compiles the given pattern using SyntaxRuby and then tests
+// whether it matches a prefix of the given string, as with Regex.Matches.
+// Note that this differs from regexp.MatchString, which finds a match
+// anywhere in the string. Use SearchString for that behavior.
+//
+// Compiled patterns are kept in a shared cache, so repeated calls with the
+// same pattern do not recompile it.
+func MatchString(pattern, s string) (bool, error) {
+	r, err := defaultCache.Acquire(pattern, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		return false, err
+	}
+	defer defaultCache.Release(r)
+	return r.Matches(s, NoMatchOpts), nil
+}
+
+// SearchString is like MatchString except that it tests whether the pattern
+// matches any substring of the given string.
+func SearchString(pattern, s string) (bool, error) {
+	r, err := defaultCache.Acquire(pattern, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		return false, err
+	}
+	defer defaultCache.Release(r)
+	return regexSearch(r, s, 0, NoMatchOpts, false, nil), nil
+}
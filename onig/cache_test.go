@@ -0,0 +1,244 @@
+package onig
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCacheGet(t *testing.T) {
+	c := NewCache(2)
+
+	a1, err := c.Get(`a+`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, err := c.Get(`a+`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a1 != a2 {
+		t.Errorf("second Get for same key returned a different regex")
+	}
+
+	// Each element of the key must be considered separately.
+	b, err := c.Get(`a+`, OptIgnoreCase, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == a1 {
+		t.Errorf("Get with different options returned the same regex")
+	}
+
+	got := c.Stats()
+	want := CacheStats{Hits: 1, Misses: 2, Len: 2}
+	if got != want {
+		t.Errorf("wrong stats\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := NewCache(2)
+	get := func(pattern string) *Regex {
+		t.Helper()
+		r, err := c.Get(pattern, NoCompileOpts, SyntaxRuby)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	a := get(`a`)
+	get(`b`)
+	get(`a`) // a is now more recently used than b
+	get(`c`) // so this should evict b
+
+	if got := get(`a`); got != a {
+		t.Errorf("a was evicted, but should have been retained")
+	}
+	statsBefore := c.Stats()
+	get(`b`)
+	statsAfter := c.Stats()
+	if statsAfter.Misses != statsBefore.Misses+1 {
+		t.Errorf("b was retained, but should have been evicted")
+	}
+
+	got := c.Stats()
+	want := CacheStats{Hits: 2, Misses: 4, Evictions: 2, Len: 2}
+	if got != want {
+		t.Errorf("wrong stats\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	c.Purge()
+	if got := c.Stats().Len; got != 0 {
+		t.Errorf("wrong Len after Purge %d; want 0", got)
+	}
+}
+
+func TestCacheAcquire(t *testing.T) {
+	c := NewCache(1)
+	acquire := func(pattern string) *Regex {
+		t.Helper()
+		r, err := c.Acquire(pattern, NoCompileOpts, SyntaxRuby)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	// A regex that has been freed is left uncompiled.
+	freed := func(r *Regex) bool {
+		return r.syntax == 0
+	}
+
+	a := acquire(`a`)
+	c.Release(a)
+	b := acquire(`b`)
+	if !freed(a) {
+		t.Errorf("a was not freed when it was evicted")
+	}
+
+	// A regex that is still acquired is only freed once it is released.
+	acquire(`c`)
+	if freed(b) || !b.Matches("b", NoMatchOpts) {
+		t.Errorf("b was freed while still acquired")
+	}
+	c.Release(b)
+	if !freed(b) {
+		t.Errorf("b was not freed when it was released")
+	}
+
+	// A regex that has been returned by Get is never freed.
+	d, err := c.Get(`d`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := acquire(`d`); got != d {
+		t.Errorf("Acquire returned a different regex from Get")
+	}
+	c.Release(d)
+	c.Purge()
+	if freed(d) || !d.Matches("d", NoMatchOpts) {
+		t.Errorf("d was freed after being returned by Get")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("no panic for Release of a regex that is not acquired")
+		}
+	}()
+	c.Release(d)
+}
+
+func TestCacheGetError(t *testing.T) {
+	c := NewCache(2)
+	_, err := c.Get(`[]`, NoCompileOpts, SyntaxRuby)
+	if err == nil {
+		t.Fatal("no error for invalid pattern")
+	}
+
+	got := c.Stats()
+	want := CacheStats{Misses: 1}
+	if got != want {
+		t.Errorf("wrong stats\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := NewCache(4)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				pattern := fmt.Sprintf("x{%d}", (g+i)%6)
+				if g%2 == 1 {
+					// Other goroutines are evicting entries all the time,
+					// so an acquired regex must remain usable until it is
+					// released.
+					r, err := c.Acquire(pattern, NoCompileOpts, SyntaxRuby)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					ok := r.Matches("xxxxxx", NoMatchOpts)
+					c.Release(r)
+					if !ok {
+						t.Errorf("%s does not match", pattern)
+						return
+					}
+					continue
+				}
+				r, err := c.Get(pattern, NoCompileOpts, SyntaxRuby)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !r.Matches("xxxxxx", NoMatchOpts) {
+					t.Errorf("%s does not match", pattern)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := c.Stats()
+	if stats.Hits+stats.Misses != 800 {
+		t.Errorf("wrong total requests %d; want 800", stats.Hits+stats.Misses)
+	}
+	if stats.Len > 4 {
+		t.Errorf("cache has grown to %d entries; want at most 4", stats.Len)
+	}
+}
+
+func TestMatchString(t *testing.T) {
+	tests := []struct {
+		Pattern    string
+		Str        string
+		WantMatch  bool
+		WantSearch bool
+	}{
+		{`hello`, `hello world`, true, true},
+		{`world`, `hello world`, false, true},
+		{`goodbye`, `hello world`, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q", test.Pattern, test.Str), func(t *testing.T) {
+			got, err := MatchString(test.Pattern, test.Str)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.WantMatch {
+				t.Errorf("wrong MatchString result %#v; want %#v", got, test.WantMatch)
+			}
+
+			got, err = SearchString(test.Pattern, test.Str)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.WantSearch {
+				t.Errorf("wrong SearchString result %#v; want %#v", got, test.WantSearch)
+			}
+		})
+	}
+
+	if _, err := MatchString(`[]`, ``); err == nil {
+		t.Errorf("no error for invalid pattern")
+	}
+}
+
+func TestMustNewRegex(t *testing.T) {
+	r := MustNewRegex(`he(l*)o`, NoCompileOpts, SyntaxRuby)
+	if !r.Matches(`hello`, NoMatchOpts) {
+		t.Errorf("regex does not match")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("no panic for invalid pattern")
+		}
+	}()
+	MustNewRegex(`[]`, NoCompileOpts, SyntaxRuby)
+}
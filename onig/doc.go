@@ -5,4 +5,9 @@
 //
 // Since Go strings are conventionally UTF-8, this library initializes
 // Oniguruma only with UTF-8 support.
+//
+// A compiled Regex is safe to share between goroutines, so that a pattern
+// can be compiled once and then used from many concurrent callers. Each
+// search or match allocates its own working state, except for methods like
+// Regex.SearchInto which write into a caller-provided Match.
 package onig
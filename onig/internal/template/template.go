@@ -0,0 +1,89 @@
+// Package template expands the replacement templates used by the Expand
+// method of a regexp.Regexp from the Go standard library, for the packages
+// that accept the same templates.
+package template
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Expand appends template to dst with each variable in it replaced,
+// using the same syntax as the Expand method of a regexp.Regexp from the Go
+// standard library: "$1" or "${1}" stands for a numbered capture, "$name"
+// or "${name}" for a named capture, and "$$" for a literal "$". A "$" that
+// does not begin a valid variable is copied literally.
+//
+// For each variable, ref is called to append its value to dst. num is the
+// number of the capture, or -1 if the name is not a number.
+//
+// As in package regexp, a name is taken to be as long as possible, so
+// "$1x" refers to a capture named "1x" rather than to capture 1 followed by
+// "x". Use "${1}x" for the latter.
+func Expand(dst []byte, template string, ref func(dst []byte, name string, num int) []byte) []byte {
+	for {
+		before, after, ok := strings.Cut(template, "$")
+		if !ok {
+			break
+		}
+		dst = append(dst, before...)
+		template = after
+		if strings.HasPrefix(template, "$") {
+			dst = append(dst, '$')
+			template = template[1:]
+			continue
+		}
+		name, num, rest, ok := extractName(template)
+		if !ok {
+			// Malformed, so the "$" is literal.
+			dst = append(dst, '$')
+			continue
+		}
+		template = rest
+		dst = ref(dst, name, num)
+	}
+	return append(dst, template...)
+}
+
+// extractName returns the name from a leading "name" or "{name}" in
+// str, for Expand. If the name is a number, num is that number, or
+// otherwise -1.
+func extractName(str string) (name string, num int, rest string, ok bool) {
+	brace := strings.HasPrefix(str, "{")
+	if brace {
+		str = str[1:]
+	}
+	i := 0
+	for i < len(str) {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		return "", 0, "", false
+	}
+	name = str[:i]
+	if brace {
+		if i >= len(str) || str[i] != '}' {
+			return "", 0, "", false
+		}
+		i++
+	}
+
+	num = 0
+	for j := 0; j < len(name); j++ {
+		if name[j] < '0' || '9' < name[j] || num >= 1e8 {
+			num = -1
+			break
+		}
+		num = num*10 + int(name[j]) - '0'
+	}
+	// Leading zeros are not allowed in a number.
+	if name[0] == '0' && len(name) > 1 {
+		num = -1
+	}
+	return name, num, str[i:], true
+}
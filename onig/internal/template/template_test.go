@@ -0,0 +1,33 @@
+package template
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		Template string
+		Want     string
+	}{
+		{`a$1 b`, `a<1:1> b`},
+		{`a${1}b`, `a<1:1>b`},
+		{`$1x`, `<1x:-1>`},
+		{`${name}$név`, `<name:-1><név:-1>`},
+		{`$$1`, `$1`},
+		{`$01 ${10}`, `<01:-1> <10:10>`},
+		{`$ ${ ${x $}`, `$ ${ ${x $}`},
+		{`$`, `$`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Template, func(t *testing.T) {
+			got := Expand([]byte("!"), test.Template, func(dst []byte, name string, num int) []byte {
+				return fmt.Appendf(dst, "<%s:%d>", name, num)
+			})
+			if want := "!" + test.Want; string(got) != want {
+				t.Errorf("wrong result %q; want %q", got, want)
+			}
+		})
+	}
+}
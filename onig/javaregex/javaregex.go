@@ -0,0 +1,203 @@
+// Package javaregex provides Pattern and Matcher types with the semantics
+// of Java's java.util.regex package, for code ported from Java.
+//
+// Patterns are compiled using Syntax, which is onig.SyntaxJava with named
+// groups and backreferences enabled, as in Java 7 and later. A Matcher
+// keeps the same state as its Java counterpart: a region of the input to
+// search, the position to continue from, the current match and the append
+// position used by AppendReplacement. The bounds of the region are always
+// opaque and anchoring, as they are by default in Java, so lookaround
+// cannot see beyond the region and "^" and "$" match at its edges.
+//
+// There are some differences in behavior from Java:
+//
+//   - All offsets are byte offsets into the UTF-8 text, rather than counts
+//     of UTF-16 code units.
+//   - "\w", "\d", "\s", "\b" and case-insensitive matching include
+//     non-ASCII characters, as with Java's UNICODE_CHARACTER_CLASS and
+//     UNICODE_CASE flags.
+//   - Only "\n" is a line terminator, as with Java's UNIX_LINES flag, and
+//     the escapes "\h", "\R" and "\X" are not supported.
+//   - HitEnd is an approximation, as described for that method.
+package javaregex
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// Syntax is the syntax used to compile patterns.
+var Syntax = onig.NewSyntax(
+	onig.SyntaxJava,
+	onig.OptSingleline,
+	onig.SynNamedGroups|onig.SynNamedBackrefs|onig.SynHexBrace,
+	onig.SynCaptureOnlyNamed|onig.SynDuplicateNames,
+)
+
+// Flag is a set of flags that modify how a pattern is compiled, which can
+// be combined using the | operator.
+type Flag uint
+
+const (
+	// CaseInsensitive performs case-insensitive matching.
+	CaseInsensitive Flag = 1 << iota
+
+	// Multiline makes "^" and "$" match at the start and end of each line,
+	// rather than only at the start and end of the input.
+	Multiline
+
+	// DotAll makes "." match any character, including a line terminator.
+	DotAll
+
+	// Comments allows whitespace and comments in the pattern.
+	Comments
+
+	// Literal makes the whole pattern match literally. Of the other flags,
+	// only CaseInsensitive has any effect with it.
+	Literal
+)
+
+// Pattern is a compiled regular expression, like a Java Pattern.
+//
+// A Pattern is safe for concurrent use by multiple goroutines, but the
+// Matchers created from it are not.
+type Pattern struct {
+	pattern string
+	flags   Flag
+	re      *onig.Regex
+	names   map[string]int
+
+	// full is re anchored at the end of the input, for Matcher.Matches.
+	full *onig.Regex
+}
+
+// Compile compiles a regular expression into a Pattern.
+func Compile(regex string, flags Flag) (*Pattern, error) {
+	opts := onig.OptCaptureGroup
+	if flags&CaseInsensitive != 0 {
+		opts |= onig.OptIgnoreCase
+	}
+	expr := regex
+	if flags&Literal != 0 {
+		expr = Quote(regex)
+	} else {
+		if flags&Multiline != 0 {
+			opts |= onig.OptNegateSingleline
+		}
+		if flags&DotAll != 0 {
+			opts |= onig.OptMultiline
+		}
+		if flags&Comments != 0 {
+			opts |= onig.OptExtend
+		}
+	}
+
+	re, err := onig.NewRegex(expr, opts, Syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pattern might end inside a quote, which Java allows to be left
+	// open, or with Comments inside a comment, so either must be closed
+	// before the suffix.
+	if inQuote(expr, opts&onig.OptExtend != 0) {
+		expr += `\E`
+	}
+	if opts&onig.OptExtend != 0 {
+		expr += "\n"
+	}
+	full, err := onig.NewRegex(`(?:`+expr+`)\z`, opts, Syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pattern{
+		pattern: regex,
+		flags:   flags,
+		re:      re,
+		names:   re.NamedCapturesFirst(),
+		full:    full,
+	}, nil
+}
+
+// inQuote returns true if the given pattern ends inside a "\Q" quote that
+// has no closing "\E". If comments is true then a "#" outside a quote
+// begins a comment that extends to the end of the line.
+func inQuote(expr string, comments bool) bool {
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case strings.HasPrefix(expr[i:], `\Q`):
+			end := strings.Index(expr[i+2:], `\E`)
+			if end < 0 {
+				return true
+			}
+			i += 2 + end + 1
+		case expr[i] == '\\':
+			i++
+		case comments && expr[i] == '#':
+			end := strings.IndexByte(expr[i:], '\n')
+			if end < 0 {
+				return false
+			}
+			i += end
+		}
+	}
+	return false
+}
+
+// MustCompile is like Compile but panics if the regular expression cannot
+// be compiled.
+func MustCompile(regex string, flags Flag) *Pattern {
+	p, err := Compile(regex, flags)
+	if err != nil {
+		panic(fmt.Sprintf("javaregex: Compile(%q): %s", regex, err))
+	}
+	return p
+}
+
+// Quote returns a pattern that matches the given string literally, as
+// Java's Pattern.quote does.
+func Quote(s string) string {
+	var buf strings.Builder
+	buf.WriteString(`\Q`)
+	for {
+		before, after, found := strings.Cut(s, `\E`)
+		buf.WriteString(before)
+		if !found {
+			break
+		}
+		buf.WriteString(`\E\\E\Q`)
+		s = after
+	}
+	buf.WriteString(`\E`)
+	return buf.String()
+}
+
+// Pattern returns the source text of the pattern.
+func (p *Pattern) Pattern() string {
+	return p.pattern
+}
+
+// String returns the source text of the pattern.
+func (p *Pattern) String() string {
+	return p.pattern
+}
+
+// Flags returns the flags the pattern was compiled with.
+func (p *Pattern) Flags() Flag {
+	return p.flags
+}
+
+// Regex returns the underlying compiled regex.
+func (p *Pattern) Regex() *onig.Regex {
+	return p.re
+}
+
+// Matcher returns a new Matcher for the given input.
+func (p *Pattern) Matcher(input string) *Matcher {
+	m := &Matcher{pattern: p, input: input}
+	m.Reset()
+	return m
+}
@@ -0,0 +1,278 @@
+package javaregex
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The expected results in these tests follow the behavior of Java 17.
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		Regex string
+		Flags Flag
+		Input string
+		Want  []string // the start and end of each match, as "start-end"
+	}{
+		{`\d+`, 0, "a1b22c333", []string{"1-2", "3-5", "6-9"}},
+		{`x*`, 0, "abxd", []string{"0-0", "1-1", "2-3", "3-3", "4-4"}},
+		{`x*`, 0, "éx", []string{"0-0", "2-3", "3-3"}},
+		{`a$`, 0, "a\n", []string{"0-1"}},
+		{`^b`, 0, "a\nb", nil},
+		{`^b`, Multiline, "a\nb", []string{"2-3"}},
+		{`a.b`, 0, "a\nb", nil},
+		{`a.b`, DotAll, "a\nb", []string{"0-3"}},
+		{`abc`, CaseInsensitive, "xABC", []string{"1-4"}},
+		{`a b # comment`, Comments, "ab", []string{"0-2"}},
+		{`a.b`, Literal, "axb a.b", []string{"4-7"}},
+		{`a.B`, Literal | CaseInsensitive, "A.b", []string{"0-3"}},
+		{`[a-z&&[^aeiou]]+`, 0, "abcde", []string{"1-4"}},
+		{`\Qa.b`, 0, "axb a.b", []string{"4-7"}},
+		{`a\Q)`, 0, "a) a)", []string{"0-2", "3-5"}},
+		{`a\Q#b`, Comments, "a#b", []string{"0-3"}},
+		{"a # \\Q\nb", Comments, "ab", []string{"0-2"}},
+		{`\\Qa`, 0, `\Qa`, []string{"0-3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s with %d in %q", test.Regex, test.Flags, test.Input), func(t *testing.T) {
+			m := MustCompile(test.Regex, test.Flags).Matcher(test.Input)
+			var got []string
+			for m.Find() {
+				got = append(got, fmt.Sprintf("%d-%d", m.Start(0), m.End(0)))
+			}
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf("wrong matches %q; want %q", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestMatchesLookingAt(t *testing.T) {
+	m := MustCompile(`a|ab`, 0).Matcher("ab")
+	if !m.Matches() || m.End(0) != 2 {
+		t.Errorf("Matches should match the whole input")
+	}
+	if !m.LookingAt() || m.End(0) != 1 {
+		t.Errorf("LookingAt should match a prefix")
+	}
+	// Find continues after the match from LookingAt.
+	if m.Find() {
+		t.Errorf("unexpected match at %d", m.Start(0))
+	}
+
+	m = MustCompile(`b`, 0).Matcher("ab")
+	if m.LookingAt() || m.Matches() {
+		t.Errorf("unexpected match that is not at the start of the input")
+	}
+
+	// A quote without "\E" extends to the end of the pattern.
+	for _, regex := range []string{`\Qa.b`, `a\Q)`} {
+		p, err := Compile(regex, 0)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", regex, err)
+		}
+		if text := strings.ReplaceAll(regex, `\Q`, ""); !p.Matcher(text).Matches() {
+			t.Errorf("%s does not match %q", regex, text)
+		}
+	}
+}
+
+func TestRegion(t *testing.T) {
+	m := MustCompile(`^\w+$`, 0).Matcher("a bcd e")
+	m.Region(2, 5)
+	if !m.Matches() {
+		t.Fatalf("no match in region")
+	}
+	if got, want := []int{m.Start(0), m.End(0)}, []int{2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong bounds %v; want %v", got, want)
+	}
+	if got, _ := m.Group(0); got != "bcd" {
+		t.Errorf("wrong group %q", got)
+	}
+
+	// Lookbehind cannot see before the region.
+	m.UsePattern(MustCompile(`(?<= )b`, 0)).Region(2, 5)
+	if m.Find() {
+		t.Errorf("lookbehind saw text before the region")
+	}
+	if m.RegionStart() != 2 || m.RegionEnd() != 5 {
+		t.Errorf("wrong region %d-%d", m.RegionStart(), m.RegionEnd())
+	}
+
+	// FindFrom resets the region.
+	m.UsePattern(MustCompile(`e`, 0))
+	if !m.FindFrom(0) || m.Start(0) != 6 {
+		t.Errorf("FindFrom did not search the whole input")
+	}
+}
+
+func TestUsePattern(t *testing.T) {
+	m := MustCompile(`[a-z]`, 0).Matcher("a1")
+	if !m.Find() {
+		t.Fatalf("no match")
+	}
+	m.UsePattern(MustCompile(`\d`, 0))
+	if _, ok := m.Group(0); ok {
+		t.Errorf("groups were not lost")
+	}
+	if !m.Find() || m.Start(0) != 1 {
+		t.Errorf("position was not kept")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	m := MustCompile(`(?<year>\d{4})-(?<mon>\d\d)(x)?`, 0).Matcher("on 2024-05")
+	if !m.Find() {
+		t.Fatalf("no match")
+	}
+	if got, want := m.GroupCount(), 3; got != want {
+		t.Errorf("wrong GroupCount %d; want %d", got, want)
+	}
+	if got, ok := m.GroupNamed("year"); !ok || got != "2024" {
+		t.Errorf("wrong year %q, %t", got, ok)
+	}
+	if got, want := []int{m.StartNamed("mon"), m.EndNamed("mon")}, []int{8, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong bounds of mon %v; want %v", got, want)
+	}
+	if _, ok := m.Group(3); ok || m.Start(3) != -1 {
+		t.Errorf("group 3 should not have participated")
+	}
+
+	// The last of these resets the matcher.
+	panics := []struct {
+		Want string
+		Fn   func()
+	}{
+		{"no group 4", func() { m.Group(4) }},
+		{"no group with name <z>", func() { m.GroupNamed("z") }},
+		{"start index 11 out of range", func() { m.FindFrom(11) }},
+		{"no match available", func() { m.Reset().Start(0) }},
+	}
+	for _, test := range panics {
+		t.Run(test.Want, func(t *testing.T) {
+			defer func() {
+				if got, want := fmt.Sprint(recover()), "javaregex: "+test.Want; got != want {
+					t.Errorf("wrong panic %q; want %q", got, want)
+				}
+			}()
+			test.Fn()
+		})
+	}
+}
+
+func TestHitEnd(t *testing.T) {
+	tests := []struct {
+		Regex string
+		Input string
+		Want  bool
+	}{
+		{`\d+`, "123", true},
+		{`\d+`, "12a", false},
+		{`x`, "abc", true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s in %q", test.Regex, test.Input), func(t *testing.T) {
+			m := MustCompile(test.Regex, 0).Matcher(test.Input)
+			m.Find()
+			if got := m.HitEnd(); got != test.Want {
+				t.Errorf("wrong result %t; want %t", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	tests := []struct {
+		Regex       string
+		Input       string
+		Replacement string
+		Want        string
+	}{
+		{`cat`, "one cat two cats in the yard", `dog`, "one dog two dogs in the yard"},
+		{`(\w+)@(\w+)`, "x a@b y", `$2 at $1`, "x b at a y"},
+		{`(?<user>\w+)@`, "a@b", `${user}:`, "a:b"},
+		{`(a)`, "a", `$10\$\\\x`, `a0$\x`},
+		{`(a)(b)?`, "a", `[$2]`, "[]"},
+		{`x*`, "ab", `-`, "-a-b-"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s with %q in %q", test.Regex, test.Replacement, test.Input), func(t *testing.T) {
+			got, err := MustCompile(test.Regex, 0).Matcher(test.Input).ReplaceAll(test.Replacement)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.Want {
+				t.Errorf("wrong result %q; want %q", got, test.Want)
+			}
+		})
+	}
+
+	got, err := MustCompile(`o`, 0).Matcher("foo").ReplaceFirst(`0`)
+	if err != nil || got != "f0o" {
+		t.Errorf("wrong ReplaceFirst result %q, %v", got, err)
+	}
+}
+
+func TestAppendReplacement(t *testing.T) {
+	m := MustCompile(`\d`, 0).Matcher("a1b2c")
+	var buf strings.Builder
+	if err := m.AppendReplacement(&buf, "x"); err == nil || err.Error() != "no match available" {
+		t.Errorf("wrong error without a match: %v", err)
+	}
+	for m.Find() {
+		digit, _ := m.Group(0)
+		if err := m.AppendReplacement(&buf, "<"+digit+digit+">"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	m.AppendTail(&buf)
+	if got, want := buf.String(), "a<11>b<22>c"; got != want {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+}
+
+func TestReplacementErrors(t *testing.T) {
+	tests := []struct {
+		Replacement string
+		Want        string
+	}{
+		{`\`, `character to be escaped is missing`},
+		{`$`, `illegal group reference: group index is missing`},
+		{`$x`, `illegal group reference`},
+		{`$2`, `no group 2`},
+		{`${}`, `named capturing group has 0 length name`},
+		{`${n`, `named capturing group is missing trailing '}'`},
+		{`${n-}`, `named capturing group is missing trailing '}'`},
+		{`${1n}`, `capturing group name {1n} starts with digit character`},
+		{`${z}`, `no group with name {z}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Replacement, func(t *testing.T) {
+			_, err := MustCompile(`(?<n>a)`, 0).Matcher("a").ReplaceAll(test.Replacement)
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if err.Error() != test.Want {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", err, test.Want)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got, want := Quote(`a\Eb.`), `\Qa\E\\E\Qb.\E`; got != want {
+		t.Errorf("wrong Quote result %q; want %q", got, want)
+	}
+	if !MustCompile(Quote(`a\Eb.`), 0).Matcher(`a\Eb.`).Matches() {
+		t.Errorf("quoted pattern does not match its text")
+	}
+	if got, want := QuoteReplacement(`$1\`), `\$1\\`; got != want {
+		t.Errorf("wrong QuoteReplacement result %q; want %q", got, want)
+	}
+}
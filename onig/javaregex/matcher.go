@@ -0,0 +1,259 @@
+package javaregex
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// Matcher performs match operations on an input string using a Pattern,
+// like a Java Matcher.
+//
+// The methods that report on the current match, such as Start, End and
+// Group, panic if there is no current match or the given group does not
+// exist, where Java would throw an exception.
+//
+// A Matcher is not safe for concurrent use by multiple goroutines.
+type Matcher struct {
+	pattern *Pattern
+	input   string
+
+	// from and to are the bounds of the region.
+	from, to int
+
+	// m is the current match, with spans relative to the start of the
+	// region, or nil if there is no current match or its groups were lost
+	// by UsePattern.
+	m *onig.Match
+
+	// first and last are the bounds of the current match. first is -1 if
+	// there is no current match, and last is where Find continues.
+	first, last int
+
+	appendPos int
+	hitEnd    bool
+}
+
+// Pattern returns the pattern that the receiver uses.
+func (m *Matcher) Pattern() *Pattern {
+	return m.pattern
+}
+
+// UsePattern changes the pattern that the receiver uses, keeping its
+// position in the input and its append position but losing the groups of
+// the current match. It returns the receiver.
+func (m *Matcher) UsePattern(p *Pattern) *Matcher {
+	if p == nil {
+		panic("javaregex: UsePattern with nil pattern")
+	}
+	m.pattern = p
+	m.m = nil
+	return m
+}
+
+// Reset discards the receiver's state, including its region, so that the
+// next Find searches from the start of the input. It returns the receiver.
+func (m *Matcher) Reset() *Matcher {
+	m.from, m.to = 0, len(m.input)
+	m.m = nil
+	m.first, m.last = -1, 0
+	m.appendPos = 0
+	m.hitEnd = false
+	return m
+}
+
+// ResetInput is like Reset but also changes the input.
+func (m *Matcher) ResetInput(input string) *Matcher {
+	m.input = input
+	return m.Reset()
+}
+
+// Region resets the receiver and limits its match operations to the
+// given byte offsets of the input. It returns the receiver.
+//
+// Region panics if the offsets are out of range or start is greater than
+// end.
+func (m *Matcher) Region(start, end int) *Matcher {
+	if start < 0 || start > len(m.input) {
+		panic(fmt.Sprintf("javaregex: region start %d out of range", start))
+	}
+	if end < start || end > len(m.input) {
+		panic(fmt.Sprintf("javaregex: region end %d out of range", end))
+	}
+	m.Reset()
+	m.from, m.to = start, end
+	return m
+}
+
+// RegionStart returns the start of the receiver's region.
+func (m *Matcher) RegionStart() int {
+	return m.from
+}
+
+// RegionEnd returns the end of the receiver's region.
+func (m *Matcher) RegionEnd() int {
+	return m.to
+}
+
+// Find searches for the next match in the region, starting at the end of
+// the current match, or after it if it was empty. It reports whether a
+// match was found.
+func (m *Matcher) Find() bool {
+	next := m.last
+	if next == m.first {
+		// An empty match would otherwise be found again.
+		_, size := utf8.DecodeRuneInString(m.input[next:])
+		next += max(size, 1)
+	}
+	if next < m.from {
+		next = m.from
+	}
+	if next > m.to {
+		m.m = nil
+		m.first = -1
+		return false
+	}
+	return m.search(next)
+}
+
+// FindFrom resets the receiver and then searches for a match beginning at
+// the given byte offset. It reports whether a match was found.
+//
+// FindFrom panics if start is negative or greater than the length of the
+// input.
+func (m *Matcher) FindFrom(start int) bool {
+	if start < 0 || start > len(m.input) {
+		panic(fmt.Sprintf("javaregex: start index %d out of range", start))
+	}
+	m.Reset()
+	return m.search(start)
+}
+
+// LookingAt reports whether the pattern matches a prefix of the region.
+func (m *Matcher) LookingAt() bool {
+	return m.match(m.pattern.re)
+}
+
+// Matches reports whether the pattern matches the whole region.
+func (m *Matcher) Matches() bool {
+	return m.match(m.pattern.full)
+}
+
+func (m *Matcher) search(start int) bool {
+	m.setMatch(m.pattern.re.SearchFrom(m.region(), start-m.from, onig.NoMatchOpts))
+	return m.m != nil
+}
+
+func (m *Matcher) match(re *onig.Regex) bool {
+	m.setMatch(re.MatchAt(m.region(), 0, onig.NoMatchOpts))
+	return m.m != nil
+}
+
+func (m *Matcher) region() string {
+	return m.input[m.from:m.to]
+}
+
+func (m *Matcher) setMatch(match *onig.Match) {
+	m.m = match
+	if match == nil {
+		m.first = -1
+		m.hitEnd = true
+		return
+	}
+	bounds := match.Bounds()
+	m.first, m.last = m.from+bounds.Start, m.from+bounds.End
+	m.hitEnd = m.last == m.to
+}
+
+// HitEnd reports whether the end of the region may have affected the
+// result of the last match operation, in which case more input could have
+// changed it.
+//
+// Oniguruma does not report whether a match read the end of the input, so
+// the result is an approximation: it is true if the last match operation
+// failed or its match ended at the end of the region. This agrees with
+// Java in the common cases, but it differs for a literal that ends at the
+// end of the region, where Java's result is false, and for a match that
+// ends before the end of the region after an alternative or lookahead
+// assertion reached it, where Java's result is true.
+func (m *Matcher) HitEnd() bool {
+	return m.hitEnd
+}
+
+// GroupCount returns the number of capture groups in the receiver's
+// pattern.
+func (m *Matcher) GroupCount() int {
+	return m.pattern.re.CaptureCount()
+}
+
+// Start returns the byte offset of the start of the given group in the
+// current match, where zero is the whole match, or -1 if the group did not
+// participate in the match.
+func (m *Matcher) Start(group int) int {
+	span := m.span(group)
+	if span.Start < 0 {
+		return -1
+	}
+	return m.from + span.Start
+}
+
+// End returns the byte offset of the end of the given group in the current
+// match, where zero is the whole match, or -1 if the group did not
+// participate in the match.
+func (m *Matcher) End(group int) int {
+	span := m.span(group)
+	if span.Start < 0 {
+		return -1
+	}
+	return m.from + span.End
+}
+
+// Group returns the text of the given group in the current match, where
+// zero is the whole match. The result is false if the group did not
+// participate in the match.
+func (m *Matcher) Group(group int) (string, bool) {
+	span := m.span(group)
+	if span.Start < 0 {
+		return "", false
+	}
+	return span.Substr(m.region()), true
+}
+
+// StartNamed is like Start but for the group with the given name.
+func (m *Matcher) StartNamed(name string) int {
+	return m.Start(m.groupIndex(name))
+}
+
+// EndNamed is like End but for the group with the given name.
+func (m *Matcher) EndNamed(name string) int {
+	return m.End(m.groupIndex(name))
+}
+
+// GroupNamed is like Group but for the group with the given name.
+func (m *Matcher) GroupNamed(name string) (string, bool) {
+	return m.Group(m.groupIndex(name))
+}
+
+func (m *Matcher) groupIndex(name string) int {
+	idx, ok := m.pattern.names[name]
+	if !ok {
+		panic(fmt.Sprintf("javaregex: no group with name <%s>", name))
+	}
+	return idx
+}
+
+// span returns the span of the given group relative to the region, or
+// panics if there is no current match or no such group.
+func (m *Matcher) span(group int) onig.Span {
+	if m.first < 0 {
+		panic("javaregex: no match available")
+	}
+	if group < 0 || group > m.GroupCount() {
+		panic(fmt.Sprintf("javaregex: no group %d", group))
+	}
+	if m.m == nil {
+		return onig.Span{Start: -1, End: -1}
+	}
+	return m.m.Capture(group)
+}
@@ -0,0 +1,160 @@
+package javaregex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AppendReplacement writes the text of the input from the append position
+// up to the current match, followed by the given replacement, to buf, and
+// then moves the append position to the end of the match. It is used with
+// Find and AppendTail to build the result of a series of replacements.
+//
+// In the replacement, "$n" stands for the text of the numbered group, using
+// as many digits as form a valid group number, "${name}" for the named
+// group, and a backslash escapes the character that follows it. An error
+// is returned, and nothing is written, if the replacement is invalid or
+// there is no current match.
+func (m *Matcher) AppendReplacement(buf *strings.Builder, replacement string) error {
+	if m.first < 0 {
+		return errors.New("no match available")
+	}
+	text, err := m.expand(replacement)
+	if err != nil {
+		return err
+	}
+	buf.WriteString(m.input[m.appendPos:m.first])
+	buf.WriteString(text)
+	m.appendPos = m.last
+	return nil
+}
+
+// AppendTail writes the text of the input from the append position to the
+// end of the input to buf.
+func (m *Matcher) AppendTail(buf *strings.Builder) {
+	buf.WriteString(m.input[m.appendPos:])
+}
+
+// ReplaceAll resets the receiver and returns a copy of its input with
+// every match replaced by the given replacement, as for AppendReplacement.
+func (m *Matcher) ReplaceAll(replacement string) (string, error) {
+	return m.replace(replacement, true)
+}
+
+// ReplaceFirst is like ReplaceAll but replaces only the first match.
+func (m *Matcher) ReplaceFirst(replacement string) (string, error) {
+	return m.replace(replacement, false)
+}
+
+func (m *Matcher) replace(replacement string, all bool) (string, error) {
+	m.Reset()
+	var buf strings.Builder
+	for m.Find() {
+		if err := m.AppendReplacement(&buf, replacement); err != nil {
+			return "", err
+		}
+		if !all {
+			break
+		}
+	}
+	m.AppendTail(&buf)
+	return buf.String(), nil
+}
+
+// QuoteReplacement returns a replacement that produces the given string
+// literally, for use with AppendReplacement.
+func QuoteReplacement(s string) string {
+	if !strings.ContainsAny(s, `\$`) {
+		return s
+	}
+	var buf strings.Builder
+	for _, r := range s {
+		if r == '\\' || r == '$' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// expand returns the given replacement with its group references
+// substituted for the current match, as described for AppendReplacement.
+func (m *Matcher) expand(replacement string) (string, error) {
+	var buf strings.Builder
+	for i := 0; i < len(replacement); {
+		c := replacement[i]
+		i++
+		switch c {
+		case '\\':
+			if i == len(replacement) {
+				return "", errors.New("character to be escaped is missing")
+			}
+			buf.WriteByte(replacement[i])
+			i++
+			continue
+		case '$':
+		default:
+			buf.WriteByte(c)
+			continue
+		}
+
+		if i == len(replacement) {
+			return "", errors.New("illegal group reference: group index is missing")
+		}
+		var group int
+		if replacement[i] == '{' {
+			i++
+			start := i
+			for i < len(replacement) && isASCIIAlnum(replacement[i]) {
+				i++
+			}
+			name := replacement[start:i]
+			switch {
+			case name == "":
+				return "", errors.New("named capturing group has 0 length name")
+			case i == len(replacement) || replacement[i] != '}':
+				return "", errors.New("named capturing group is missing trailing '}'")
+			case isDigit(name[0]):
+				return "", fmt.Errorf("capturing group name {%s} starts with digit character", name)
+			}
+			idx, ok := m.pattern.names[name]
+			if !ok {
+				return "", fmt.Errorf("no group with name {%s}", name)
+			}
+			group = idx
+			i++
+		} else {
+			if !isDigit(replacement[i]) {
+				return "", errors.New("illegal group reference")
+			}
+			// Further digits are used only while the group number remains
+			// valid, as in Java.
+			group = int(replacement[i] - '0')
+			i++
+			for i < len(replacement) && isDigit(replacement[i]) {
+				next := group*10 + int(replacement[i]-'0')
+				if next > m.GroupCount() {
+					break
+				}
+				group = next
+				i++
+			}
+			if group > m.GroupCount() {
+				return "", fmt.Errorf("no group %d", group)
+			}
+		}
+		if text, ok := m.Group(group); ok {
+			buf.WriteString(text)
+		}
+	}
+	return buf.String(), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isASCIIAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
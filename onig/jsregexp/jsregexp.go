@@ -0,0 +1,626 @@
+// Package jsregexp provides a JSRegExp type with the semantics of
+// JavaScript's RegExp objects, implemented using Oniguruma, for use by
+// JavaScript interpreters and other code that must match as ECMAScript
+// does.
+//
+// Patterns are compiled using Syntax, a custom syntax that disables the
+// constructs that Oniguruma's Perl syntax offers but ECMAScript does not,
+// after translating the constructs whose meanings differ, such as ".", "^",
+// "$", "\d", "\w", "\s" and "\b", into equivalents with ECMAScript's
+// meanings.
+//
+// JavaScript strings are sequences of UTF-16 code units, while the strings
+// given to this package are UTF-8. Offsets are therefore reported both as
+// byte offsets, through onig.Match, and in UTF-16 code units, which is also
+// the unit of LastIndex.
+//
+// There are some differences in behavior from JavaScript:
+//
+//   - Matching is always in terms of code points, as with the "u" flag, so
+//     a pattern cannot match half of a surrogate pair. An offset in UTF-16
+//     code units that falls inside a surrogate pair is treated as the
+//     offset after it.
+//   - A backreference to a group that has not participated in the match
+//     fails to match, rather than matching the empty string, and groups
+//     inside a repetition are not reset on each iteration.
+//   - Lookbehind assertions are limited to the forms that Oniguruma
+//     supports, and Oniguruma's atomic groups "(?>...)" are accepted.
+//   - Case-insensitive matching uses Unicode case folding even without the
+//     "u" flag.
+//   - The "v" flag is not supported.
+package jsregexp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// Syntax is the ECMAScript-flavoured syntax that patterns are compiled
+// with once the constructs that differ in meaning have been translated.
+var Syntax = onig.NewSyntax(
+	onig.SyntaxPerlNG,
+	onig.OptSingleline,
+	onig.SynNamedGroups|onig.SynNamedBackrefs|onig.SynUnicodeEscape|
+		onig.SynVerticalTab|onig.SynCharProperties,
+	onig.SynBufAnchors|onig.SynSearchStartAnchor|onig.SynWordBeginEnd|
+		onig.SynPosixBracket|onig.SynHexBrace|onig.SynQuote|
+		onig.SynPerlOptions|onig.SynRubyOptions|onig.SynPossessive|
+		onig.SynPossessiveInterval|onig.SynClassSetOps|onig.SynSubexpCalls|
+		onig.SynHexDigitClass|onig.SynIntervalLowAbbrev|
+		onig.SynCaptureOnlyNamed|onig.SynDuplicateNames,
+)
+
+// flagOrder is the order in which JavaScript lists the supported flags.
+const flagOrder = "dgimsuy"
+
+// JSRegExp is a compiled regular expression with the semantics of a
+// JavaScript RegExp object.
+//
+// Because LastIndex is updated by Exec, Test and Replace, a JSRegExp that
+// uses the "g" or "y" flags is not safe for concurrent use.
+type JSRegExp struct {
+	// LastIndex is the offset in UTF-16 code units at which the next
+	// search begins, for a JSRegExp with the "g" or "y" flags.
+	LastIndex int
+
+	source string
+	flags  string
+	re     *onig.Regex
+	names  map[string]int
+}
+
+// New compiles a JavaScript regular expression with the given source and
+// flags, as for the RegExp constructor.
+func New(source, flags string) (*JSRegExp, error) {
+	for i := 0; i < len(flags); i++ {
+		if strings.IndexByte(flagOrder, flags[i]) < 0 || strings.IndexByte(flags[i+1:], flags[i]) >= 0 {
+			return nil, fmt.Errorf("invalid regular expression flags %q", flags)
+		}
+	}
+	ret := &JSRegExp{source: source}
+	for i := 0; i < len(flagOrder); i++ {
+		if strings.IndexByte(flags, flagOrder[i]) >= 0 {
+			ret.flags += flagOrder[i : i+1]
+		}
+	}
+
+	pattern, err := translate(source, ret.has('m'), ret.has('s'), ret.has('u'))
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: /%s/: %s", source, err)
+	}
+	opts := onig.NoCompileOpts
+	if ret.has('i') {
+		opts |= onig.OptIgnoreCase
+	}
+	ret.re, err = onig.NewRegex(pattern, opts, Syntax)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: /%s/: %s", source, err)
+	}
+	ret.names = ret.re.NamedCapturesFirst()
+	return ret, nil
+}
+
+// MustNew is like New but panics if the regular expression is invalid.
+func MustNew(source, flags string) *JSRegExp {
+	re, err := New(source, flags)
+	if err != nil {
+		panic(err.Error())
+	}
+	return re
+}
+
+func (r *JSRegExp) has(flag byte) bool {
+	return strings.IndexByte(r.flags, flag) >= 0
+}
+
+// Source returns the source text of the regular expression.
+func (r *JSRegExp) Source() string {
+	return r.source
+}
+
+// Flags returns the flags of the regular expression, in the order that
+// JavaScript lists them.
+func (r *JSRegExp) Flags() string {
+	return r.flags
+}
+
+// String returns the regular expression in the form of a JavaScript
+// regular expression literal.
+func (r *JSRegExp) String() string {
+	return "/" + r.source + "/" + r.flags
+}
+
+// Regex returns the underlying compiled regex, whose capture groups
+// correspond to those of the regular expression.
+func (r *JSRegExp) Regex() *onig.Regex {
+	return r.re
+}
+
+// Result is the result of a successful call to Exec.
+type Result struct {
+	// Input is the string that was searched.
+	Input string
+
+	// Match gives the bounds of the match and of its captures as byte
+	// offsets into Input.
+	Match *onig.Match
+
+	// Index is the offset of the start of the match in UTF-16 code units.
+	Index int
+
+	// Indices gives the bounds of the match and of each of its captures in
+	// UTF-16 code units, with Span{-1, -1} for groups that did not
+	// participate. It is populated only if the regular expression has the
+	// "d" flag.
+	Indices []onig.Span
+
+	names map[string]int
+}
+
+// Group returns the text of the capture group with the given index, where
+// zero is the whole match, or false if the group did not participate in the
+// match.
+func (r *Result) Group(index int) (string, bool) {
+	span := r.Match.Capture(index)
+	if span.Start < 0 {
+		return "", false
+	}
+	return span.Substr(r.Input), true
+}
+
+// NamedGroup returns the text of the capture group with the given name, or
+// false if there is no such group or it did not participate in the match.
+func (r *Result) NamedGroup(name string) (string, bool) {
+	idx, ok := r.names[name]
+	if !ok {
+		return "", false
+	}
+	return r.Group(idx)
+}
+
+// Exec searches the given string for a match, as JavaScript's
+// RegExp.prototype.exec does, returning nil if there is none.
+//
+// With the "g" or "y" flags the search begins at LastIndex, and LastIndex
+// is updated to the end of the match, or to zero if there is none. With
+// the "y" flag the match must begin exactly at LastIndex.
+func (r *JSRegExp) Exec(s string) *Result {
+	return r.exec(&utf16Counter{s: s})
+}
+
+// Test reports whether the given string contains a match, updating
+// LastIndex as for Exec.
+func (r *JSRegExp) Test(s string) bool {
+	return r.Exec(s) != nil
+}
+
+func (r *JSRegExp) exec(c *utf16Counter) *Result {
+	global, sticky := r.has('g'), r.has('y')
+	lastIndex := r.LastIndex
+	if !global && !sticky || lastIndex < 0 {
+		lastIndex = 0
+	}
+
+	var m *onig.Match
+	if start := c.bytes(lastIndex); start >= 0 {
+		if sticky {
+			m = r.re.MatchAt(c.s, start, onig.NoMatchOpts)
+		} else {
+			m = r.re.SearchFrom(c.s, start, onig.NoMatchOpts)
+		}
+	}
+	if m == nil {
+		if global || sticky {
+			r.LastIndex = 0
+		}
+		return nil
+	}
+
+	bounds := m.Bounds()
+	ret := &Result{
+		Input: c.s,
+		Match: m,
+		Index: c.units(bounds.Start),
+		names: r.names,
+	}
+	if r.has('d') {
+		ret.Indices = make([]onig.Span, m.CaptureCount()+1)
+		for i := range ret.Indices {
+			span := m.Capture(i)
+			if span.Start >= 0 {
+				span = onig.Span{Start: c.units(span.Start), End: c.units(span.End)}
+			}
+			ret.Indices[i] = span
+		}
+	}
+	if global || sticky {
+		r.LastIndex = c.units(bounds.End)
+	}
+	return ret
+}
+
+// utf16Counter converts between byte offsets in a UTF-8 string and offsets
+// in UTF-16 code units, remembering its position so that a series of
+// increasing offsets costs time proportional to the length of the string.
+type utf16Counter struct {
+	s string
+
+	// b and u are corresponding offsets in bytes and in UTF-16 code units.
+	b, u int
+}
+
+// step advances the counter by one character.
+func (c *utf16Counter) step() {
+	r, size := utf8.DecodeRuneInString(c.s[c.b:])
+	c.b += size
+	c.u++
+	if r >= 0x10000 {
+		c.u++
+	}
+}
+
+// units returns the offset in UTF-16 code units of the given byte offset,
+// which must be at the start of a character.
+func (c *utf16Counter) units(b int) int {
+	if b < c.b {
+		c.b, c.u = 0, 0
+	}
+	for c.b < b {
+		c.step()
+	}
+	return c.u
+}
+
+// bytes returns the byte offset of the given offset in UTF-16 code units,
+// or -1 if it is beyond the end of the string. An offset inside a surrogate
+// pair is treated as the offset after it.
+func (c *utf16Counter) bytes(u int) int {
+	if u < c.u {
+		c.b, c.u = 0, 0
+	}
+	for c.u < u && c.b < len(c.s) {
+		c.step()
+	}
+	if c.u < u {
+		return -1
+	}
+	return c.b
+}
+
+// Classes equivalent to ECMAScript's class escapes, as ranges of code
+// points.
+var (
+	digitRanges = [][2]rune{{'0', '9'}}
+	wordRanges  = [][2]rune{{'0', '9'}, {'A', 'Z'}, {'_', '_'}, {'a', 'z'}}
+	spaceRanges = [][2]rune{
+		{'\t', '\r'}, {' ', ' '}, {0xa0, 0xa0}, {0x1680, 0x1680},
+		{0x2000, 0x200a}, {0x2028, 0x2029}, {0x202f, 0x202f},
+		{0x205f, 0x205f}, {0x3000, 0x3000}, {0xfeff, 0xfeff},
+	}
+	lineTermRanges = [][2]rune{{'\n', '\n'}, {'\r', '\r'}, {0x2028, 0x2029}}
+)
+
+// classEscapes gives the contents of a character class equivalent to each
+// of ECMAScript's class escapes.
+var classEscapes = map[byte]string{
+	'd': classContent(digitRanges),
+	'D': classContent(complement(digitRanges)),
+	'w': classContent(wordRanges),
+	'W': classContent(complement(wordRanges)),
+	's': classContent(spaceRanges),
+	'S': classContent(complement(spaceRanges)),
+}
+
+var (
+	wordClass    = "[" + classEscapes['w'] + "]"
+	notLineTerm  = "[^" + classContent(lineTermRanges) + "]"
+	anyChar      = `[\s\S]`
+	wordBoundary = `(?:(?<=` + wordClass + `)(?!` + wordClass + `)|(?<!` + wordClass + `)(?=` + wordClass + `))`
+	nonBoundary  = `(?:(?<=` + wordClass + `)(?=` + wordClass + `)|(?<!` + wordClass + `)(?!` + wordClass + `))`
+)
+
+// complement returns the ranges of code points that are not in the given
+// sorted ranges.
+func complement(ranges [][2]rune) [][2]rune {
+	var ret [][2]rune
+	next := rune(0)
+	for _, r := range ranges {
+		if r[0] > next {
+			ret = append(ret, [2]rune{next, r[0] - 1})
+		}
+		next = r[1] + 1
+	}
+	if next <= utf8.MaxRune {
+		ret = append(ret, [2]rune{next, utf8.MaxRune})
+	}
+	return ret
+}
+
+// classContent returns the given ranges in the form of the content of a
+// character class in Syntax.
+func classContent(ranges [][2]rune) string {
+	var buf strings.Builder
+	for _, r := range ranges {
+		writeClassChar(&buf, r[0])
+		if r[1] != r[0] {
+			buf.WriteByte('-')
+			writeClassChar(&buf, r[1])
+		}
+	}
+	return buf.String()
+}
+
+// writeClassChar writes the given code point in a form that is literal in
+// Syntax, both inside and outside of a character class.
+func writeClassChar(buf *strings.Builder, r rune) {
+	if r < 0x10000 {
+		fmt.Fprintf(buf, `\u%04X`, r)
+		return
+	}
+	// Code points outside the BMP are never special.
+	buf.WriteRune(r)
+}
+
+// translate rewrites an ECMAScript pattern into an equivalent pattern in
+// Syntax, given whether the "m", "s" and "u" flags are set.
+func translate(src string, multiline, dotAll, unicode bool) (string, error) {
+	var buf strings.Builder
+	inClass := false
+
+	// quantified is 1 after a quantifier and 2 after a lazy quantifier,
+	// since ECMAScript does not allow a quantifier to be quantified again.
+	quantified := 0
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if n := quantifierLen(src[i:]); n > 0 && !inClass {
+			switch {
+			case quantified == 1 && c == '?':
+				quantified = 2
+			case quantified != 0:
+				return "", fmt.Errorf("nothing to repeat")
+			default:
+				quantified = 1
+			}
+			buf.WriteString(src[i : i+n])
+			i += n - 1
+			continue
+		}
+		quantified = 0
+
+		switch {
+		case c == '\\':
+			if i+1 == len(src) {
+				return "", fmt.Errorf("\\ at end of pattern")
+			}
+			n, err := translateEscape(&buf, src[i+1:], inClass, unicode)
+			if err != nil {
+				return "", err
+			}
+			i += n
+		case inClass:
+			switch c {
+			case ']':
+				inClass = false
+				buf.WriteByte(c)
+			case '[':
+				buf.WriteString(`\[`)
+			default:
+				buf.WriteByte(c)
+			}
+		case c == '[':
+			switch {
+			case strings.HasPrefix(src[i:], "[]"):
+				// An empty class matches nothing.
+				buf.WriteString("(?!)")
+				i++
+			case strings.HasPrefix(src[i:], "[^]"):
+				buf.WriteString(anyChar)
+				i += 2
+			case strings.HasPrefix(src[i:], "[^"):
+				buf.WriteString("[^")
+				inClass = true
+				i++
+			default:
+				buf.WriteByte(c)
+				inClass = true
+			}
+		case c == '.':
+			if dotAll {
+				buf.WriteString(anyChar)
+			} else {
+				buf.WriteString(notLineTerm)
+			}
+		case c == '^':
+			if multiline {
+				buf.WriteString("(?<!" + notLineTerm + ")")
+			} else {
+				buf.WriteString("(?<!" + anyChar + ")")
+			}
+		case c == '$':
+			if multiline {
+				buf.WriteString("(?!" + notLineTerm + ")")
+			} else {
+				buf.WriteString("(?!" + anyChar + ")")
+			}
+		case c == '(' && strings.HasPrefix(src[i:], "(?"):
+			rest := src[i+2:]
+			if !strings.HasPrefix(rest, ":") && !strings.HasPrefix(rest, "=") &&
+				!strings.HasPrefix(rest, "!") && !strings.HasPrefix(rest, "<") {
+				return "", fmt.Errorf("invalid group")
+			}
+			buf.WriteString("(?")
+			i++
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if inClass {
+		return "", fmt.Errorf("missing terminating ] for character class")
+	}
+	return buf.String(), nil
+}
+
+// quantifierLen returns the length of the quantifier at the start of the
+// given text, or zero if it does not begin with one. A "{" that does not
+// begin a valid interval is literal.
+func quantifierLen(s string) int {
+	switch s[0] {
+	case '*', '+', '?':
+		return 1
+	case '{':
+		i := 1
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 1 {
+			return 0
+		}
+		if i < len(s) && s[i] == ',' {
+			i++
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+		}
+		if i < len(s) && s[i] == '}' {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// translateEscape translates the escape sequence whose backslash precedes
+// the given text, returning the number of bytes of the text that it used.
+func translateEscape(buf *strings.Builder, rest string, inClass, unicode bool) (int, error) {
+	c := rest[0]
+	switch {
+	case classEscapes[c] != "":
+		if inClass {
+			buf.WriteString(classEscapes[c])
+		} else {
+			buf.WriteString("[" + classEscapes[c] + "]")
+		}
+		return 1, nil
+	case c == 'b' && inClass:
+		buf.WriteString(`\x08`)
+		return 1, nil
+	case c == 'b':
+		buf.WriteString(wordBoundary)
+		return 1, nil
+	case c == 'B':
+		buf.WriteString(nonBoundary)
+		return 1, nil
+	case c == 'u':
+		if r, n, ok := unicodeEscape(rest, unicode); ok {
+			if r >= 0xd800 && r <= 0xdfff {
+				// A lone surrogate cannot occur in UTF-8 text.
+				if !inClass {
+					buf.WriteString("(?!)")
+				}
+				return n, nil
+			}
+			writeClassChar(buf, r)
+			return n, nil
+		}
+	case c == 'p' || c == 'P':
+		if unicode {
+			end := strings.IndexByte(rest, '}')
+			if !strings.HasPrefix(rest[1:], "{") || end < 0 {
+				return 0, fmt.Errorf("invalid property name")
+			}
+			name := rest[2:end]
+			if _, value, ok := strings.Cut(name, "="); ok {
+				name = value
+			}
+			buf.WriteString(`\` + rest[:1] + `{` + name + `}`)
+			return end + 1, nil
+		}
+	case c == 'x':
+		if len(rest) >= 3 && isHex(rest[1]) && isHex(rest[2]) {
+			buf.WriteString(`\` + rest[:3])
+			return 3, nil
+		}
+	case c == 'c':
+		if len(rest) >= 2 && ('a' <= rest[1]|0x20 && rest[1]|0x20 <= 'z') {
+			buf.WriteString(`\` + rest[:2])
+			return 2, nil
+		}
+	case c == 'k' || c == '0' || (c >= '1' && c <= '9') ||
+		strings.IndexByte("fnrtv", c) >= 0:
+		buf.WriteByte('\\')
+		buf.WriteByte(c)
+		return 1, nil
+	case c >= 0x80 || !('a' <= c|0x20 && c|0x20 <= 'z'):
+		// An escaped punctuation character is always literal.
+		buf.WriteByte('\\')
+		buf.WriteByte(c)
+		return 1, nil
+	}
+
+	// Anything else is an identity escape, which is valid only without the
+	// "u" flag.
+	if unicode {
+		return 0, fmt.Errorf("invalid escape")
+	}
+	buf.WriteByte(c)
+	return 1, nil
+}
+
+// unicodeEscape decodes the escape "\uXXXX", or "\u{X...}" if unicode is
+// set, whose "u" begins the given text. A pair of escapes for the halves of
+// a surrogate pair is decoded as a single code point.
+func unicodeEscape(rest string, unicode bool) (r rune, n int, ok bool) {
+	if unicode && strings.HasPrefix(rest, "u{") {
+		end := strings.IndexByte(rest, '}')
+		if end < 3 {
+			return 0, 0, false
+		}
+		for i := 2; i < end; i++ {
+			if !isHex(rest[i]) {
+				return 0, 0, false
+			}
+			r = r<<4 | hexVal(rest[i])
+			if r > utf8.MaxRune {
+				return 0, 0, false
+			}
+		}
+		return r, end + 1, true
+	}
+	r, ok = hex4(rest[1:])
+	if !ok {
+		return 0, 0, false
+	}
+	if r >= 0xd800 && r < 0xdc00 && strings.HasPrefix(rest[5:], `\u`) {
+		if lo, ok := hex4(rest[7:]); ok && lo >= 0xdc00 && lo <= 0xdfff {
+			return 0x10000 + (r-0xd800)<<10 + (lo - 0xdc00), 11, true
+		}
+	}
+	return r, 5, true
+}
+
+func hex4(s string) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	var r rune
+	for i := 0; i < 4; i++ {
+		if !isHex(s[i]) {
+			return 0, false
+		}
+		r = r<<4 | hexVal(s[i])
+	}
+	return r, true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c|0x20 && c|0x20 <= 'f'
+}
+
+func hexVal(c byte) rune {
+	if c <= '9' {
+		return rune(c - '0')
+	}
+	return rune(c|0x20-'a') + 10
+}
@@ -0,0 +1,223 @@
+package jsregexp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// The expected results in these tests were produced by Node.js.
+
+func TestExec(t *testing.T) {
+	tests := []struct {
+		Source, Flags string
+		Str           string
+		WantIndex     int
+		Want          []string // nil if there is no match
+	}{
+		{`a.c`, ``, "a\nc abc", 4, []string{"abc"}},
+		{`a.c`, `s`, "a\nc", 0, []string{"a\nc"}},
+		{`a.c`, ``, "a c", 0, nil},
+		{`^b`, `m`, "a\nb", 2, []string{"b"}},
+		{`^b`, `m`, "a b", 2, []string{"b"}},
+		{`^b`, ``, "a\nb", 0, nil},
+		{`a$`, ``, "a\n", 0, nil},
+		{`a$`, `m`, "a\nb", 0, []string{"a"}},
+		{`\w+`, ``, "héllo", 0, []string{"h"}},
+		{`\s+`, ``, "a\u00a0\ufeffb", 1, []string{"\u00a0\ufeff"}},
+		{`\d+`, ``, "٣12", 1, []string{"12"}},
+		{`[^]`, ``, "\n", 0, []string{"\n"}},
+		{`[]`, ``, "a", 0, nil},
+		{`\u{1F600}`, `u`, "x😀", 1, []string{"😀"}},
+		{`😀`, ``, "x😀", 1, []string{"😀"}},
+		{`\bé`, ``, "aé é", 1, []string{"é"}},
+		{`\p{Script=Greek}+`, `u`, "abγδ", 2, []string{"γδ"}},
+		{`\p{L}`, ``, "p{L}", 0, []string{"p{L}"}},
+		{`[\d-z]+`, ``, "1-z", 0, []string{"1-z"}},
+		{`[\W]+`, ``, "ab, é", 2, []string{", é"}},
+		{`\cJ`, ``, "\n", 0, []string{"\n"}},
+		{`\x41\q`, ``, "Aq", 0, []string{"Aq"}},
+		{`\Aa`, ``, "Aa", 0, []string{"Aa"}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("/%s/%s in %q", test.Source, test.Flags, test.Str), func(t *testing.T) {
+			re := MustNew(test.Source, test.Flags)
+			res := re.Exec(test.Str)
+			if res == nil {
+				if test.Want != nil {
+					t.Fatalf("no match; want %q", test.Want)
+				}
+				return
+			}
+			if test.Want == nil {
+				t.Fatalf("unexpected match %#v", res.Match)
+			}
+			var got []string
+			for i := 0; i <= res.Match.CaptureCount(); i++ {
+				text, _ := res.Group(i)
+				got = append(got, text)
+			}
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf("wrong groups %q; want %q", got, test.Want)
+			}
+			if res.Index != test.WantIndex {
+				t.Errorf("wrong index %d; want %d", res.Index, test.WantIndex)
+			}
+		})
+	}
+}
+
+func TestExecIndices(t *testing.T) {
+	re := MustNew(`(?<y>\d{4})-(?<m>\d\d)(x)?`, "d")
+	res := re.Exec("😀 2024-05")
+	if res == nil {
+		t.Fatal("no match")
+	}
+
+	wantIndices := []onig.Span{{Start: 3, End: 10}, {Start: 3, End: 7}, {Start: 8, End: 10}, {Start: -1, End: -1}}
+	if !reflect.DeepEqual(res.Indices, wantIndices) {
+		t.Errorf("wrong indices\ngot:  %#v\nwant: %#v", res.Indices, wantIndices)
+	}
+	if got, want := res.Match.Bounds(), (onig.Span{Start: 5, End: 12}); got != want {
+		t.Errorf("wrong byte bounds %#v; want %#v", got, want)
+	}
+	if got, ok := res.NamedGroup("m"); !ok || got != "05" {
+		t.Errorf("wrong group m %q, %t", got, ok)
+	}
+	if _, ok := res.Group(3); ok {
+		t.Errorf("group 3 should not have participated")
+	}
+	if _, ok := res.NamedGroup("z"); ok {
+		t.Errorf("nonexistent group z should not be found")
+	}
+
+	if res := MustNew(`\d`, "").Exec("1"); res.Indices != nil {
+		t.Errorf("indices populated without the d flag: %#v", res.Indices)
+	}
+}
+
+func TestLastIndex(t *testing.T) {
+	var got []any
+
+	re := MustNew(`a`, "g")
+	for i := 0; i < 4; i++ {
+		got = append(got, re.Test("aa"), re.LastIndex)
+	}
+	if want := []any{true, 1, true, 2, false, 0, true, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong results with g flag %v; want %v", got, want)
+	}
+
+	sticky := MustNew(`a`, "y")
+	sticky.LastIndex = 1
+	if res := sticky.Exec("ba"); res == nil || res.Index != 1 || sticky.LastIndex != 2 {
+		t.Errorf("wrong first sticky result %#v with LastIndex %d", res, sticky.LastIndex)
+	}
+	if res := sticky.Exec("ba"); res != nil || sticky.LastIndex != 0 {
+		t.Errorf("wrong second sticky result %#v with LastIndex %d", res, sticky.LastIndex)
+	}
+
+	// LastIndex is in UTF-16 code units, so a character outside the BMP
+	// counts twice.
+	astral := MustNew(`😀|a`, "gu")
+	got = nil
+	for i := 0; i < 2; i++ {
+		res := astral.Exec("😀a")
+		got = append(got, res.Index, astral.LastIndex)
+	}
+	if want := []any{0, 2, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong results outside the BMP %v; want %v", got, want)
+	}
+
+	// Without g or y, LastIndex is neither used nor updated.
+	plain := MustNew(`a`, "")
+	plain.LastIndex = 5
+	if res := plain.Exec("ab"); res == nil || res.Index != 0 || plain.LastIndex != 5 {
+		t.Errorf("wrong result without flags %#v with LastIndex %d", res, plain.LastIndex)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		Source, Flags string
+		Str           string
+		Replacement   string
+		Want          string
+	}{
+		{`x*`, `g`, `abxd`, `-`, `-a-b--d-`},
+		{`(\w)(\d)?`, `g`, `a1b`, `[$2$1]`, `[1a][b]`},
+		{`b`, ``, `abc`, "$`|$&|$'|$$|$0|$3|$10", `aa|b|c|$|$0|$3|$10c`},
+		{`(?<n>b)`, ``, `abc`, `<$<n>|$<z>|$<n`, `a<b||$<nc`},
+		{`(b)`, ``, `abc`, `$<n>`, `a$<n>c`},
+		{`(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)`, ``, `abcdefghij`, `$10|$11|$01|$1`, `j|a1|a|a`},
+		{``, `gu`, `😀😀`, `-`, `-😀-😀-`},
+		{`a`, `y`, `baa`, `-`, `baa`},
+		{`a`, ``, `aa`, `$`, `$a`},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("/%s/%s with %q in %q", test.Source, test.Flags, test.Replacement, test.Str), func(t *testing.T) {
+			re := MustNew(test.Source, test.Flags)
+			if got := re.Replace(test.Str, test.Replacement); got != test.Want {
+				t.Errorf("wrong result %q; want %q", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestReplaceFunc(t *testing.T) {
+	re := MustNew(`\d+`, "g")
+	got := re.ReplaceFunc("😀1 22", func(res *Result) string {
+		return fmt.Sprintf("<%d>", res.Index)
+	})
+	if want := "😀<2> <4>"; got != want {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+	if re.LastIndex != 0 {
+		t.Errorf("wrong LastIndex %d after global replace", re.LastIndex)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		Source, Flags string
+		Want          string
+	}{
+		{`(?i)a`, ``, `invalid regular expression: /(?i)a/: invalid group`},
+		{`a`, `gg`, `invalid regular expression flags "gg"`},
+		{`a`, `x`, `invalid regular expression flags "x"`},
+		{`\q`, `u`, `invalid regular expression: /\q/: invalid escape`},
+		{`[a`, ``, `invalid regular expression: /[a/: missing terminating ] for character class`},
+		{`(`, ``, ``},
+		{`(?<n>a)(?<n>b)`, ``, ``},
+		{`a*+`, `u`, ``},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("/%s/%s", test.Source, test.Flags), func(t *testing.T) {
+			_, err := New(test.Source, test.Flags)
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if test.Want != "" && err.Error() != test.Want {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", err, test.Want)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid regular expression") {
+				t.Errorf("wrong error %q", err)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	re := MustNew(`a/b`, "yigd")
+	if got, want := re.String(), `/a/b/dgiy`; got != want {
+		t.Errorf("wrong String %q; want %q", got, want)
+	}
+	if got, want := re.Flags(), `dgiy`; got != want {
+		t.Errorf("wrong Flags %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package jsregexp
+
+import (
+	"strings"
+)
+
+// Replace returns a copy of the given string with the first match replaced
+// by the given replacement pattern, or every match with the "g" flag, as
+// JavaScript's String.prototype.replace does when given a RegExp.
+//
+// In the replacement, "$&" is the matched text, "$`" the text before the
+// match, "$'" the text after it, "$1" to "$99" the text of the numbered
+// groups, "$<name>" the text of the named group, and "$$" a literal "$".
+// LastIndex is updated as for a series of calls to Exec.
+func (r *JSRegExp) Replace(s, replacement string) string {
+	return r.replace(s, func(res *Result) string {
+		return res.expand(replacement)
+	})
+}
+
+// ReplaceFunc is like Replace but replaces each match with the result of
+// calling repl with that match, which is substituted directly.
+func (r *JSRegExp) ReplaceFunc(s string, repl func(res *Result) string) string {
+	return r.replace(s, repl)
+}
+
+func (r *JSRegExp) replace(s string, repl func(res *Result) string) string {
+	global := r.has('g')
+	if global {
+		r.LastIndex = 0
+	}
+
+	// All of the matches are found before any replacement is made, as in
+	// JavaScript, since repl could change LastIndex.
+	c := &utf16Counter{s: s}
+	var results []*Result
+	for {
+		res := r.exec(c)
+		if res == nil {
+			break
+		}
+		results = append(results, res)
+		if !global {
+			break
+		}
+		if res.Match.Bounds().Len() == 0 {
+			// An empty match would otherwise be found again.
+			r.LastIndex++
+		}
+	}
+
+	var buf strings.Builder
+	next := 0
+	for _, res := range results {
+		bounds := res.Match.Bounds()
+		replacement := repl(res)
+		if bounds.Start >= next {
+			buf.WriteString(s[next:bounds.Start])
+			buf.WriteString(replacement)
+			next = bounds.End
+		}
+	}
+	buf.WriteString(s[next:])
+	return buf.String()
+}
+
+// expand returns the given replacement pattern with its substitutions
+// made for the receiver, as for JavaScript's GetSubstitution.
+func (r *Result) expand(replacement string) string {
+	var buf strings.Builder
+	bounds := r.Match.Bounds()
+	ncap := r.Match.CaptureCount()
+	for {
+		before, after, ok := strings.Cut(replacement, "$")
+		if !ok {
+			break
+		}
+		buf.WriteString(before)
+		replacement = after
+		if replacement == "" {
+			buf.WriteByte('$')
+			break
+		}
+
+		switch c := replacement[0]; {
+		case c == '$':
+			buf.WriteByte('$')
+			replacement = replacement[1:]
+		case c == '&':
+			buf.WriteString(bounds.Substr(r.Input))
+			replacement = replacement[1:]
+		case c == '`':
+			buf.WriteString(r.Input[:bounds.Start])
+			replacement = replacement[1:]
+		case c == '\'':
+			buf.WriteString(r.Input[bounds.End:])
+			replacement = replacement[1:]
+		case c >= '0' && c <= '9':
+			// A two-digit group number is used if it is valid, or else a
+			// one-digit one.
+			idx, n := int(c-'0'), 1
+			if len(replacement) > 1 && replacement[1] >= '0' && replacement[1] <= '9' {
+				if two := idx*10 + int(replacement[1]-'0'); two >= 1 && two <= ncap {
+					idx, n = two, 2
+				}
+			}
+			if idx < 1 || idx > ncap {
+				buf.WriteByte('$')
+				continue
+			}
+			if text, ok := r.Group(idx); ok {
+				buf.WriteString(text)
+			}
+			replacement = replacement[n:]
+		case c == '<':
+			end := strings.IndexByte(replacement, '>')
+			if len(r.names) == 0 || end < 0 {
+				buf.WriteByte('$')
+				continue
+			}
+			if text, ok := r.NamedGroup(replacement[1:end]); ok {
+				buf.WriteString(text)
+			}
+			replacement = replacement[end+1:]
+		default:
+			buf.WriteByte('$')
+		}
+	}
+	buf.WriteString(replacement)
+	return buf.String()
+}
@@ -0,0 +1,276 @@
+package onig
+
+import (
+	"fmt"
+	"io"
+)
+
+// LexRootState is the name of the state a Lexer begins in.
+const LexRootState = "root"
+
+// MaxLexEmptyTransitions is the number of consecutive empty matches at the
+// same position after which a Lexer reports that it is not making progress.
+const MaxLexEmptyTransitions = 100
+
+// LexRule is a rule for a Lexer, which produces a token of the given kind
+// wherever its regex matches at the current position.
+//
+// A rule can also change the lexer's state, for languages whose tokens
+// depend on context, such as the contents of string literals or comments.
+// The lexer keeps a stack of states and uses the rules of the state at the
+// top. After a rule matches, Pop removes the top state, then Next replaces
+// the top state, and then Push adds a new state on top.
+type LexRule struct {
+	Kind  string
+	Regex *Regex
+
+	// Skip discards the tokens produced by the rule, such as for
+	// whitespace and comments.
+	Skip bool
+
+	Pop  bool
+	Next string
+	Push string
+}
+
+func (r *LexRule) changesState() bool {
+	return r.Pop || r.Next != "" || r.Push != ""
+}
+
+// LexPolicy decides which rule a Lexer uses when more than one of the rules
+// of its current state match at the current position.
+type LexPolicy int
+
+const (
+	// FirstMatch uses the first rule that matches, in the order the rules
+	// are given.
+	FirstMatch LexPolicy = iota
+
+	// LongestMatch uses the rule with the longest match, preferring the
+	// earliest rule when matches have the same length.
+	LongestMatch
+)
+
+// Lexer splits text into tokens using ordered lists of rules, grouped into
+// named states. Each rule's regex is matched at the current position, as
+// with MatchAt, so "\G" matches at that position and lookbehind assertions
+// can see the text before it.
+//
+// A rule can match the empty string only if it changes the state. Other
+// empty matches are ignored. Since empty matches that change the state can
+// still form a cycle, such as two states whose empty rules switch to each
+// other, tokenizing fails with a *LexError if more than
+// MaxLexEmptyTransitions of them occur in a row at the same position.
+//
+// A Lexer is safe for concurrent use by multiple goroutines.
+type Lexer struct {
+	states map[string][]LexRule
+	policy LexPolicy
+}
+
+// NewLexer returns a Lexer with the given rules for each state, which must
+// include LexRootState, and the given policy for choosing between rules.
+// It returns an error if a rule has no regex or refers to a state that
+// does not exist.
+func NewLexer(states map[string][]LexRule, policy LexPolicy) (*Lexer, error) {
+	if _, ok := states[LexRootState]; !ok {
+		return nil, fmt.Errorf("no rules for state %q", LexRootState)
+	}
+	copied := make(map[string][]LexRule, len(states))
+	for state, rules := range states {
+		for i, rule := range rules {
+			if rule.Regex == nil {
+				return nil, fmt.Errorf("rule %d of state %q has no regex", i, state)
+			}
+			for _, target := range []string{rule.Next, rule.Push} {
+				if _, ok := states[target]; target != "" && !ok {
+					return nil, fmt.Errorf("rule %d of state %q refers to undefined state %q", i, state, target)
+				}
+			}
+		}
+		copied[state] = append([]LexRule(nil), rules...)
+	}
+	return &Lexer{states: copied, policy: policy}, nil
+}
+
+// Token is a token produced by a Lexer.
+type Token struct {
+	Kind string
+	Text string
+	Span Span
+
+	// Line and Column give the position of the start of the token. Both
+	// start at 1, and Column counts bytes, as in go/token.
+	Line, Column int
+}
+
+// LexError describes a failure to tokenize text.
+type LexError struct {
+	// Offset, Line and Column give the position of the failure, as for
+	// the fields of Token.
+	Offset       int
+	Line, Column int
+
+	// State is the state the lexer was in.
+	State string
+
+	Msg string
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Tokenize returns all of the tokens in the given string. If tokenizing
+// fails, it returns the tokens before the failure along with a *LexError.
+func (l *Lexer) Tokenize(s string) ([]Token, error) {
+	var tokens []Token
+	t := l.Tokens(s)
+	for {
+		tok, err := t.Next()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// Tokens returns a TokenReader that produces the tokens in the given string
+// one at a time.
+func (l *Lexer) Tokens(s string) *TokenReader {
+	return &TokenReader{
+		lexer: l,
+		s:     s,
+		stack: []string{LexRootState},
+		line:  1,
+	}
+}
+
+// TokenReader produces the tokens of a string one at a time, for a Lexer.
+//
+// A TokenReader is not safe for concurrent use by multiple goroutines.
+type TokenReader struct {
+	lexer *Lexer
+	s     string
+	pos   int
+	stack []string
+
+	// line is the current line number and lineStart is the offset of its
+	// first byte.
+	line, lineStart int
+
+	// empty is the number of consecutive empty matches at pos.
+	empty int
+
+	err error
+}
+
+// State returns the name of the reader's current state.
+func (t *TokenReader) State() string {
+	return t.stack[len(t.stack)-1]
+}
+
+// Next returns the next token. At the end of the input it returns io.EOF,
+// and if no rule matches it returns a *LexError. After either, it returns
+// the same error again.
+func (t *TokenReader) Next() (Token, error) {
+	for t.err == nil {
+		if t.pos == len(t.s) {
+			t.err = io.EOF
+			break
+		}
+
+		rule, span := t.match()
+		if rule == nil {
+			t.err = t.errorf("no rule matches %q", t.s[t.pos:t.pos+min(len(t.s)-t.pos, 10)])
+			break
+		}
+		tok := Token{
+			Kind:   rule.Kind,
+			Text:   span.Substr(t.s),
+			Span:   span,
+			Line:   t.line,
+			Column: span.Start - t.lineStart + 1,
+		}
+		if span.Len() > 0 {
+			t.empty = 0
+		} else if t.empty++; t.empty > MaxLexEmptyTransitions {
+			t.err = t.errorf("no progress after %d empty matches in a row", MaxLexEmptyTransitions)
+			break
+		}
+		if err := t.changeState(rule); err != nil {
+			t.err = err
+			break
+		}
+		t.advance(span.End)
+		if !rule.Skip && span.Len() > 0 {
+			return tok, nil
+		}
+	}
+	return Token{}, t.err
+}
+
+// match returns the rule to use at the current position and its match, or
+// nil if no rule matches.
+func (t *TokenReader) match() (*LexRule, Span) {
+	var best *LexRule
+	var bestSpan Span
+	rules := t.lexer.states[t.State()]
+	for i := range rules {
+		rule := &rules[i]
+		m := rule.Regex.MatchAt(t.s, t.pos, NoMatchOpts)
+		if m == nil {
+			continue
+		}
+		span := m.Bounds()
+		if span.Len() == 0 && !rule.changesState() {
+			continue
+		}
+		if t.lexer.policy == FirstMatch {
+			return rule, span
+		}
+		if best == nil || span.Len() > bestSpan.Len() {
+			best, bestSpan = rule, span
+		}
+	}
+	return best, bestSpan
+}
+
+func (t *TokenReader) advance(end int) {
+	for i := t.pos; i < end; i++ {
+		if t.s[i] == '\n' {
+			t.line++
+			t.lineStart = i + 1
+		}
+	}
+	t.pos = end
+}
+
+func (t *TokenReader) changeState(rule *LexRule) error {
+	if rule.Pop {
+		if len(t.stack) == 1 {
+			return t.errorf("rule for %q pops the last state", rule.Kind)
+		}
+		t.stack = t.stack[:len(t.stack)-1]
+	}
+	if rule.Next != "" {
+		t.stack[len(t.stack)-1] = rule.Next
+	}
+	if rule.Push != "" {
+		t.stack = append(t.stack, rule.Push)
+	}
+	return nil
+}
+
+func (t *TokenReader) errorf(format string, args ...any) *LexError {
+	return &LexError{
+		Offset: t.pos,
+		Line:   t.line,
+		Column: t.pos - t.lineStart + 1,
+		State:  t.State(),
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
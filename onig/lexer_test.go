@@ -0,0 +1,186 @@
+package onig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func lexRule(kind, pattern string) LexRule {
+	return LexRule{Kind: kind, Regex: MustNewRegex(pattern, NoCompileOpts, SyntaxRuby)}
+}
+
+func TestLexer(t *testing.T) {
+	ws := lexRule("ws", `\s+`)
+	ws.Skip = true
+	open := lexRule("open", `"`)
+	open.Push = "string"
+	end := lexRule("close", `"`)
+	end.Pop = true
+
+	lexer, err := NewLexer(map[string][]LexRule{
+		LexRootState: {
+			ws,
+			lexRule("number", `\d+`),
+			lexRule("times", `(?<=\d)x`),
+			lexRule("ident", `[a-z]+`),
+			open,
+		},
+		"string": {
+			lexRule("text", `[^"\\]+`),
+			lexRule("escape", `\\.`),
+			end,
+		},
+	}, FirstMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := lexer.Tokenize("ab 2x3 \"é\\\"\"\n  cd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Token{
+		{Kind: "ident", Text: "ab", Span: Span{0, 2}, Line: 1, Column: 1},
+		{Kind: "number", Text: "2", Span: Span{3, 4}, Line: 1, Column: 4},
+		{Kind: "times", Text: "x", Span: Span{4, 5}, Line: 1, Column: 5},
+		{Kind: "number", Text: "3", Span: Span{5, 6}, Line: 1, Column: 6},
+		{Kind: "open", Text: `"`, Span: Span{7, 8}, Line: 1, Column: 8},
+		{Kind: "text", Text: "é", Span: Span{8, 10}, Line: 1, Column: 9},
+		{Kind: "escape", Text: `\"`, Span: Span{10, 12}, Line: 1, Column: 11},
+		{Kind: "close", Text: `"`, Span: Span{12, 13}, Line: 1, Column: 13},
+		{Kind: "ident", Text: "cd", Span: Span{16, 18}, Line: 2, Column: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong tokens\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestLexerPolicy(t *testing.T) {
+	rules := map[string][]LexRule{
+		LexRootState: {lexRule("keyword", `if`), lexRule("ident", `[a-z]+`)},
+	}
+	tests := []struct {
+		Policy LexPolicy
+		Want   []string
+	}{
+		{FirstMatch, []string{"keyword", "if", "ident", "fy"}},
+		{LongestMatch, []string{"ident", "iffy"}},
+	}
+
+	for _, test := range tests {
+		lexer, err := NewLexer(rules, test.Policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toks, err := lexer.Tokenize("iffy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tok := range toks {
+			got = append(got, tok.Kind, tok.Text)
+		}
+		if !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("wrong tokens with policy %d: %q; want %q", test.Policy, got, test.Want)
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	pop := lexRule("pop", `\)`)
+	pop.Pop = true
+	lexer, err := NewLexer(map[string][]LexRule{
+		LexRootState: {lexRule("word", `\w+\n?`), pop},
+	}, FirstMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Input string
+		Want  LexError
+	}{
+		{"ab\ncd $", LexError{Offset: 5, Line: 2, Column: 3, State: LexRootState, Msg: `no rule matches " $"`}},
+		{"ab)", LexError{Offset: 2, Line: 1, Column: 3, State: LexRootState, Msg: `rule for "pop" pops the last state`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			toks, err := lexer.Tokenize(test.Input)
+			lexErr, ok := err.(*LexError)
+			if !ok {
+				t.Fatalf("wrong error %#v", err)
+			}
+			if *lexErr != test.Want {
+				t.Errorf("wrong error\ngot:  %#v\nwant: %#v", *lexErr, test.Want)
+			}
+			if len(toks) == 0 {
+				t.Errorf("tokens before the error were not returned")
+			}
+		})
+	}
+
+	if got, want := (&LexError{Line: 2, Column: 3, Msg: "oops"}).Error(), "2:3: oops"; got != want {
+		t.Errorf("wrong error string %q; want %q", got, want)
+	}
+}
+
+func TestLexerEmptyCycle(t *testing.T) {
+	toB := lexRule("toB", ``)
+	toB.Next = "b"
+	toRoot := lexRule("toRoot", `(?=x)`)
+	toRoot.Next = LexRootState
+	push := lexRule("push", `(?=y)`)
+	push.Push = LexRootState
+
+	lexer, err := NewLexer(map[string][]LexRule{
+		LexRootState: {lexRule("word", `a+`), push, toB},
+		"b":          {lexRule("word", `b+`), toRoot},
+	}, FirstMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Input string
+		Want  LexError
+	}{
+		// root and b switch to each other forever at the "x".
+		{"aabx", LexError{Offset: 3, Line: 1, Column: 4, State: "b", Msg: "no progress after 100 empty matches in a row"}},
+		// push adds another root state forever at the "y".
+		{"ay", LexError{Offset: 1, Line: 1, Column: 2, State: LexRootState, Msg: "no progress after 100 empty matches in a row"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			_, err := lexer.Tokenize(test.Input)
+			lexErr, ok := err.(*LexError)
+			if !ok {
+				t.Fatalf("wrong error %#v", err)
+			}
+			if *lexErr != test.Want {
+				t.Errorf("wrong error\ngot:  %#v\nwant: %#v", *lexErr, test.Want)
+			}
+		})
+	}
+}
+
+func TestNewLexerErrors(t *testing.T) {
+	push := lexRule("push", `a`)
+	push.Push = "missing"
+	tests := []struct {
+		States map[string][]LexRule
+		Want   string
+	}{
+		{map[string][]LexRule{"other": nil}, `no rules for state "root"`},
+		{map[string][]LexRule{LexRootState: {{Kind: "a"}}}, `rule 0 of state "root" has no regex`},
+		{map[string][]LexRule{LexRootState: {push}}, `rule 0 of state "root" refers to undefined state "missing"`},
+	}
+
+	for _, test := range tests {
+		_, err := NewLexer(test.States, FirstMatch)
+		if err == nil || err.Error() != test.Want {
+			t.Errorf("wrong error %v; want %s", err, test.Want)
+		}
+	}
+}
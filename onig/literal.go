@@ -0,0 +1,105 @@
+package onig
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseCompileOptions parses a string of single-letter option flags, such as
+// "imx", into the equivalent compile options, following the conventions of
+// the given syntax.
+//
+// All syntaxes accept "i" for OptIgnoreCase and "x" for OptExtend. Syntaxes
+// that use Perl-style inline options, which are SyntaxPerl, SyntaxPerlNG and
+// SyntaxJava, accept "s" for OptMultiline, which makes "." match a newline,
+// and "m" for OptNegateSingleline, which makes "^" and "$" match at line
+// boundaries. All other syntaxes follow the Ruby convention, accepting "m"
+// for OptMultiline.
+func ParseCompileOptions(flags string, syntax Syntax) (CompileOptions, error) {
+	perl := syntaxGetInfo(syntax).Op2&synOp2OptionPerl != 0
+	options := NoCompileOpts
+	for _, r := range flags {
+		switch {
+		case r == 'i':
+			options |= OptIgnoreCase
+		case r == 'x':
+			options |= OptExtend
+		case r == 'm' && !perl:
+			options |= OptMultiline
+		case r == 's' && perl:
+			options |= OptMultiline
+		case r == 'm' && perl:
+			options |= OptNegateSingleline
+		default:
+			return NoCompileOpts, fmt.Errorf("unsupported option flag %q for syntax %s", r, syntax)
+		}
+	}
+	return options, nil
+}
+
+// literalBracketPairs gives the closing delimiter for each opening delimiter
+// that is not also its own closing delimiter.
+var literalBracketPairs = map[rune]rune{
+	'(': ')',
+	'[': ']',
+	'{': '}',
+	'<': '>',
+}
+
+// ParseLiteral parses and compiles a delimited regex literal such as
+// "/foo.*bar/imx", where the pattern is enclosed in delimiters and followed
+// by option flags as accepted by ParseCompileOptions.
+//
+// The delimiter may be any ASCII punctuation character other than backslash.
+// If it is one of "(", "[", "{" or "<" then the literal is instead closed by
+// the corresponding closing bracket, and unescaped pairs of the brackets may
+// be nested within the pattern, as in "{a{2}}".
+//
+// A delimiter can be included in the pattern by escaping it with a
+// backslash. The backslash is removed unless the delimiter has a special
+// meaning in the given syntax, in which case it is retained so that the
+// delimiter still matches literally. All other backslash escapes are passed
+// through to the pattern unchanged.
+func ParseLiteral(lit string, syntax Syntax) (*Regex, error) {
+	open, size := utf8.DecodeRuneInString(lit)
+	if lit == "" || open >= utf8.RuneSelf || open == '\\' || !(unicode.IsPunct(open) || unicode.IsSymbol(open)) {
+		return nil, fmt.Errorf("regex literal must begin with a punctuation delimiter")
+	}
+	closing, ok := literalBracketPairs[open]
+	if !ok {
+		closing = open
+	}
+
+	q := newQuoter(syntax)
+	var buf strings.Builder
+	depth := 0
+	for i := size; i < len(lit); {
+		r, n := utf8.DecodeRuneInString(lit[i:])
+		switch {
+		case r == '\\' && i+n < len(lit):
+			next, m := utf8.DecodeRuneInString(lit[i+n:])
+			if (next == open || next == closing) && !q.special(next) {
+				buf.WriteRune(next)
+			} else {
+				buf.WriteString(lit[i : i+n+m])
+			}
+			i += n + m
+			continue
+		case r == closing && depth == 0:
+			options, err := ParseCompileOptions(lit[i+n:], syntax)
+			if err != nil {
+				return nil, err
+			}
+			return NewRegex(buf.String(), options, syntax)
+		case r == closing:
+			depth--
+		case r == open:
+			depth++
+		}
+		buf.WriteRune(r)
+		i += n
+	}
+	return nil, fmt.Errorf("regex literal is missing closing delimiter %q", closing)
+}
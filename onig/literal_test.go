@@ -0,0 +1,155 @@
+package onig
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseCompileOptions(t *testing.T) {
+	tests := []struct {
+		Flags   string
+		Syntax  Syntax
+		Want    CompileOptions
+		WantErr string
+	}{
+		{"", SyntaxRuby, NoCompileOpts, ``},
+		{"imx", SyntaxRuby, OptIgnoreCase | OptMultiline | OptExtend, ``},
+		{"ii", SyntaxRuby, OptIgnoreCase, ``},
+		{"s", SyntaxRuby, NoCompileOpts, `unsupported option flag 's' for syntax Ruby`},
+		{"m", SyntaxPosixExtended, OptMultiline, ``},
+		{"imsx", SyntaxPerl, OptIgnoreCase | OptNegateSingleline | OptMultiline | OptExtend, ``},
+		{"s", SyntaxJava, OptMultiline, ``},
+		{"m", SyntaxPerlNG, OptNegateSingleline, ``},
+		{"g", SyntaxPerl, NoCompileOpts, `unsupported option flag 'g' for syntax Perl`},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %q", test.Syntax, test.Flags), func(t *testing.T) {
+			got, err := ParseCompileOptions(test.Flags, test.Syntax)
+			if test.WantErr != "" {
+				if err == nil {
+					t.Fatal("unexpected success")
+				}
+				if got := err.Error(); got != test.WantErr {
+					t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.WantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.Want {
+				t.Errorf("wrong result %s; want %s", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestParseCompileOptionsPerlMultiline(t *testing.T) {
+	// In Perl, "m" makes the anchors match at line boundaries, which in
+	// Oniguruma terms is negating the syntax's default singleline mode.
+	for _, test := range []struct {
+		Flags string
+		Want  bool
+	}{
+		{"", false},
+		{"m", true},
+	} {
+		options, err := ParseCompileOptions(test.Flags, SyntaxPerl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := MustNewRegex(`^b$`, options, SyntaxPerl)
+		if got := r.Search("a\nb\nc", NoMatchOpts) != nil; got != test.Want {
+			t.Errorf("with flags %q, found match is %#v; want %#v", test.Flags, got, test.Want)
+		}
+	}
+}
+
+func TestCompileOptionsString(t *testing.T) {
+	tests := []struct {
+		Options CompileOptions
+		Want    string
+	}{
+		{NoCompileOpts, `NoCompileOpts`},
+		{OptIgnoreCase, `OptIgnoreCase`},
+		{OptExtend | OptIgnoreCase, `OptIgnoreCase|OptExtend`},
+		{OptCaptureGroup | 1<<30, `OptCaptureGroup|0x40000000`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Want, func(t *testing.T) {
+			if got := test.Options.String(); got != test.Want {
+				t.Errorf("wrong result %q; want %q", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestParseLiteral(t *testing.T) {
+	tests := []struct {
+		Lit         string
+		Syntax      Syntax
+		WantPattern string
+		WantOptions CompileOptions
+	}{
+		{`/foo.*bar/`, SyntaxRuby, `foo.*bar`, NoCompileOpts},
+		{`/foo.*bar/imx`, SyntaxRuby, `foo.*bar`, OptIgnoreCase | OptMultiline | OptExtend},
+		{`/a\/b/`, SyntaxRuby, `a/b`, NoCompileOpts},
+		{`/a\\/`, SyntaxRuby, `a\\`, NoCompileOpts},
+		{`/\d+\./`, SyntaxRuby, `\d+\.`, NoCompileOpts},
+		{`|a\|b|`, SyntaxRuby, `a\|b`, NoCompileOpts},
+		{`#a/b#s`, SyntaxPerl, `a/b`, OptMultiline},
+		{`{a{2}}`, SyntaxRuby, `a{2}`, NoCompileOpts},
+		{`{a\}}`, SyntaxRuby, `a}`, NoCompileOpts},
+		{`{a\{}`, SyntaxRuby, `a\{`, NoCompileOpts},
+		{`(a\))`, SyntaxPosixBasic, `a)`, NoCompileOpts},
+		{`/日本/`, SyntaxRuby, `日本`, NoCompileOpts},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Lit, func(t *testing.T) {
+			r, err := ParseLiteral(test.Lit, test.Syntax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Pattern(); got != test.WantPattern {
+				t.Errorf("wrong pattern\ngot:  %s\nwant: %s", got, test.WantPattern)
+			}
+			if got := r.Options(); got != test.WantOptions {
+				t.Errorf("wrong options %s; want %s", got, test.WantOptions)
+			}
+			if got := r.Syntax(); got != test.Syntax {
+				t.Errorf("wrong syntax %s; want %s", got, test.Syntax)
+			}
+		})
+	}
+}
+
+func TestParseLiteralErrors(t *testing.T) {
+	tests := []struct {
+		Lit     string
+		WantErr string
+	}{
+		{``, `regex literal must begin with a punctuation delimiter`},
+		{`foo`, `regex literal must begin with a punctuation delimiter`},
+		{`\foo\`, `regex literal must begin with a punctuation delimiter`},
+		{`/foo`, `regex literal is missing closing delimiter '/'`},
+		{`/foo\/`, `regex literal is missing closing delimiter '/'`},
+		{`{a{2}`, `regex literal is missing closing delimiter '}'`},
+		{`/foo/q`, `unsupported option flag 'q' for syntax Ruby`},
+		{`/[/`, `premature end of char-class`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Lit, func(t *testing.T) {
+			_, err := ParseLiteral(test.Lit, SyntaxRuby)
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if got := err.Error(); got != test.WantErr {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.WantErr)
+			}
+		})
+	}
+}
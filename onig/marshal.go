@@ -0,0 +1,300 @@
+package onig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MarshalText implements encoding.TextMarshaler, returning the pattern the
+// receiver was compiled from.
+//
+// The text form does not record the options or syntax. Use the JSON form
+// to preserve those.
+func (r *Regex) MarshalText() ([]byte, error) {
+	return []byte(r.pattern), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, compiling the given
+// text as a pattern using SyntaxRuby with no options.
+//
+// The receiver must be a new, zero-value Regex, as is allocated for a *Regex
+// field in a configuration struct. Since a compiled Regex is immutable and
+// may be shared, such as by the cache behind MustNewRegex, unmarshaling into
+// one returns an error and leaves it unchanged. The receiver must also be
+// allocated separately rather than embedded in another value.
+func (r *Regex) UnmarshalText(text []byte) error {
+	if r.syntax != 0 {
+		return errAlreadyCompiled
+	}
+	return r.compile(string(text), NoCompileOpts, SyntaxRuby)
+}
+
+var errAlreadyCompiled = errors.New("cannot unmarshal into a regex that is already compiled")
+
+// regexJSON is the JSON object representation of a Regex.
+type regexJSON struct {
+	Pattern string   `json:"pattern"`
+	Options []string `json:"options,omitempty"`
+	Syntax  string   `json:"syntax,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, returning an object describing the
+// pattern, options and syntax the receiver was compiled from, such as:
+//
+//	{"pattern":"a+b","options":["IgnoreCase","Extend"],"syntax":"Ruby"}
+//
+// Each option is named after its constant with the "Opt" prefix removed, and
+// the syntax is named as returned by Syntax.String.
+func (r *Regex) MarshalJSON() ([]byte, error) {
+	syntax, ok := syntaxName(r.syntax)
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal regex with unsupported syntax %s", r.syntax)
+	}
+	raw := regexJSON{
+		Pattern: r.pattern,
+		Syntax:  syntax,
+	}
+	remain := r.options
+	for _, o := range compileOptionNames {
+		if remain&o.Opt != 0 {
+			raw.Options = append(raw.Options, o.Name)
+			remain &^= o.Opt
+		}
+	}
+	if remain != 0 {
+		return nil, fmt.Errorf("cannot marshal regex with unsupported options %#x", uint(remain))
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting either an object in
+// the form produced by MarshalJSON or a plain string.
+//
+// In the object form, "options" and "syntax" may be omitted, in which case
+// the pattern is compiled using SyntaxRuby with no options. A plain string
+// is treated as for UnmarshalText. In both cases the receiver is subject to
+// the same constraints as described for UnmarshalText.
+func (r *Regex) UnmarshalJSON(data []byte) error {
+	if r.syntax != 0 {
+		return errAlreadyCompiled
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return r.UnmarshalText([]byte(s))
+	}
+
+	var raw regexJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("regex must be either a string or an object: %w", err)
+	}
+
+	syntax := SyntaxRuby
+	if raw.Syntax != "" {
+		var ok bool
+		syntax, ok = syntaxByName(raw.Syntax)
+		if !ok {
+			return fmt.Errorf("unsupported regex syntax %q", raw.Syntax)
+		}
+	}
+	options := NoCompileOpts
+Names:
+	for _, name := range raw.Options {
+		for _, o := range compileOptionNames {
+			if o.Name == name {
+				options |= o.Opt
+				continue Names
+			}
+		}
+		return fmt.Errorf("unsupported regex option %q", name)
+	}
+
+	return r.compile(raw.Pattern, options, syntax)
+}
+
+// MatchJSON is the JSON representation of a match, as produced by the
+// MarshalJSON methods of Match and SubjectMatch. Match.UnmarshalJSON reverses
+// the conversion, such as for checking the results returned by a service in
+// tests. Unmarshaling into a MatchJSON instead also gives access to the text
+// and named captures.
+type MatchJSON struct {
+	// Bounds describes the whole match.
+	Bounds *SpanJSON `json:"bounds"`
+
+	// Captures describes each capture, starting with the capture at index
+	// 1, with nil for a capture that did not participate in the match.
+	Captures []*SpanJSON `json:"captures"`
+
+	// Named describes the capture with each name in the regex, chosen as
+	// for NamedCapture, with nil for a name whose captures did not
+	// participate in the match. It is present only for a SubjectMatch.
+	Named map[string]*SpanJSON `json:"named,omitempty"`
+}
+
+// SpanJSON is the JSON representation of a span within a MatchJSON. Text
+// is the text of the span, which is present only for a SubjectMatch.
+type SpanJSON struct {
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+	Text  *string `json:"text,omitempty"`
+}
+
+// Match returns a Match with the spans described by the receiver. It
+// returns an error if the bounds are missing or if any span is invalid.
+func (j *MatchJSON) Match() (*Match, error) {
+	spans, err := j.spans()
+	if err != nil {
+		return nil, err
+	}
+	m := new(Match)
+	if err := matchSetSpans(m, spans); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (j *MatchJSON) spans() ([]Span, error) {
+	if j.Bounds == nil {
+		return nil, fmt.Errorf("match has no bounds")
+	}
+	spans := make([]Span, len(j.Captures)+1)
+	for i, raw := range append([]*SpanJSON{j.Bounds}, j.Captures...) {
+		if raw == nil {
+			spans[i] = Span{-1, -1}
+			continue
+		}
+		if raw.Start < 0 || raw.End < raw.Start {
+			return nil, fmt.Errorf("invalid span [%d:%d] for capture %d", raw.Start, raw.End, i)
+		}
+		spans[i] = Span{raw.Start, raw.End}
+	}
+	return spans, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting an object in the form
+// described by MatchJSON and replacing the spans of the receiver with the
+// ones it describes. Any text and named captures in the object are ignored.
+//
+// The receiver may be a zero Match, as is allocated for a *Match field, in
+// which case it must be allocated separately rather than embedded in
+// another value. It must not be in use by any other goroutine.
+func (m *Match) UnmarshalJSON(data []byte) error {
+	var raw MatchJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	spans, err := raw.spans()
+	if err != nil {
+		return err
+	}
+	return matchSetSpans(m, spans)
+}
+
+// MarshalJSON implements json.Marshaler, returning an object in the form
+// described by MatchJSON, such as:
+//
+//	{"bounds":{"start":3,"end":10},"captures":[{"start":3,"end":7},null]}
+//
+// A Match does not record the text it was found in, so the result has no
+// text or named captures. Use SubjectMatch to include them.
+func (m *Match) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.toJSON(nil))
+}
+
+// MarshalJSON implements json.Marshaler, returning an object in the form
+// described by MatchJSON, including the text of each span and the named
+// captures, such as:
+//
+//	{
+//	  "bounds":{"start":3,"end":10,"text":"2024-06"},
+//	  "captures":[{"start":3,"end":7,"text":"2024"},null],
+//	  "named":{"year":{"start":3,"end":7,"text":"2024"},"day":null}
+//	}
+func (m *SubjectMatch) MarshalJSON() ([]byte, error) {
+	raw := m.toJSON(m.subject)
+	raw.Named = make(map[string]*SpanJSON)
+	for name := range m.re.NamedCaptures() {
+		raw.Named[name] = spanJSON(NamedCapture(m.re, m.Match, name), m.subject)
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON implements json.Unmarshaler by returning an error, since a
+// SubjectMatch can only be made from a match in a subject. Unmarshal into a
+// Match or a MatchJSON instead.
+func (m *SubjectMatch) UnmarshalJSON(data []byte) error {
+	return errors.New("cannot unmarshal into a SubjectMatch")
+}
+
+// toJSON returns the MatchJSON for the receiver, including the text of each
+// span if subject is not nil.
+func (m *Match) toJSON(subject *Subject) MatchJSON {
+	raw := MatchJSON{
+		Bounds:   spanJSON(m.Bounds(), subject),
+		Captures: make([]*SpanJSON, m.CaptureCount()),
+	}
+	for i := range raw.Captures {
+		raw.Captures[i] = spanJSON(m.Capture(i+1), subject)
+	}
+	return raw
+}
+
+func spanJSON(span Span, subject *Subject) *SpanJSON {
+	if span.Start < 0 {
+		return nil
+	}
+	raw := &SpanJSON{Start: span.Start, End: span.End}
+	if subject != nil {
+		text := span.Substr(subject.s)
+		raw.Text = &text
+	}
+	return raw
+}
+
+// RegexFlag is an implementation of flag.Value that compiles each value it
+// is given as a regex, using fixed options and syntax.
+//
+// The zero value is ready to use and compiles patterns using SyntaxRuby with
+// no options:
+//
+//	var include onig.RegexFlag
+//	flag.Var(&include, "include", "pattern matching files to include")
+//
+// After the flags have been parsed, field Regex is nil if the flag was not
+// set. If the flag is set more than once then the last value wins.
+type RegexFlag struct {
+	Regex   *Regex
+	Options CompileOptions
+
+	// Syntax is the syntax to compile patterns with. If it is zero then
+	// SyntaxRuby is used.
+	Syntax Syntax
+}
+
+// String returns the pattern of the most recently set regex, or an empty
+// string if none has been set.
+func (f *RegexFlag) String() string {
+	if f == nil || f.Regex == nil {
+		return ""
+	}
+	return f.Regex.Pattern()
+}
+
+// Set compiles the given pattern, replacing field Regex if successful.
+func (f *RegexFlag) Set(pattern string) error {
+	syntax := f.Syntax
+	if syntax == 0 {
+		syntax = SyntaxRuby
+	}
+	r, err := NewRegex(pattern, f.Options, syntax)
+	if err != nil {
+		return err
+	}
+	f.Regex = r
+	return nil
+}
+
+// Get implements flag.Getter, returning the current value of field Regex.
+func (f *RegexFlag) Get() any {
+	return f.Regex
+}
@@ -0,0 +1,354 @@
+package onig
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestRegexMarshalJSON(t *testing.T) {
+	tests := []struct {
+		Regex *Regex
+		Want  string
+	}{
+		{
+			MustNewRegex(`a+b`, NoCompileOpts, SyntaxRuby),
+			`{"pattern":"a+b","syntax":"Ruby"}`,
+		},
+		{
+			MustNewRegex(`a+b`, OptExtend|OptIgnoreCase, SyntaxPerl),
+			`{"pattern":"a+b","options":["IgnoreCase","Extend"],"syntax":"Perl"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Want, func(t *testing.T) {
+			got, err := json.Marshal(test.Regex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.Want {
+				t.Errorf("wrong result\ngot:  %s\nwant: %s", got, test.Want)
+			}
+
+			var r Regex
+			if err := json.Unmarshal(got, &r); err != nil {
+				t.Fatal(err)
+			}
+			if !r.Equal(test.Regex) {
+				t.Errorf("round trip produced %#v; want %#v", &r, test.Regex)
+			}
+		})
+	}
+}
+
+func TestRegexUnmarshalJSON(t *testing.T) {
+	type config struct {
+		Include *Regex   `json:"include"`
+		Exclude []*Regex `json:"exclude"`
+	}
+	src := `{
+		"include": "^ab+",
+		"exclude": [
+			{"pattern": "ABC", "options": ["IgnoreCase"]},
+			{"pattern": "a\\{2\\}", "syntax": "PosixBasic"}
+		]
+	}`
+
+	var got config
+	if err := json.Unmarshal([]byte(src), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		Include: MustNewRegex(`^ab+`, NoCompileOpts, SyntaxRuby),
+		Exclude: []*Regex{
+			MustNewRegex(`ABC`, OptIgnoreCase, SyntaxRuby),
+			MustNewRegex(`a\{2\}`, NoCompileOpts, SyntaxPosixBasic),
+		},
+	}
+	if !got.Include.Equal(want.Include) {
+		t.Errorf("wrong include\ngot:  %#v\nwant: %#v", got.Include, want.Include)
+	}
+	if len(got.Exclude) != len(want.Exclude) {
+		t.Fatalf("wrong number of excludes %d; want %d", len(got.Exclude), len(want.Exclude))
+	}
+	for i := range want.Exclude {
+		if !got.Exclude[i].Equal(want.Exclude[i]) {
+			t.Errorf("wrong exclude %d\ngot:  %#v\nwant: %#v", i, got.Exclude[i], want.Exclude[i])
+		}
+	}
+
+	if !got.Include.Matches(`abbb`, NoMatchOpts) {
+		t.Errorf("unmarshaled include does not match")
+	}
+	if !got.Exclude[0].Matches(`abc`, NoMatchOpts) {
+		t.Errorf("unmarshaled exclude does not match")
+	}
+}
+
+func TestRegexUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		Src     string
+		WantErr string
+	}{
+		{
+			`"[]"`,
+			`empty char-class`,
+		},
+		{
+			`{"pattern": "a", "syntax": "Cobol"}`,
+			`unsupported regex syntax "Cobol"`,
+		},
+		{
+			`{"pattern": "a", "options": ["Fast"]}`,
+			`unsupported regex option "Fast"`,
+		},
+		{
+			`12`,
+			`regex must be either a string or an object: json: cannot unmarshal number into Go value of type onig.regexJSON`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Src, func(t *testing.T) {
+			var r Regex
+			err := json.Unmarshal([]byte(test.Src), &r)
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if got := err.Error(); got != test.WantErr {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.WantErr)
+			}
+		})
+	}
+}
+
+func TestRegexUnmarshalText(t *testing.T) {
+	r := new(Regex)
+	if err := r.UnmarshalText([]byte(`a+`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := MustNewRegex(`a+`, NoCompileOpts, SyntaxRuby); !r.Equal(want) {
+		t.Errorf("wrong regex\ngot:  %#v\nwant: %#v", r, want)
+	}
+
+	// A failure leaves the regex uncompiled, so it can be tried again.
+	r = new(Regex)
+	if err := r.UnmarshalText([]byte(`[]`)); err == nil {
+		t.Fatal("no error for invalid pattern")
+	}
+	if err := r.UnmarshalText([]byte(`b+`)); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Matches(`bbb`, NoMatchOpts) {
+		t.Errorf("unmarshaled regex does not match")
+	}
+
+	got, err := r.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `b+` {
+		t.Errorf("wrong text %q; want %q", got, `b+`)
+	}
+}
+
+func TestRegexUnmarshalCompiled(t *testing.T) {
+	// A compiled regex may be shared, such as by the cache behind
+	// MustNewRegex, so unmarshaling must not change it.
+	cached := MustNewRegex(`abc`, NoCompileOpts, SyntaxRuby)
+	if err := json.Unmarshal([]byte(`"xyz"`), cached); err == nil {
+		t.Errorf("no error for unmarshaling JSON string into compiled regex")
+	}
+	if err := json.Unmarshal([]byte(`{"pattern":"xyz"}`), cached); err == nil {
+		t.Errorf("no error for unmarshaling JSON object into compiled regex")
+	}
+	if err := cached.UnmarshalText([]byte(`xyz`)); err == nil {
+		t.Errorf("no error for unmarshaling text into compiled regex")
+	}
+
+	again := MustNewRegex(`abc`, NoCompileOpts, SyntaxRuby)
+	if got := again.Pattern(); got != `abc` {
+		t.Errorf("cached regex has pattern %q; want %q", got, `abc`)
+	}
+	if ok, err := MatchString(`abc`, `abc`); !ok || err != nil {
+		t.Errorf("MatchString returned %v, %v; want true", ok, err)
+	}
+}
+
+func TestRegexFlag(t *testing.T) {
+	var include RegexFlag
+	exclude := RegexFlag{Options: OptIgnoreCase, Syntax: SyntaxPerl}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&include, "include", "")
+	fs.Var(&exclude, "exclude", "")
+
+	if err := fs.Parse([]string{"-exclude", "a", "-exclude", "B+"}); err != nil {
+		t.Fatal(err)
+	}
+	if include.Regex != nil {
+		t.Errorf("include is set, but should not be")
+	}
+	if want := MustNewRegex(`B+`, OptIgnoreCase, SyntaxPerl); !exclude.Regex.Equal(want) {
+		t.Errorf("wrong exclude\ngot:  %#v\nwant: %#v", exclude.Regex, want)
+	}
+	if got, want := fs.Lookup("exclude").Value.String(), `B+`; got != want {
+		t.Errorf("wrong String result %q; want %q", got, want)
+	}
+
+	if err := fs.Parse([]string{"-include", "["}); err == nil {
+		t.Errorf("no error for invalid pattern")
+	}
+}
+
+func TestMatchMarshalJSON(t *testing.T) {
+	re := MustNewRegex(`(?<year>\d{4})-(?<month>\d\d)(?:-(?<day>\d\d))?`, NoCompileOpts, SyntaxRuby)
+	input := "on 2024-06 at"
+
+	tests := []struct {
+		Name  string
+		Value any
+		Want  string
+	}{
+		{
+			"match",
+			re.Search(input, NoMatchOpts),
+			`{"bounds":{"start":3,"end":10},"captures":[{"start":3,"end":7},{"start":8,"end":10},null]}`,
+		},
+		{
+			"subject match",
+			NewSubject(input).Search(re, NoMatchOpts),
+			`{"bounds":{"start":3,"end":10,"text":"2024-06"},` +
+				`"captures":[{"start":3,"end":7,"text":"2024"},{"start":8,"end":10,"text":"06"},null],` +
+				`"named":{"day":null,"month":{"start":8,"end":10,"text":"06"},"year":{"start":3,"end":7,"text":"2024"}}}`,
+		},
+		{
+			"no captures",
+			MustNewRegex(`x*`, NoCompileOpts, SyntaxRuby).Search("ab", NoMatchOpts),
+			`{"bounds":{"start":0,"end":0},"captures":[]}`,
+		},
+		{
+			"no match",
+			re.Search("none", NoMatchOpts),
+			`null`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			got, err := json.Marshal(test.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.Want {
+				t.Errorf("wrong result\ngot:  %s\nwant: %s", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestMatchJSONRoundTrip(t *testing.T) {
+	re := MustNewRegex(`(?<a>a)|(?<b>b)(?<c>c)?`, NoCompileOpts, SyntaxRuby)
+	for _, input := range []string{"xa", "xbc", "xb"} {
+		want := NewSubject(input).Search(re, NoMatchOpts)
+		src, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var raw MatchJSON
+		if err := json.Unmarshal(src, &raw); err != nil {
+			t.Fatal(err)
+		}
+		got, err := raw.Match()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want.Match) {
+			t.Errorf("wrong match for %q\ngot:  %#v\nwant: %#v", input, got, want.Match)
+		}
+		if text := *raw.Bounds.Text; text != want.Text() {
+			t.Errorf("wrong text for %q %q; want %q", input, text, want.Text())
+		}
+		if c := raw.Named["c"]; (c == nil) != (want.Capture(3).Start < 0) {
+			t.Errorf("wrong named capture for %q %#v", input, c)
+		}
+	}
+}
+
+func TestMatchUnmarshalJSON(t *testing.T) {
+	re := MustNewRegex(`(a)|(b)(c)?`, OptCaptureGroup, SyntaxRuby)
+	type result struct {
+		Match *Match `json:"match"`
+	}
+
+	for _, input := range []string{"xa", "xbc", "xb"} {
+		want := re.Search(input, NoMatchOpts)
+		src, err := json.Marshal(result{want})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got result
+		if err := json.Unmarshal(src, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Match.Equal(want) {
+			t.Errorf("wrong match for %q\ngot:  %#v\nwant: %#v", input, got.Match, want)
+		}
+
+		// Unmarshaling into an existing match replaces its spans.
+		reused := mustFakeMatch([]Span{{0, 1}})
+		if err := json.Unmarshal(src, &result{reused}); err != nil {
+			t.Fatal(err)
+		}
+		if !reused.Equal(want) {
+			t.Errorf("wrong reused match for %q\ngot:  %#v\nwant: %#v", input, reused, want)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"captures":[]}`), new(Match)); err == nil || err.Error() != "match has no bounds" {
+		t.Errorf("wrong error %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"bounds":{"start":0,"end":1}}`), new(SubjectMatch)); err == nil {
+		t.Errorf("no error for unmarshaling into SubjectMatch")
+	}
+}
+
+func TestMatchJSONErrors(t *testing.T) {
+	tests := []struct {
+		Src     string
+		WantErr string
+	}{
+		{
+			`{"captures":[]}`,
+			`match has no bounds`,
+		},
+		{
+			`{"bounds":{"start":0,"end":1},"captures":[{"start":2,"end":1}]}`,
+			`invalid span [2:1] for capture 1`,
+		},
+		{
+			`{"bounds":{"start":-1,"end":1}}`,
+			`invalid span [-1:1] for capture 0`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Src, func(t *testing.T) {
+			var raw MatchJSON
+			if err := json.Unmarshal([]byte(test.Src), &raw); err != nil {
+				t.Fatal(err)
+			}
+			_, err := raw.Match()
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if got := err.Error(); got != test.WantErr {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.WantErr)
+			}
+		})
+	}
+}
@@ -3,11 +3,46 @@ package onig
 import "fmt"
 
 // Match describes how a pattern matched a particular string or byte array.
+//
+// A Match may be read concurrently from multiple goroutines, but it must not
+// be passed to methods like Regex.SearchInto or Match.Reset while any other
+// goroutine is using it.
 type Match struct {
+	// This aligns c as C requires, including within a slab of matches.
+	_ [0]uint64
+
 	// c is a buffer into which the OnigRegion data will be placed.
 	// It is opaque to Go code. Bindings code (in bindings.go) can access the
 	// typed pointer to this via method cPtr.
 	c [regionSizeof]byte
+
+	// final is true once a finalizer has been set to free the buffers that
+	// c points to. A zero Match has no buffers and so needs no finalizer
+	// until some are allocated.
+	final bool
+}
+
+// NewMatch allocates a new, empty Match object that can be passed to methods
+// like Regex.SearchInto, which populate an existing match rather than
+// allocating a new one on each call.
+//
+// Match objects must always be allocated either by this function or by one
+// of the Regex methods that returns a match. The zero value of Match is not
+// valid.
+func NewMatch() *Match {
+	m := new(Match)
+	matchInit(m)
+	return m
+}
+
+// Reset clears all of the captures in the receiver, including the overall
+// bounds, so that they are all unset.
+//
+// The receiver retains any buffers it has already allocated, so it can be
+// passed to methods like Regex.SearchInto without allocating any further
+// memory, as long as the new regex has no more captures than the previous.
+func (m *Match) Reset() {
+	matchReset(m)
 }
 
 // Bounds returns a span describing the whole match.
@@ -48,6 +83,15 @@ func (m *Match) SliceAround(b []byte) (before, inside, after []byte) {
 	return b[:span.Start], b[span.Start:span.End], b[span.End:]
 }
 
+// Bind returns a SubjectMatch for the receiver, which must have been found
+// by the given regex in the given string, so that the text of its captures
+// can be retrieved without supplying the string again. The Subject method of
+// the result returns a Subject for the string, which can be used for
+// further searches.
+func (m *Match) Bind(re *Regex, s string) *SubjectMatch {
+	return &SubjectMatch{Match: m, subject: &Subject{s: s}, re: re}
+}
+
 // Capture returns the number of captures.
 func (m *Match) CaptureCount() int {
 	return matchCaptureCount(m)
@@ -91,7 +135,7 @@ func (m *Match) GoString() string {
 // function will panic.
 func mustFakeMatch(spans []Span) *Match {
 	m := new(Match)
-	err := matchInitFake(m, spans)
+	err := matchSetSpans(m, spans)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -0,0 +1,142 @@
+package onig
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Matcher is the set of searching and matching methods offered by Regex,
+// as an interface so that code can accept either a Regex or another regular
+// expression engine adapted to the same API, such as by NewRegexpMatcher.
+type Matcher interface {
+	// Match tests whether the pattern matches a prefix of the given string.
+	Match(s string, opts MatchOptions) *Match
+
+	// Search finds the first match of the pattern in the given string.
+	Search(s string, opts MatchOptions) *Match
+
+	// SearchAll finds all of the successive, non-overlapping matches of the
+	// pattern in the given string.
+	SearchAll(s string, opts MatchOptions) []*Match
+
+	// CaptureCount returns the number of capture groups in the pattern.
+	CaptureCount() int
+
+	// NamedCaptures returns the indices of the capture groups with each
+	// name in the pattern.
+	NamedCaptures() map[string][]int
+}
+
+var _ Matcher = (*Regex)(nil)
+
+// NamedCapture returns the span of the capture with the given name in a
+// match that was produced by the given matcher. If there is more than one
+// group with that name then the result is the last one that participated in
+// the match, as in Ruby.
+//
+// The result is Span{-1, -1} if no group with the given name participated
+// in the match, including if there is no group with that name.
+func NamedCapture(matcher Matcher, m *Match, name string) Span {
+	idxs := matcher.NamedCaptures()[name]
+	for i := len(idxs) - 1; i >= 0; i-- {
+		if span := m.Capture(idxs[i]); span.Start >= 0 {
+			return span
+		}
+	}
+	return Span{-1, -1}
+}
+
+// regexpMatcher is the implementation of Matcher returned by
+// NewRegexpMatcher.
+type regexpMatcher struct {
+	re *regexp.Regexp
+
+	// anchored is re anchored at the start of the text, for method Match.
+	anchored *regexp.Regexp
+}
+
+// NewRegexpMatcher returns a Matcher that uses the given regexp from the
+// Go standard library, so that code written against Matcher can use either
+// engine.
+//
+// The results follow the conventions of package regexp, including its
+// syntax and its handling of empty matches in SearchAll. A Regexp that was
+// compiled with regexp.CompilePOSIX or has had its Longest method called
+// still uses leftmost-longest semantics for Search and SearchAll, but not for
+// Match.
+//
+// The regexp engine has no equivalent of the match options, so the
+// methods of the returned matcher panic if given any options other than
+// NoMatchOpts.
+func NewRegexpMatcher(re *regexp.Regexp) Matcher {
+	return &regexpMatcher{
+		re:       re,
+		anchored: regexp.MustCompile(`\A(?:` + re.String() + `)`),
+	}
+}
+
+func (m *regexpMatcher) Match(s string, opts MatchOptions) *Match {
+	checkNoMatchOpts(opts)
+	return regexpMatch(m.anchored.FindStringSubmatchIndex(s))
+}
+
+func (m *regexpMatcher) Search(s string, opts MatchOptions) *Match {
+	checkNoMatchOpts(opts)
+	return regexpMatch(m.re.FindStringSubmatchIndex(s))
+}
+
+func (m *regexpMatcher) SearchAll(s string, opts MatchOptions) []*Match {
+	checkNoMatchOpts(opts)
+	all := m.re.FindAllStringSubmatchIndex(s, -1)
+	if all == nil {
+		return nil
+	}
+	ret := make([]*Match, len(all))
+	for i, a := range all {
+		ret[i] = regexpMatch(a)
+	}
+	return ret
+}
+
+func (m *regexpMatcher) CaptureCount() int {
+	return m.re.NumSubexp()
+}
+
+func (m *regexpMatcher) NamedCaptures() map[string][]int {
+	var ret map[string][]int
+	for i, name := range m.re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string][]int)
+		}
+		ret[name] = append(ret[name], i)
+	}
+	return ret
+}
+
+// regexpMatch converts a result from one of the regexp submatch index
+// methods into a Match.
+func regexpMatch(a []int) *Match {
+	if a == nil {
+		return nil
+	}
+	spans := make([]Span, len(a)/2)
+	for i := range spans {
+		spans[i] = Span{a[2*i], a[2*i+1]}
+	}
+	m := new(Match)
+	if err := matchSetSpans(m, spans); err != nil {
+		// This fails only if Oniguruma cannot allocate memory for the
+		// spans, which Go code treats as fatal.
+		panic(fmt.Sprintf("onig: allocating match: %s", err))
+	}
+	return m
+}
+
+func checkNoMatchOpts(opts MatchOptions) {
+	if opts != NoMatchOpts {
+		panic(fmt.Sprintf("regexp matcher does not support match options %#x", uint(opts)))
+	}
+}
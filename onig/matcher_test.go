@@ -0,0 +1,147 @@
+package onig
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		Pattern       string
+		Str           string
+		WantMatch     *Match
+		WantSearch    *Match
+		WantSearchAll []*Match
+	}{
+		{
+			`b+`,
+			`abbcb`,
+			nil,
+			mustFakeMatch([]Span{{1, 3}}),
+			[]*Match{
+				mustFakeMatch([]Span{{1, 3}}),
+				mustFakeMatch([]Span{{4, 5}}),
+			},
+		},
+		{
+			`(a)(x)?`,
+			`aba`,
+			mustFakeMatch([]Span{{0, 1}, {0, 1}, {-1, -1}}),
+			mustFakeMatch([]Span{{0, 1}, {0, 1}, {-1, -1}}),
+			[]*Match{
+				mustFakeMatch([]Span{{0, 1}, {0, 1}, {-1, -1}}),
+				mustFakeMatch([]Span{{2, 3}, {2, 3}, {-1, -1}}),
+			},
+		},
+		{
+			`(?<word>\w+)`,
+			`hi there`,
+			mustFakeMatch([]Span{{0, 2}, {0, 2}}),
+			mustFakeMatch([]Span{{0, 2}, {0, 2}}),
+			[]*Match{
+				mustFakeMatch([]Span{{0, 2}, {0, 2}}),
+				mustFakeMatch([]Span{{3, 8}, {3, 8}}),
+			},
+		},
+		{
+			`z`,
+			`abc`,
+			nil,
+			nil,
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		regex, err := NewRegex(test.Pattern, NoCompileOpts, SyntaxPerlNG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matchers := map[string]Matcher{
+			"Regex":  regex,
+			"regexp": NewRegexpMatcher(regexp.MustCompile(test.Pattern)),
+		}
+		for name, matcher := range matchers {
+			t.Run(fmt.Sprintf("%s %q in %q", name, test.Pattern, test.Str), func(t *testing.T) {
+				if got := matcher.Match(test.Str, NoMatchOpts); !got.Equal(test.WantMatch) {
+					t.Errorf("wrong Match result\ngot:  %#v\nwant: %#v", got, test.WantMatch)
+				}
+				if got := matcher.Search(test.Str, NoMatchOpts); !got.Equal(test.WantSearch) {
+					t.Errorf("wrong Search result\ngot:  %#v\nwant: %#v", got, test.WantSearch)
+				}
+				got := matcher.SearchAll(test.Str, NoMatchOpts)
+				if len(got) != len(test.WantSearchAll) {
+					t.Fatalf("wrong number of SearchAll results %d; want %d", len(got), len(test.WantSearchAll))
+				}
+				for i := range got {
+					if !got[i].Equal(test.WantSearchAll[i]) {
+						t.Errorf("wrong SearchAll result %d\ngot:  %#v\nwant: %#v", i, got[i], test.WantSearchAll[i])
+					}
+				}
+				if got, want := matcher.CaptureCount(), regex.CaptureCount(); got != want {
+					t.Errorf("wrong CaptureCount %d; want %d", got, want)
+				}
+				if got, want := matcher.NamedCaptures(), regex.NamedCaptures(); !reflect.DeepEqual(got, want) {
+					t.Errorf("wrong NamedCaptures\ngot:  %#v\nwant: %#v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestNamedCapture(t *testing.T) {
+	regex := MustNewRegex(`(?<x>a)|(?<x>b)(?<y>c)?`, NoCompileOpts, SyntaxRuby)
+	m := regex.Search(`b`, NoMatchOpts)
+	if got, want := NamedCapture(regex, m, "x"), (Span{0, 1}); got != want {
+		t.Errorf("wrong span for x %#v; want %#v", got, want)
+	}
+	if got, want := NamedCapture(regex, m, "y"), (Span{-1, -1}); got != want {
+		t.Errorf("wrong span for y %#v; want %#v", got, want)
+	}
+	if got, want := NamedCapture(regex, m, "z"), (Span{-1, -1}); got != want {
+		t.Errorf("wrong span for z %#v; want %#v", got, want)
+	}
+
+	adapted := NewRegexpMatcher(regexp.MustCompile(`(?P<x>a)(?P<y>b)?`))
+	m = adapted.Search(`xa`, NoMatchOpts)
+	if got, want := NamedCapture(adapted, m, "x"), (Span{1, 2}); got != want {
+		t.Errorf("wrong span for regexp x %#v; want %#v", got, want)
+	}
+}
+
+func TestRegexSearchAll(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Str     string
+	}{
+		{`a`, `banana`},
+		{`a*`, `baab`},
+		{`x*`, `日本`},
+		{`z`, `abc`},
+		{``, ``},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q", test.Pattern, test.Str), func(t *testing.T) {
+			r := MustNewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+
+			// SearchAll must find the same matches as ScanSpans, just with
+			// the captures included.
+			want := r.ScanSpans(test.Str, NoMatchOpts)
+			for name, got := range map[string][]*Match{
+				"SearchAll":      r.SearchAll(test.Str, NoMatchOpts),
+				"SearchAllBytes": r.SearchAllBytes([]byte(test.Str), NoMatchOpts),
+			} {
+				var gotSpans []Span
+				for _, m := range got {
+					gotSpans = append(gotSpans, m.Bounds())
+				}
+				if !reflect.DeepEqual(gotSpans, want) {
+					t.Errorf("wrong %s result\ngot:  %#v\nwant: %#v", name, gotSpans, want)
+				}
+			}
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package onig
 
+import (
+	"fmt"
+	"strings"
+)
+
 // CompileOptions is a bitmask type used to pass compile-time options to
 // NewRegex.
 type CompileOptions uint
@@ -17,6 +22,25 @@ const (
 	OptCaptureGroup     CompileOptions = optCaptureGroup
 )
 
+// String returns the names of the options in the receiver separated by "|",
+// such as "OptIgnoreCase|OptExtend", or "NoCompileOpts" if none are set.
+func (o CompileOptions) String() string {
+	if o == NoCompileOpts {
+		return "NoCompileOpts"
+	}
+	var names []string
+	for _, n := range compileOptionNames {
+		if o&n.Opt != 0 {
+			names = append(names, "Opt"+n.Name)
+			o &^= n.Opt
+		}
+	}
+	if o != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint(o)))
+	}
+	return strings.Join(names, "|")
+}
+
 // MatchOptions is a bitmask type used to pass match-time options to the
 // various match and search methods on type Regex.
 type MatchOptions uint
@@ -26,3 +50,21 @@ const (
 	OptNotBOL   MatchOptions = optNotBOL
 	OptNotEOL   MatchOptions = optNotEOL
 )
+
+// compileOptionNames gives the name used for each individual compile option
+// in the JSON representation of a Regex and in CompileOptions.String, in the
+// order they are written.
+var compileOptionNames = []struct {
+	Opt  CompileOptions
+	Name string
+}{
+	{OptIgnoreCase, "IgnoreCase"},
+	{OptExtend, "Extend"},
+	{OptMultiline, "Multiline"},
+	{OptSingleline, "Singleline"},
+	{OptFindLongest, "FindLongest"},
+	{OptFindNotEmpty, "FindNotEmpty"},
+	{OptNegateSingleline, "NegateSingleline"},
+	{OptDontCaptureGroup, "DontCaptureGroup"},
+	{OptCaptureGroup, "CaptureGroup"},
+}
@@ -0,0 +1,443 @@
+// Package pyre provides functions with the semantics of Python's "re"
+// module, implemented using Oniguruma, for code ported from Python.
+//
+// Results are reported using onig.Match and onig.Span, rather than a
+// separate match object type. The functions named after Python's are
+// available both at the package level, where compiled patterns are cached
+// as in Python, and as methods of a compiled Pattern.
+//
+// The Oniguruma version this module targets has no Python syntax of its
+// own, so patterns are compiled using onig.SyntaxPerlNG after translating
+// the constructs that are specific to Python: "(?P<name>...)" groups,
+// "(?P=name)" backreferences, "\Z", "{,n}" and the ASCII flag. Unnamed
+// groups capture even when named groups are present, as in Python.
+//
+// There are some differences in behavior from Python:
+//
+//   - All offsets are byte offsets into the UTF-8 text, rather than counts
+//     of code points.
+//   - The inline flags "(?a)", "(?L)" and "(?u)" are not supported, and
+//     neither are conditional groups "(?(1)yes|no)" or "\N{name}" escapes.
+//   - The ASCII flag restricts "\w", "\d", "\s" and "\b" and their
+//     negations to ASCII, but case-insensitive matching may still fold
+//     non-ASCII characters.
+//   - Groups that did not participate in a match appear as empty strings
+//     in the results of FindAll and Split, where Python would give None.
+//   - Some patterns that Python rejects are accepted, such as lookbehind
+//     assertions with alternatives of different lengths.
+package pyre
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// Flag is a set of flags that modify how a pattern is compiled, which can
+// be combined using the | operator.
+type Flag uint
+
+const (
+	// I performs case-insensitive matching.
+	I Flag = 1 << iota
+
+	// M makes "^" and "$" match at the start and end of each line, rather
+	// than only at the start and end of the text.
+	M
+
+	// S makes "." match any character, including a newline.
+	S
+
+	// X allows whitespace and comments in the pattern, for readability.
+	X
+
+	// A makes "\w", "\W", "\b", "\B", "\d", "\D", "\s" and "\S" match only
+	// ASCII characters.
+	A
+)
+
+// These are the long names for the flags, as in Python.
+const (
+	IGNORECASE = I
+	MULTILINE  = M
+	DOTALL     = S
+	VERBOSE    = X
+	ASCII      = A
+)
+
+// cacheSize is the capacity of the cache of compiled patterns, which is
+// the same as Python's.
+const cacheSize = 512
+
+var cache = onig.NewCache(cacheSize)
+
+// Pattern is a compiled regular expression, like a Python pattern object.
+//
+// A Pattern is safe for concurrent use by multiple goroutines.
+type Pattern struct {
+	pattern    string
+	flags      Flag
+	re         *onig.Regex
+	groupIndex map[string]int
+
+	// full is re anchored at the end of the text, for FullMatch.
+	full *onig.Regex
+
+	// nonEmpty is re with empty matches rejected, for continuing a scan
+	// at the end of an empty match.
+	nonEmpty *onig.Regex
+}
+
+// Compile compiles a regular expression pattern into a Pattern.
+func Compile(pattern string, flags Flag) (*Pattern, error) {
+	opts := onig.OptCaptureGroup
+	if flags&I != 0 {
+		opts |= onig.OptIgnoreCase
+	}
+	if flags&M != 0 {
+		opts |= onig.OptNegateSingleline
+	}
+	if flags&S != 0 {
+		opts |= onig.OptMultiline
+	}
+	if flags&X != 0 {
+		opts |= onig.OptExtend
+	}
+
+	translated := translate(pattern, flags)
+	re, err := cache.Get(translated, opts, onig.SyntaxPerlNG)
+	if err != nil {
+		return nil, err
+	}
+	groupIndex := make(map[string]int)
+	for name, idxs := range re.NamedCaptures() {
+		if len(idxs) > 1 {
+			return nil, fmt.Errorf("redefinition of group name %q as group %d; was group %d", name, idxs[1], idxs[0])
+		}
+		groupIndex[name] = idxs[0]
+	}
+
+	// In verbose mode the pattern might end with a comment, so the
+	// suffixes below must begin on a new line.
+	if flags&X != 0 {
+		translated += "\n"
+	}
+	full, err := cache.Get(`(?:`+translated+`)\z`, opts, onig.SyntaxPerlNG)
+	if err != nil {
+		return nil, err
+	}
+	nonEmpty, err := cache.Get(`(?:`+translated+`)(?!\G)`, opts, onig.SyntaxPerlNG)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pattern{
+		pattern:    pattern,
+		flags:      flags,
+		re:         re,
+		groupIndex: groupIndex,
+		full:       full,
+		nonEmpty:   nonEmpty,
+	}, nil
+}
+
+// MustCompile is like Compile but panics if the pattern cannot be compiled.
+func MustCompile(pattern string, flags Flag) *Pattern {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		panic(fmt.Sprintf("pyre: Compile(%q): %s", pattern, err))
+	}
+	return p
+}
+
+// asciiClasses gives the contents of a character class equivalent to each
+// of the class escapes under the ASCII flag.
+var asciiClasses = map[byte]string{
+	'w': `a-zA-Z0-9_`,
+	'W': `\x00-\x2F\x3A-\x40\x5B-\x5E\x60\x7B-\x{10FFFF}`,
+	'd': `0-9`,
+	'D': `\x00-\x2F\x3A-\x{10FFFF}`,
+	's': `\t\n\x0B\f\r\x20`,
+	'S': `\x00-\x08\x0E-\x1F\x21-\x{10FFFF}`,
+}
+
+// asciiBoundaries gives the equivalents of "\b" and "\B" under the ASCII
+// flag.
+var asciiBoundaries = map[byte]string{
+	'b': `(?:(?<=[a-zA-Z0-9_])(?![a-zA-Z0-9_])|(?<![a-zA-Z0-9_])(?=[a-zA-Z0-9_]))`,
+	'B': `(?:(?<=[a-zA-Z0-9_])(?=[a-zA-Z0-9_])|(?<![a-zA-Z0-9_])(?![a-zA-Z0-9_]))`,
+}
+
+// translate rewrites the Python-specific constructs in the given pattern
+// into their equivalents in onig.SyntaxPerlNG.
+func translate(expr string, flags Flag) string {
+	var buf strings.Builder
+	inClass := false
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == '\\' && i+1 < len(expr):
+			i++
+			c = expr[i]
+			switch {
+			case flags&A != 0 && inClass && asciiClasses[c] != "":
+				buf.WriteString(asciiClasses[c])
+			case flags&A != 0 && asciiClasses[c] != "":
+				buf.WriteString("[" + asciiClasses[c] + "]")
+			case flags&A != 0 && !inClass && asciiBoundaries[c] != "":
+				buf.WriteString(asciiBoundaries[c])
+			case !inClass && c == 'Z':
+				buf.WriteString(`\z`)
+			default:
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			}
+			continue
+		case inClass && c == ']':
+			inClass = false
+		case inClass && c == '[':
+			// Python has no nested classes, but Oniguruma does.
+			buf.WriteString(`\[`)
+			continue
+		case !inClass && c == '[':
+			inClass = true
+			buf.WriteByte(c)
+			// A "]" at the start of a class is literal.
+			if strings.HasPrefix(expr[i+1:], "^") {
+				i++
+				buf.WriteByte('^')
+			}
+			if strings.HasPrefix(expr[i+1:], "]") {
+				i++
+				buf.WriteString(`\]`)
+			}
+			continue
+		case !inClass && c == '#' && flags&X != 0:
+			end := strings.IndexByte(expr[i:], '\n')
+			if end < 0 {
+				end = len(expr) - i
+			}
+			buf.WriteString(expr[i : i+end])
+			i += end - 1
+			continue
+		case !inClass && strings.HasPrefix(expr[i:], "(?#"):
+			end := strings.IndexByte(expr[i:], ')')
+			if end < 0 {
+				end = len(expr) - i - 1
+			}
+			buf.WriteString(expr[i : i+end+1])
+			i += end
+			continue
+		case !inClass && strings.HasPrefix(expr[i:], "(?P<"):
+			buf.WriteString("(?<")
+			i += 3
+			continue
+		case !inClass && strings.HasPrefix(expr[i:], "(?P="):
+			if end := strings.IndexByte(expr[i:], ')'); end >= 0 {
+				buf.WriteString(`\k<` + expr[i+4:i+end] + `>`)
+				i += end
+				continue
+			}
+		case !inClass && strings.HasPrefix(expr[i:], "{,"):
+			// Python allows the minimum of a repetition to be omitted.
+			j := i + 2
+			for j < len(expr) && expr[j] >= '0' && expr[j] <= '9' {
+				j++
+			}
+			if j > i+2 && j < len(expr) && expr[j] == '}' {
+				buf.WriteString("{0,")
+				i++
+				continue
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// Escape escapes the special characters in the given string, so that it
+// can be used as a pattern that matches it literally.
+func Escape(s string) string {
+	var buf strings.Builder
+	buf.Grow(2 * len(s))
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("()[]{}?*+-|^$\\.&~# \t\n\r\v\f", s[i]) >= 0 {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// String returns the pattern that the receiver was compiled from.
+func (p *Pattern) String() string {
+	return p.pattern
+}
+
+// Flags returns the flags that the receiver was compiled with.
+func (p *Pattern) Flags() Flag {
+	return p.flags
+}
+
+// Groups returns the number of capture groups in the pattern.
+func (p *Pattern) Groups() int {
+	return p.re.CaptureCount()
+}
+
+// GroupIndex returns the index of each named group in the pattern.
+func (p *Pattern) GroupIndex() map[string]int {
+	ret := make(map[string]int, len(p.groupIndex))
+	for name, idx := range p.groupIndex {
+		ret[name] = idx
+	}
+	return ret
+}
+
+// Match returns the match of the pattern at the start of the given string,
+// or nil if there is none.
+func (p *Pattern) Match(s string) *onig.Match {
+	return p.re.Match(s, onig.NoMatchOpts)
+}
+
+// FullMatch returns the match of the pattern against the whole of the
+// given string, or nil if there is none.
+func (p *Pattern) FullMatch(s string) *onig.Match {
+	return p.full.Match(s, onig.NoMatchOpts)
+}
+
+// Search returns the first match of the pattern in the given string, or
+// nil if there is none.
+func (p *Pattern) Search(s string) *onig.Match {
+	return p.re.Search(s, onig.NoMatchOpts)
+}
+
+// FindIter returns all of the non-overlapping matches of the pattern in the
+// given string, in order.
+//
+// As in Python 3.7 and later, an empty match may immediately follow a
+// non-empty one.
+func (p *Pattern) FindIter(s string) []*onig.Match {
+	var ret []*onig.Match
+	p.scan(s, 0, func(m *onig.Match) {
+		ret = append(ret, m)
+	})
+	return ret
+}
+
+// FindAll returns the text of all of the non-overlapping matches of the
+// pattern in the given string.
+//
+// As in Python, what is returned for each match depends on the number of
+// groups in the pattern: the whole match if there are none, the only group
+// if there is one, or all of the groups otherwise. Each result is a slice
+// of one or more strings accordingly.
+func (p *Pattern) FindAll(s string) [][]string {
+	var ret [][]string
+	groups := p.Groups()
+	p.scan(s, 0, func(m *onig.Match) {
+		if groups == 0 {
+			ret = append(ret, []string{m.Bounds().Substr(s)})
+			return
+		}
+		ret = append(ret, groupStrings(s, m, groups))
+	})
+	return ret
+}
+
+// scan calls fn with each of the successive matches of the pattern in the
+// given string, up to the given limit if it is positive.
+//
+// As in Python, the search that follows an empty match must find a
+// non-empty match at the same position, or else it continues one character
+// later, where it may find another empty match.
+func (p *Pattern) scan(s string, limit int, fn func(m *onig.Match)) {
+	pos := 0
+	mustAdvance := false
+	for n := 0; (limit <= 0 || n < limit) && pos <= len(s); n++ {
+		var m *onig.Match
+		if mustAdvance {
+			m = p.nonEmpty.MatchAt(s, pos, onig.NoMatchOpts)
+		}
+		if m == nil {
+			if mustAdvance {
+				if pos == len(s) {
+					return
+				}
+				_, width := utf8.DecodeRuneInString(s[pos:])
+				pos += width
+			}
+			m = p.re.SearchFrom(s, pos, onig.NoMatchOpts)
+			if m == nil {
+				return
+			}
+		}
+		fn(m)
+		bounds := m.Bounds()
+		pos = bounds.End
+		mustAdvance = bounds.Start == bounds.End
+	}
+}
+
+// groupStrings returns the text of groups 1 to n of the given match, with
+// an empty string for each group that did not participate.
+func groupStrings(s string, m *onig.Match, n int) []string {
+	ret := make([]string, n)
+	for i := range ret {
+		if span := m.Capture(i + 1); span.Start >= 0 {
+			ret[i] = span.Substr(s)
+		}
+	}
+	return ret
+}
+
+// Match compiles the given pattern and returns its match at the start of
+// the given string, or nil if there is none.
+func Match(pattern, s string, flags Flag) (*onig.Match, error) {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	return p.Match(s), nil
+}
+
+// FullMatch compiles the given pattern and returns its match against the
+// whole of the given string, or nil if there is none.
+func FullMatch(pattern, s string, flags Flag) (*onig.Match, error) {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	return p.FullMatch(s), nil
+}
+
+// Search compiles the given pattern and returns its first match in the
+// given string, or nil if there is none.
+func Search(pattern, s string, flags Flag) (*onig.Match, error) {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	return p.Search(s), nil
+}
+
+// FindIter compiles the given pattern and returns all of its
+// non-overlapping matches in the given string, as for Pattern.FindIter.
+func FindIter(pattern, s string, flags Flag) ([]*onig.Match, error) {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	return p.FindIter(s), nil
+}
+
+// FindAll compiles the given pattern and returns the text of all of its
+// non-overlapping matches in the given string, as for Pattern.FindAll.
+func FindAll(pattern, s string, flags Flag) ([][]string, error) {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	return p.FindAll(s), nil
+}
@@ -0,0 +1,223 @@
+package pyre
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// The expected results in these tests were produced by Python 3.
+
+func TestFindAll(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Str     string
+		Flags   Flag
+		Want    [][]string
+	}{
+		{`x*`, `abxd`, 0, [][]string{{""}, {""}, {"x"}, {""}, {""}}},
+		{`x*?`, `xx`, 0, [][]string{{""}, {"x"}, {""}, {"x"}, {""}}},
+		{`(\w+)=(\d+)?`, `a=1 b= c=3`, 0, [][]string{{"a", "1"}, {"b", ""}, {"c", "3"}}},
+		{`(\w)\w`, `abcd`, 0, [][]string{{"a"}, {"c"}}},
+		{`^\w`, "ab\ncd", M, [][]string{{"a"}, {"c"}}},
+		{`^\w`, "ab\ncd", 0, [][]string{{"a"}}},
+		{`a.b`, "a\nb", S, [][]string{{"a\nb"}}},
+		{`a.b`, "a\nb", 0, nil},
+		{`A B # comment`, `ab`, I | X, [][]string{{"ab"}}},
+		{`\w+`, `héllo wörld`, A, [][]string{{"h"}, {"llo"}, {"w"}, {"rld"}}},
+		{`\w+`, `héllo wörld`, 0, [][]string{{"héllo"}, {"wörld"}}},
+		{`[\W]+`, `ab, é`, A, [][]string{{", é"}}},
+		{`\b\w`, `ab cd`, A, [][]string{{"a"}, {"c"}}},
+		{`(?P<x>a)(?P=x)`, `aaaa`, 0, [][]string{{"a"}, {"a"}}},
+		{`a{,2}`, `aaab`, 0, [][]string{{"aa"}, {"a"}, {""}, {""}}},
+		{`\d\Z`, "1\n2\n", 0, nil},
+		{`[]a]`, `a]`, 0, [][]string{{"a"}, {"]"}}},
+		{`[a[]`, `[`, 0, [][]string{{"["}}},
+		{"a # [\n b", `ab`, X, [][]string{{"ab"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q", test.Pattern, test.Str), func(t *testing.T) {
+			got, err := FindAll(test.Pattern, test.Str, test.Flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	p := MustCompile(`(?P<word>\w+)`, 0)
+
+	if got, want := p.Match(`ab cd`), mustMatch(t, `(\w+)`, `ab cd`); !got.Equal(want) {
+		t.Errorf("wrong Match result\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if got := p.Match(` ab`); got != nil {
+		t.Errorf("unexpected Match result %#v", got)
+	}
+	if got := p.FullMatch(`ab cd`); got != nil {
+		t.Errorf("unexpected FullMatch result %#v", got)
+	}
+	if got := p.FullMatch(`abcd`); got == nil || got.Bounds() != (onig.Span{Start: 0, End: 4}) {
+		t.Errorf("wrong FullMatch result %#v", got)
+	}
+	if got := p.Search(` ab`); got == nil || got.Capture(1) != (onig.Span{Start: 1, End: 3}) {
+		t.Errorf("wrong Search result %#v", got)
+	}
+	if got, want := p.GroupIndex(), map[string]int{"word": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong GroupIndex %#v; want %#v", got, want)
+	}
+
+	// Python's fullmatch backtracks to find a match of the whole string.
+	got, err := FullMatch(`a|ab`, `ab`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Bounds() != (onig.Span{Start: 0, End: 2}) {
+		t.Errorf("wrong FullMatch result for alternation %#v", got)
+	}
+
+	// The verbose flag must not let a trailing comment swallow the
+	// anchoring that FullMatch adds.
+	got, err = FullMatch(`a # comment`, `ab`, X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("unexpected FullMatch result with trailing comment %#v", got)
+	}
+}
+
+func mustMatch(t *testing.T, pattern, s string) *onig.Match {
+	t.Helper()
+	re := onig.MustNewRegex(pattern, onig.NoCompileOpts, onig.SyntaxPerlNG)
+	return re.Match(s, onig.NoMatchOpts)
+}
+
+func TestSubn(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Repl    string
+		Str     string
+		Count   int
+		Want    string
+		WantN   int
+	}{
+		{`x*`, `-`, `abxd`, 0, `-a-b--d-`, 5},
+		{`(?P<k>\w+)=(\w+)`, `\2=\g<k>`, `a=b c=d`, 0, `b=a d=c`, 2},
+		{`a`, `b`, `aaa`, 2, `bba`, 2},
+		{`(a)|b`, `[\1]`, `ab`, 0, `[a][]`, 2},
+		{`(a)`, `\101\0\\\n\&`, `a`, 0, "A\x00\\\n\\&", 1},
+		{``, `-`, `ab`, 0, `-a-b-`, 3},
+		{`z`, `-`, `ab`, 0, `ab`, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q with %q in %q", test.Pattern, test.Repl, test.Str), func(t *testing.T) {
+			got, n, err := Subn(test.Pattern, test.Repl, test.Str, test.Count, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.Want || n != test.WantN {
+				t.Errorf("wrong result %q, %d; want %q, %d", got, n, test.Want, test.WantN)
+			}
+		})
+	}
+}
+
+func TestSubFunc(t *testing.T) {
+	s := `a1b22`
+	got := MustCompile(`\d+`, 0).SubFunc(func(m *onig.Match) string {
+		return fmt.Sprintf("<%d>", m.Bounds().Len())
+	}, s, 0)
+	if want := `a<1>b<2>`; got != want {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+}
+
+func TestSubTemplateError(t *testing.T) {
+	tests := []struct {
+		Repl string
+		Want string
+	}{
+		{`\g<2>`, `invalid group reference 2 at position 3`},
+		{`\g<y>`, `unknown group name "y"`},
+		{`\q`, `bad escape \q at position 0`},
+		{`\`, `bad escape (end of pattern) at position 0`},
+		{`\3`, `invalid group reference 3 at position 1`},
+		{`\g`, `missing < at position 2`},
+		{`\g<1`, `missing >, unterminated name at position 3`},
+	}
+
+	p := MustCompile(`(?P<k>a)`, 0)
+	for _, test := range tests {
+		t.Run(test.Repl, func(t *testing.T) {
+			_, err := p.Sub(test.Repl, `a`, 0)
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if got := err.Error(); got != test.Want {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		Pattern  string
+		Str      string
+		MaxSplit int
+		Want     []string
+	}{
+		{`x*`, `axbc`, 0, []string{"", "a", "", "b", "c", ""}},
+		{`(a)|b`, `1a2b3`, 0, []string{"1", "a", "2", "", "3"}},
+		{`\W+`, `a, b, c`, 1, []string{"a", "b, c"}},
+		{`\b`, `a b`, 0, []string{"", "a", " ", "b", ""}},
+		{`,`, ``, 0, []string{""}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q", test.Pattern, test.Str), func(t *testing.T) {
+			got, err := Split(test.Pattern, test.Str, test.MaxSplit, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	_, err := Compile(`(?P<n>a)(?P<n>b)`, 0)
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if got, want := err.Error(), `redefinition of group name "n" as group 2; was group 1`; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+
+	if _, err := Compile(`(a`, 0); err == nil {
+		t.Error("unexpected success for unbalanced parenthesis")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	s := "a.b-c d#&~_é\t"
+	if got, want := Escape(s), "a\\.b\\-c\\ d\\#\\&\\~_é\\\t"; got != want {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+	for _, flags := range []Flag{0, X} {
+		p := MustCompile(Escape(s), flags)
+		if m := p.FullMatch(s); m == nil {
+			t.Errorf("escaped pattern with flags %#x does not match %q", flags, s)
+		}
+	}
+}
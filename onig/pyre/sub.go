@@ -0,0 +1,245 @@
+package pyre
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// templatePart is one part of a parsed replacement template: either
+// literal text, or a reference to a group.
+type templatePart struct {
+	lit   string
+	group int // -1 for literal text
+}
+
+// templateEscapes are the character escapes that are recognized in a
+// replacement template.
+var templateEscapes = map[byte]byte{
+	'a':  '\a',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
+	'\\': '\\',
+}
+
+// parseTemplate parses a replacement template for the receiver, returning
+// an error if it is malformed or refers to a group that does not exist.
+func (p *Pattern) parseTemplate(repl string) ([]templatePart, error) {
+	var parts []templatePart
+	var lit strings.Builder
+	addGroup := func(group int) {
+		if lit.Len() > 0 {
+			parts = append(parts, templatePart{lit: lit.String(), group: -1})
+			lit.Reset()
+		}
+		parts = append(parts, templatePart{group: group})
+	}
+
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		if c != '\\' {
+			lit.WriteByte(c)
+			continue
+		}
+		if i+1 == len(repl) {
+			return nil, fmt.Errorf("bad escape (end of pattern) at position %d", i)
+		}
+		pos := i
+		i++
+		c = repl[i]
+		switch {
+		case c == 'g':
+			rest := repl[i+1:]
+			if !strings.HasPrefix(rest, "<") {
+				return nil, fmt.Errorf("missing < at position %d", i+1)
+			}
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return nil, fmt.Errorf("missing >, unterminated name at position %d", i+2)
+			}
+			name := rest[1:end]
+			if name == "" {
+				return nil, fmt.Errorf("missing group name at position %d", i+2)
+			}
+			group, err := strconv.Atoi(name)
+			if err != nil {
+				idx, ok := p.groupIndex[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown group name %q", name)
+				}
+				group = idx
+			} else if group < 0 || group > p.Groups() {
+				return nil, fmt.Errorf("invalid group reference %d at position %d", group, i+2)
+			}
+			addGroup(group)
+			i += end + 1
+		case c == '0':
+			// An octal escape of up to three digits, including this one.
+			j := i + 1
+			for j < len(repl) && j < i+3 && isOctal(repl[j]) {
+				j++
+			}
+			v, _ := strconv.ParseUint(repl[i:j], 8, 8)
+			lit.WriteRune(rune(v))
+			i = j - 1
+		case c >= '1' && c <= '9':
+			// Three octal digits are an octal escape, and otherwise one or
+			// two digits are a group reference.
+			if i+2 < len(repl) && isOctal(c) && isOctal(repl[i+1]) && isOctal(repl[i+2]) {
+				v, _ := strconv.ParseUint(repl[i:i+3], 8, 16)
+				if v > 0o377 {
+					return nil, fmt.Errorf("octal escape value \\%s outside of range 0-0o377 at position %d", repl[i:i+3], pos)
+				}
+				lit.WriteRune(rune(v))
+				i += 2
+				continue
+			}
+			j := i + 1
+			if j < len(repl) && repl[j] >= '0' && repl[j] <= '9' {
+				j++
+			}
+			group, _ := strconv.Atoi(repl[i:j])
+			if group > p.Groups() {
+				return nil, fmt.Errorf("invalid group reference %d at position %d", group, i)
+			}
+			addGroup(group)
+			i = j - 1
+		case templateEscapes[c] != 0:
+			lit.WriteByte(templateEscapes[c])
+		case c < 0x80 && ('a' <= c|0x20 && c|0x20 <= 'z'):
+			return nil, fmt.Errorf("bad escape \\%c at position %d", c, pos)
+		default:
+			// Other escapes are left alone.
+			lit.WriteByte('\\')
+			lit.WriteByte(c)
+		}
+	}
+	if lit.Len() > 0 {
+		parts = append(parts, templatePart{lit: lit.String(), group: -1})
+	}
+	return parts, nil
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// expand appends the result of the given template for the given match to
+// buf. Groups that did not participate in the match are replaced with
+// nothing.
+func expand(buf []byte, parts []templatePart, s string, m *onig.Match) []byte {
+	for _, part := range parts {
+		if part.group < 0 {
+			buf = append(buf, part.lit...)
+			continue
+		}
+		if span := m.Capture(part.group); span.Start >= 0 {
+			buf = append(buf, span.Substr(s)...)
+		}
+	}
+	return buf
+}
+
+// Sub returns the given string with the leftmost non-overlapping matches
+// of the pattern replaced using the replacement template repl, up to the
+// given count of replacements if it is positive.
+//
+// In the template, "\g<name>", "\g<1>" and "\1" refer to groups in the
+// match, and the usual character escapes such as "\n" are recognized. An
+// error is returned if the template is malformed or refers to a group that
+// does not exist.
+func (p *Pattern) Sub(repl, s string, count int) (string, error) {
+	ret, _, err := p.Subn(repl, s, count)
+	return ret, err
+}
+
+// Subn is like Sub but also returns the number of replacements made.
+func (p *Pattern) Subn(repl, s string, count int) (string, int, error) {
+	parts, err := p.parseTemplate(repl)
+	if err != nil {
+		return "", 0, err
+	}
+	ret, n := p.subn(s, count, func(buf []byte, m *onig.Match) []byte {
+		return expand(buf, parts, s, m)
+	})
+	return ret, n, nil
+}
+
+// SubFunc is like Sub but replaces each match with the result of calling
+// repl with that match, which is substituted directly.
+func (p *Pattern) SubFunc(repl func(m *onig.Match) string, s string, count int) string {
+	ret, _ := p.subn(s, count, func(buf []byte, m *onig.Match) []byte {
+		return append(buf, repl(m)...)
+	})
+	return ret
+}
+
+func (p *Pattern) subn(s string, count int, repl func(buf []byte, m *onig.Match) []byte) (string, int) {
+	var buf []byte
+	last, n := 0, 0
+	p.scan(s, count, func(m *onig.Match) {
+		bounds := m.Bounds()
+		buf = append(buf, s[last:bounds.Start]...)
+		buf = repl(buf, m)
+		last = bounds.End
+		n++
+	})
+	if n == 0 {
+		return s, 0
+	}
+	buf = append(buf, s[last:]...)
+	return string(buf), n
+}
+
+// Split splits the given string at the matches of the pattern, up to the
+// given number of splits if maxsplit is positive. The text of each group
+// in the pattern is included in the result after the text that precedes
+// the match it belongs to.
+func (p *Pattern) Split(s string, maxsplit int) []string {
+	var ret []string
+	last := 0
+	groups := p.Groups()
+	p.scan(s, maxsplit, func(m *onig.Match) {
+		bounds := m.Bounds()
+		ret = append(ret, s[last:bounds.Start])
+		ret = append(ret, groupStrings(s, m, groups)...)
+		last = bounds.End
+	})
+	return append(ret, s[last:])
+}
+
+// Sub compiles the given pattern and replaces its matches in the given
+// string as for Pattern.Sub.
+func Sub(pattern, repl, s string, count int, flags Flag) (string, error) {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		return "", err
+	}
+	return p.Sub(repl, s, count)
+}
+
+// Subn compiles the given pattern and replaces its matches in the given
+// string as for Pattern.Subn.
+func Subn(pattern, repl, s string, count int, flags Flag) (string, int, error) {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		return "", 0, err
+	}
+	return p.Subn(repl, s, count)
+}
+
+// Split compiles the given pattern and splits the given string at its
+// matches as for Pattern.Split.
+func Split(pattern, s string, maxsplit int, flags Flag) ([]string, error) {
+	p, err := Compile(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	return p.Split(s, maxsplit), nil
+}
@@ -0,0 +1,133 @@
+package onig
+
+import (
+	"strings"
+	"unicode"
+)
+
+// QuoteMeta returns a pattern that matches the given string literally when
+// compiled with the given syntax, by escaping each character that would
+// otherwise have a special meaning in that syntax.
+//
+// The set of special characters differs between syntaxes. For example, in
+// SyntaxPosixBasic a "(" is literal and only "\(" begins a group, so
+// QuoteMeta leaves "(" unescaped for that syntax. A character whose escaped
+// form is itself special, if any, is written as a single-character bracket
+// expression instead.
+//
+// Whitespace and "#" are escaped only if the syntax enables OptExtend by
+// default, so the result is not suitable for use in a pattern that is
+// compiled with OptExtend.
+func QuoteMeta(s string, syntax Syntax) string {
+	q := newQuoter(syntax)
+	var buf strings.Builder
+	buf.Grow(len(s))
+	for _, r := range s {
+		q.writeLiteral(&buf, r)
+	}
+	return buf.String()
+}
+
+// quoter knows which characters are special in a particular syntax.
+type quoter struct {
+	info syntaxInfo
+
+	// esc is the escape character for the syntax, or -1 if the syntax does
+	// not support escaping.
+	esc rune
+}
+
+func newQuoter(syntax Syntax) quoter {
+	info := syntaxGetInfo(syntax)
+	esc := info.Esc
+	if esc == synIneffectiveMetaChar || info.Op2&synOp2IneffectiveEscape != 0 {
+		esc = -1
+	}
+	return quoter{
+		info: info,
+		esc:  esc,
+	}
+}
+
+func (q quoter) writeLiteral(buf *strings.Builder, r rune) {
+	switch {
+	case !q.special(r):
+		buf.WriteRune(r)
+	case q.esc != -1 && !q.escapedSpecial(r):
+		buf.WriteRune(q.esc)
+		buf.WriteRune(r)
+	case q.info.Op&synOpBracketCC != 0:
+		buf.WriteByte('[')
+		buf.WriteRune(r)
+		buf.WriteByte(']')
+	default:
+		// There's no way to make this character literal in this syntax.
+		buf.WriteRune(r)
+	}
+}
+
+// special returns true if the given character has a special meaning in the
+// syntax when it is not escaped.
+func (q quoter) special(r rune) bool {
+	op := q.info.Op
+	if r == q.esc {
+		return true
+	}
+	if op&synOpVariableMetaChars != 0 && r != synIneffectiveMetaChar {
+		switch r {
+		case q.info.AnyChar, q.info.AnyTime, q.info.ZeroOrOneTime, q.info.OneOrMoreTime, q.info.AnyCharAnyTime:
+			return true
+		}
+	}
+	if q.info.Options&OptExtend != 0 && (r == '#' || unicode.IsSpace(r)) {
+		return true
+	}
+
+	switch r {
+	case '.':
+		return op&synOpDotAnychar != 0
+	case '*':
+		return op&synOpAsteriskZeroInf != 0
+	case '+':
+		return op&synOpPlusOneInf != 0
+	case '?':
+		return op&synOpQmarkZeroOne != 0
+	case '{':
+		return op&synOpBraceInterval != 0
+	case '|':
+		return op&synOpVbarAlt != 0
+	case '(', ')':
+		return op&synOpLparenSubexp != 0
+	case '[':
+		return op&synOpBracketCC != 0
+	case '^', '$':
+		return op&synOpLineAnchor != 0
+	default:
+		return false
+	}
+}
+
+// escapedSpecial returns true if the given character has a special meaning
+// in the syntax when it is preceded by the escape character.
+//
+// This considers only the characters that special can return true for,
+// since QuoteMeta never escapes any others.
+func (q quoter) escapedSpecial(r rune) bool {
+	op := q.info.Op
+	switch r {
+	case '*':
+		return op&synOpEscAsteriskZeroInf != 0
+	case '+':
+		return op&synOpEscPlusOneInf != 0
+	case '?':
+		return op&synOpEscQmarkZeroOne != 0
+	case '{':
+		return op&synOpEscBraceInterval != 0
+	case '|':
+		return op&synOpEscVbarAlt != 0
+	case '(', ')':
+		return op&synOpEscLparenSubexp != 0
+	default:
+		return false
+	}
+}
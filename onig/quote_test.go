@@ -0,0 +1,92 @@
+package onig
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQuoteMeta(t *testing.T) {
+	tests := []struct {
+		Str    string
+		Syntax Syntax
+		Want   string
+	}{
+		{`hello world`, SyntaxRuby, `hello world`},
+		{`a.b*c`, SyntaxRuby, `a\.b\*c`},
+		{`(a|b)+?`, SyntaxRuby, `\(a\|b\)\+\?`},
+		{`x{2}[y]^$\`, SyntaxRuby, `x\{2}\[y]\^\$\\`},
+		{`(a|b)+?`, SyntaxPosixBasic, `(a|b)+?`},
+		{`a.b*c`, SyntaxPosixBasic, `a\.b\*c`},
+		{`(a|b)+?`, SyntaxPosixExtended, `\(a\|b\)\+\?`},
+		{`(a|b)+?`, SyntaxEmacs, `(a|b)\+\?`},
+		{`(a|b)+?`, SyntaxGrep, `(a|b)+?`},
+		{`a.b*c\`, SyntaxAsIs, `a.b*c\`},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q", test.Str), func(t *testing.T) {
+			got := QuoteMeta(test.Str, test.Syntax)
+			if got != test.Want {
+				t.Errorf("wrong result\ngot:  %s\nwant: %s", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestQuoteMetaRoundTrip(t *testing.T) {
+	syntaxes := map[string]Syntax{
+		"AsIs":          SyntaxAsIs,
+		"PosixBasic":    SyntaxPosixBasic,
+		"PosixExtended": SyntaxPosixExtended,
+		"Emacs":         SyntaxEmacs,
+		"Grep":          SyntaxGrep,
+		"GNU":           SyntaxGNU,
+		"Java":          SyntaxJava,
+		"Perl":          SyntaxPerl,
+		"PerlNG":        SyntaxPerlNG,
+		"Ruby":          SyntaxRuby,
+	}
+	strs := []string{
+		`hello world`,
+		"!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+		`a.b`,
+		`(a|b)*`,
+		`x{2,3}`,
+		`^start$`,
+		`[[:alpha:]]`,
+		`\d\w\1`,
+		`(?<name>x)`,
+		`日本.語`,
+	}
+
+	for name, syntax := range syntaxes {
+		for _, s := range strs {
+			t.Run(fmt.Sprintf("%s %q", name, s), func(t *testing.T) {
+				pattern := QuoteMeta(s, syntax)
+				r, err := NewRegex(pattern, NoCompileOpts, syntax)
+				if err != nil {
+					t.Fatalf("failed to compile %q: %s", pattern, err)
+				}
+
+				m := r.Match(s, NoMatchOpts)
+				if m == nil {
+					t.Fatalf("%q does not match %q", pattern, s)
+				}
+				if got, want := m.Bounds(), (Span{0, len(s)}); got != want {
+					t.Errorf("%q matches %#v of %q; want %#v", pattern, got, s, want)
+				}
+
+				// The literal must also be found in the middle of a longer
+				// string, where anchors and the like would fail.
+				padded := "zz" + s + "zz"
+				m = r.Search(padded, NoMatchOpts)
+				if m == nil {
+					t.Fatalf("%q not found in %q", pattern, padded)
+				}
+				if got, want := m.Bounds(), (Span{2, 2 + len(s)}); got != want {
+					t.Errorf("%q matches %#v of %q; want %#v", pattern, got, padded, want)
+				}
+			})
+		}
+	}
+}
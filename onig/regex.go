@@ -1,19 +1,39 @@
 package onig
 
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
 // Regex is the main type in this package, representing a compiled regular
 // expression.
+//
+// A Regex is immutable once compiled, so a single Regex may be used
+// concurrently from multiple goroutines.
 type Regex struct {
 	// c is a buffer into which the oniguruma regex_t data will be placed.
 	// It is opaque to Go code. Bindings code (in bindings.go) can access the
 	// typed pointer to this via method cPtr.
 	c [regexSizeof]byte
+
+	// These record the arguments the regex was compiled from, for
+	// introspection.
+	pattern string
+	options CompileOptions
+	syntax  Syntax
 }
 
+// Encoding is an enumeration of the text encodings a Regex can operate on.
+type Encoding string
+
+// EncodingUTF8 is the only encoding supported by this package.
+const EncodingUTF8 Encoding = "UTF-8"
+
 // NewRegex compiles the given regex pattern using the selected syntax,
 // returning a newly-allocated Regex object.
 func NewRegex(pattern string, options CompileOptions, syntax Syntax) (*Regex, error) {
-	r := new(Regex)
-	err := regexInit(r, pattern, options, syntax)
+	r := &Regex{}
+	err := r.compile(pattern, options, syntax)
 	if err != nil {
 		// Don't return our probably-invalid Regex object, since accessing it
 		// is likely to cause crashes.
@@ -22,58 +42,254 @@ func NewRegex(pattern string, options CompileOptions, syntax Syntax) (*Regex, er
 	return r, nil
 }
 
+// compile compiles the given pattern into the receiver, which must not
+// already be compiled. If compilation fails then the receiver remains
+// uncompiled.
+func (r *Regex) compile(pattern string, options CompileOptions, syntax Syntax) error {
+	if err := regexInit(r, pattern, options, syntax); err != nil {
+		return err
+	}
+	r.pattern = pattern
+	r.options = options
+	r.syntax = syntax
+	return nil
+}
+
+// MustNewRegex is like NewRegex except that it panics if the pattern cannot
+// be compiled. It is intended for initializing global variables holding
+// patterns that are known to be valid.
+//
+// Regexes returned by MustNewRegex come from the same shared cache as is
+// used by MatchString and SearchString.
+func MustNewRegex(pattern string, options CompileOptions, syntax Syntax) *Regex {
+	r, err := defaultCache.Get(pattern, options, syntax)
+	if err != nil {
+		panic(fmt.Sprintf("onig: compiling %q: %s", pattern, err))
+	}
+	return r
+}
+
+// Pattern returns the pattern string the receiver was compiled from.
+func (r *Regex) Pattern() string {
+	return r.pattern
+}
+
+// Options returns the compile options that were passed when compiling the
+// receiver. This does not include any options that the syntax enables by
+// default.
+func (r *Regex) Options() CompileOptions {
+	return r.options
+}
+
+// Syntax returns the syntax the receiver was compiled with.
+func (r *Regex) Syntax() Syntax {
+	return r.syntax
+}
+
+// Encoding returns the text encoding the receiver operates on, which is
+// always EncodingUTF8.
+func (r *Regex) Encoding() Encoding {
+	return EncodingUTF8
+}
+
+// String returns the pattern string the receiver was compiled from, like
+// method Pattern.
+func (r *Regex) String() string {
+	return r.pattern
+}
+
+// GoString returns a Go-syntax-like representation of the receiver, which is
+// primarily useful for debugging.
+func (r *Regex) GoString() string {
+	if r == nil {
+		return "(*onig.Regex)(nil)"
+	}
+	return fmt.Sprintf(
+		"&onig.Regex{Pattern:%q,Options:%#x,Syntax:%#v}",
+		r.pattern, uint(r.options), r.syntax,
+	)
+}
+
+// Clone compiles a new regex from the same pattern, options and syntax as the
+// receiver.
+//
+// Since a Regex is safe for concurrent use, cloning is not necessary in order
+// to use the same regex from multiple goroutines.
+func (r *Regex) Clone() *Regex {
+	ret, err := NewRegex(r.pattern, r.options, r.syntax)
+	if err != nil {
+		// Should never happen, since the receiver compiled successfully.
+		panic(fmt.Sprintf("failed to recompile %q: %s", r.pattern, err))
+	}
+	return ret
+}
+
+// Equal returns true if the receiver and the other given regex were compiled
+// from the same pattern, options and syntax.
+func (r *Regex) Equal(other *Regex) bool {
+	if (r == nil) != (other == nil) {
+		return false
+	}
+	if r == nil {
+		return true
+	}
+	return r.pattern == other.pattern && r.options == other.options && r.syntax == other.syntax
+}
+
 // Match tests whether the receiver matches a prefix of the given string,
 // returning a description of the match if one is found. If no match is found
 // then the result is nil.
 func (r *Regex) Match(s string, opts MatchOptions) *Match {
-	m := new(Match)
-	matchInit(m)
-	matches := regexMatch(r, s, opts, m)
-	if !matches {
+	m := NewMatch()
+	if !r.MatchInto(m, s, opts) {
 		return nil
 	}
 	return m
 }
 
+// MatchInto is like Match except that it writes its result into the given
+// existing match object, which must have been created by NewMatch or
+// returned from an earlier call. The result is true if a match is found. If
+// no match is found, all of the captures in m are unset.
+//
+// Reusing the same match object across many calls in a loop avoids
+// allocating a new match for each call.
+func (r *Regex) MatchInto(m *Match, s string, opts MatchOptions) bool {
+	return regexMatch(r, s, 0, opts, m)
+}
+
 // MatchBytes tests whether the receiver matches a prefix of the given byte
 // slice, returning a description of the match if one is found. If no match is
 // found then the result is nil.
 func (r *Regex) MatchBytes(b []byte, opts MatchOptions) *Match {
-	m := new(Match)
-	matchInit(m)
-	matches := regexMatchBytes(r, b, opts, m)
-	if !matches {
+	m := NewMatch()
+	if !r.MatchBytesInto(m, b, opts) {
 		return nil
 	}
 	return m
 }
 
+// MatchBytesInto is like MatchBytes except that it writes its result into the
+// given existing match object, in the same way as MatchInto.
+func (r *Regex) MatchBytesInto(m *Match, b []byte, opts MatchOptions) bool {
+	return regexMatchBytes(r, b, 0, opts, m)
+}
+
+// MatchAt is like Match except that it tests whether the receiver matches
+// the portion of the string beginning at the given byte offset. As with
+// SearchFrom, the portion before the offset is still visible to constructs
+// such as "^" and lookbehind assertions, and "\G" matches at the offset.
+//
+// MatchAt panics if at is negative or greater than len(s).
+func (r *Regex) MatchAt(s string, at int, opts MatchOptions) *Match {
+	m := NewMatch()
+	if !r.MatchAtInto(m, s, at, opts) {
+		return nil
+	}
+	return m
+}
+
+// MatchAtInto is like MatchAt except that it writes its result into the
+// given existing match object, in the same way as MatchInto.
+func (r *Regex) MatchAtInto(m *Match, s string, at int, opts MatchOptions) bool {
+	checkStart(at, len(s))
+	return regexMatch(r, s, at, opts, m)
+}
+
+// MatchBytesAt is like MatchAt except that it matches against a byte slice.
+func (r *Regex) MatchBytesAt(b []byte, at int, opts MatchOptions) *Match {
+	m := NewMatch()
+	if !r.MatchBytesAtInto(m, b, at, opts) {
+		return nil
+	}
+	return m
+}
+
+// MatchBytesAtInto is like MatchBytesAt except that it writes its result
+// into the given existing match object, in the same way as MatchInto.
+func (r *Regex) MatchBytesAtInto(m *Match, b []byte, at int, opts MatchOptions) bool {
+	checkStart(at, len(b))
+	return regexMatchBytes(r, b, at, opts, m)
+}
+
 // Search tests whether the receiver matches a substring of the given string,
 // returning a description of the first match found. If no match is found then
 // the result is nil.
 func (r *Regex) Search(s string, opts MatchOptions) *Match {
-	m := new(Match)
-	matchInit(m)
-	matches := regexSearch(r, s, opts, false, m)
-	if !matches {
+	m := NewMatch()
+	if !r.SearchInto(m, s, opts) {
 		return nil
 	}
 	return m
 }
 
+// SearchInto is like Search except that it writes its result into the given
+// existing match object, in the same way as MatchInto.
+func (r *Regex) SearchInto(m *Match, s string, opts MatchOptions) bool {
+	return regexSearch(r, s, 0, opts, false, m)
+}
+
 // SearchBytes tests whether the receiver matches a substring of the given byte
 // slice, returning a description of the first match found. If no match is
 // found then the result is nil.
 func (r *Regex) SearchBytes(b []byte, opts MatchOptions) *Match {
-	m := new(Match)
-	matchInit(m)
-	matches := regexSearchBytes(r, b, opts, false, m)
-	if !matches {
+	m := NewMatch()
+	if !r.SearchBytesInto(m, b, opts) {
+		return nil
+	}
+	return m
+}
+
+// SearchBytesInto is like SearchBytes except that it writes its result into
+// the given existing match object, in the same way as MatchInto.
+func (r *Regex) SearchBytesInto(m *Match, b []byte, opts MatchOptions) bool {
+	return regexSearchBytes(r, b, 0, opts, false, m)
+}
+
+// SearchFrom is like Search except that it only finds matches that begin at
+// or after the given byte offset into the string. Unlike searching a slice
+// of the string, the portion before start is still visible to constructs
+// such as "^", "\b" and lookbehind assertions, and any offsets in the
+// result are relative to the whole string. "\G" matches at start.
+//
+// SearchFrom panics if start is negative or greater than len(s).
+func (r *Regex) SearchFrom(s string, start int, opts MatchOptions) *Match {
+	m := NewMatch()
+	if !r.SearchFromInto(m, s, start, opts) {
 		return nil
 	}
 	return m
 }
 
+// SearchFromInto is like SearchFrom except that it writes its result into the
+// given existing match object, in the same way as MatchInto.
+func (r *Regex) SearchFromInto(m *Match, s string, start int, opts MatchOptions) bool {
+	checkStart(start, len(s))
+	return regexSearch(r, s, start, opts, false, m)
+}
+
+// SearchBytesFrom is like SearchFrom except that it searches a byte slice.
+func (r *Regex) SearchBytesFrom(b []byte, start int, opts MatchOptions) *Match {
+	m := NewMatch()
+	if !r.SearchBytesFromInto(m, b, start, opts) {
+		return nil
+	}
+	return m
+}
+
+// SearchBytesFromInto is like SearchBytesFrom except that it writes its
+// result into the given existing match object, in the same way as MatchInto.
+func (r *Regex) SearchBytesFromInto(m *Match, b []byte, start int, opts MatchOptions) bool {
+	checkStart(start, len(b))
+	return regexSearchBytes(r, b, start, opts, false, m)
+}
+
+func checkStart(start, l int) {
+	if start < 0 || start > l {
+		panic(fmt.Sprintf("onig: start offset %d out of range [0:%d]", start, l))
+	}
+}
+
 // SearchAround is equivalent to Search followed by slicing the string
 // around the first match, if any.
 //
@@ -97,13 +313,125 @@ func (r *Regex) SearchAroundBytes(b []byte, opts MatchOptions) (before, inside,
 // Matches tests whether the receiver matches a prefix of the given string,
 // returning true if a match is found.
 func (r *Regex) Matches(s string, opts MatchOptions) bool {
-	return regexMatch(r, s, opts, nil)
+	return regexMatch(r, s, 0, opts, nil)
 }
 
 // MatchesBytes tests whether the receiver matches a prefix of the given byte
 // slice, returning true if a match is found.
 func (r *Regex) MatchesBytes(b []byte, opts MatchOptions) bool {
-	return regexMatchBytes(r, b, opts, nil)
+	return regexMatchBytes(r, b, 0, opts, nil)
+}
+
+// SearchMany is equivalent to calling Search for each of the given strings in
+// turn, returning a slice of results with one element per string. Elements
+// are nil for strings where no match was found.
+//
+// The whole batch is handled by a single call into Oniguruma, which avoids
+// the overhead of a separate cgo call for each string when the strings
+// are short.
+func (r *Regex) SearchMany(ss []string, opts MatchOptions) []*Match {
+	return regexSearchMany(r, ss, opts)
+}
+
+// SearchManyBytes is like SearchMany but for a batch of byte slices.
+func (r *Regex) SearchManyBytes(bs [][]byte, opts MatchOptions) []*Match {
+	return regexSearchMany(r, bs, opts)
+}
+
+// MatchesMany is equivalent to calling Matches for each of the given strings
+// in turn, returning a slice of results with one element per string.
+//
+// As with SearchMany, the whole batch is handled by a single call into
+// Oniguruma.
+func (r *Regex) MatchesMany(ss []string, opts MatchOptions) []bool {
+	return regexBatch(r, ss, opts, false, nil)
+}
+
+// MatchesManyBytes is like MatchesMany but for a batch of byte slices.
+func (r *Regex) MatchesManyBytes(bs [][]byte, opts MatchOptions) []bool {
+	return regexBatch(r, bs, opts, false, nil)
+}
+
+// Count returns the number of successive, non-overlapping matches of the
+// receiver in the given string.
+//
+// All of the matches are found within a single call into Oniguruma, so this
+// is considerably cheaper than counting the results of repeated searches
+// when there are many matches.
+func (r *Regex) Count(s string, opts MatchOptions) int {
+	return regexCount(r, s, opts)
+}
+
+// CountBytes is like Count but for a byte slice.
+func (r *Regex) CountBytes(b []byte, opts MatchOptions) int {
+	return regexCountBytes(r, b, opts)
+}
+
+// ScanSpans returns spans describing the bounds of all of the successive,
+// non-overlapping matches of the receiver in the given string, in order. The
+// result is nil if there are no matches.
+//
+// After an empty match, the next search begins one character later, so
+// that the same empty match is not found again. An empty match can also
+// appear directly after a non-empty one, as in Ruby's String#scan.
+//
+// As with Count, all of the matches are found within a single call into
+// Oniguruma.
+func (r *Regex) ScanSpans(s string, opts MatchOptions) []Span {
+	return regexScan(r, s, opts)
+}
+
+// ScanSpansBytes is like ScanSpans but for a byte slice.
+func (r *Regex) ScanSpansBytes(b []byte, opts MatchOptions) []Span {
+	return regexScanBytes(r, b, opts)
+}
+
+// SearchAll returns all of the successive, non-overlapping matches of the
+// receiver in the given string, in order, including their captures. The
+// result is nil if there are no matches.
+//
+// The matches are the same as those described by ScanSpans, but each one
+// requires a separate call into Oniguruma. Use ScanSpans instead if only
+// the bounds of each match are needed.
+func (r *Regex) SearchAll(s string, opts MatchOptions) []*Match {
+	return regexSearchAll(s, func(m *Match, pos int) bool {
+		return r.SearchFromInto(m, s, pos, opts)
+	})
+}
+
+// SearchAllBytes is like SearchAll but for a byte slice.
+func (r *Regex) SearchAllBytes(b []byte, opts MatchOptions) []*Match {
+	return regexSearchAll(b, func(m *Match, pos int) bool {
+		return r.SearchBytesFromInto(m, b, pos, opts)
+	})
+}
+
+func regexSearchAll[S string | []byte](subject S, search func(m *Match, pos int) bool) []*Match {
+	var ret []*Match
+	for pos := 0; pos <= len(subject); {
+		m := NewMatch()
+		if !search(m, pos) {
+			break
+		}
+		ret = append(ret, m)
+		pos = scanNext(subject, m.Bounds())
+	}
+	return ret
+}
+
+// scanNext returns the offset at which to search for the match following
+// the given one, stepping over one whole character after an empty match in
+// the same way as ScanSpans. The result is len(subject)+1 if there is no
+// such offset.
+func scanNext[S string | []byte](subject S, span Span) int {
+	if span.Start != span.End {
+		return span.End
+	}
+	next := span.End + 1
+	for next < len(subject) && !utf8.RuneStart(subject[next]) {
+		next++
+	}
+	return next
 }
 
 // CaptureCount returns the number of capture sequences present in the
@@ -153,3 +481,16 @@ func (r *Regex) NamedCapturesFirst() map[string]int {
 	}
 	return ret
 }
+
+func regexSearchMany[S string | []byte](r *Regex, subjects []S, opts MatchOptions) []*Match {
+	slab := make([]Match, len(subjects))
+	matchInitSlab(slab)
+	found := regexBatch(r, subjects, opts, true, slab)
+	ret := make([]*Match, len(subjects))
+	for i, ok := range found {
+		if ok {
+			ret[i] = &slab[i]
+		}
+	}
+	return ret
+}
@@ -3,6 +3,8 @@ package onig
 import (
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -44,6 +46,18 @@ func TestRegexMatch(t *testing.T) {
 			`why hello, world`,
 			nil,
 		},
+		{
+			`hello`,
+			``,
+			nil,
+		},
+		{
+			`x*`,
+			``,
+			mustFakeMatch([]Span{
+				{0, 0},
+			}),
+		},
 	}
 
 	for _, test := range tests {
@@ -112,6 +126,18 @@ func TestRegexSearch(t *testing.T) {
 				{4, 9},
 			}),
 		},
+		{
+			`hello`,
+			``,
+			nil,
+		},
+		{
+			`x*`,
+			``,
+			mustFakeMatch([]Span{
+				{0, 0},
+			}),
+		},
 	}
 
 	for _, test := range tests {
@@ -140,6 +166,289 @@ func TestRegexSearch(t *testing.T) {
 	}
 }
 
+func TestRegexSearchFrom(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Str     string
+		Start   int
+		Want    *Match
+	}{
+		{
+			`a+`,
+			`aa baa`,
+			1,
+			mustFakeMatch([]Span{
+				{1, 2},
+			}),
+		},
+		{
+			`a+`,
+			`aa baa`,
+			2,
+			mustFakeMatch([]Span{
+				{4, 6},
+			}),
+		},
+		{
+			// The "a" at offset 1 is not at a word boundary, even though
+			// it would be at the start of the string s[1:].
+			`\ba`,
+			`aa baa`,
+			1,
+			nil,
+		},
+		{
+			`(?<=b)a`,
+			`aa baa`,
+			4,
+			mustFakeMatch([]Span{
+				{4, 5},
+			}),
+		},
+		{
+			`\Ga`,
+			`aa baa`,
+			3,
+			nil,
+		},
+		{
+			`^a`,
+			`aa baa`,
+			1,
+			nil,
+		},
+		{
+			`x*`,
+			`aa`,
+			2,
+			mustFakeMatch([]Span{
+				{2, 2},
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q from %d", test.Pattern, test.Str, test.Start), func(t *testing.T) {
+			r, err := NewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := r.SearchFrom(test.Str, test.Start, NoMatchOpts)
+			if !got.Equal(test.Want) {
+				t.Errorf(
+					"wrong SearchFrom result\npattern: %s\nstring:  %s\ngot:     %#v\nwant:    %#v",
+					test.Pattern, test.Str, got, test.Want,
+				)
+			}
+
+			got = r.SearchBytesFrom([]byte(test.Str), test.Start, NoMatchOpts)
+			if !got.Equal(test.Want) {
+				t.Errorf(
+					"wrong SearchBytesFrom result\npattern: %s\nstring:  %s\ngot:     %#v\nwant:    %#v",
+					test.Pattern, test.Str, got, test.Want,
+				)
+			}
+		})
+	}
+}
+
+func TestRegexMatchAt(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Str     string
+		At      int
+		Want    *Match
+	}{
+		{
+			`b(a+)`,
+			`aa baa`,
+			3,
+			mustFakeMatch([]Span{
+				{3, 6},
+				{4, 6},
+			}),
+		},
+		{
+			`a+`,
+			`aa baa`,
+			2,
+			nil,
+		},
+		{
+			`\Gb`,
+			`aa baa`,
+			3,
+			mustFakeMatch([]Span{
+				{3, 4},
+			}),
+		},
+		{
+			`(?<= )b`,
+			`aa baa`,
+			3,
+			mustFakeMatch([]Span{
+				{3, 4},
+			}),
+		},
+		{
+			`$`,
+			`aa`,
+			2,
+			mustFakeMatch([]Span{
+				{2, 2},
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q at %d", test.Pattern, test.Str, test.At), func(t *testing.T) {
+			r, err := NewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := r.MatchAt(test.Str, test.At, NoMatchOpts)
+			if !got.Equal(test.Want) {
+				t.Errorf(
+					"wrong MatchAt result\npattern: %s\nstring:  %s\ngot:     %#v\nwant:    %#v",
+					test.Pattern, test.Str, got, test.Want,
+				)
+			}
+
+			got = r.MatchBytesAt([]byte(test.Str), test.At, NoMatchOpts)
+			if !got.Equal(test.Want) {
+				t.Errorf(
+					"wrong MatchBytesAt result\npattern: %s\nstring:  %s\ngot:     %#v\nwant:    %#v",
+					test.Pattern, test.Str, got, test.Want,
+				)
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("no panic for out-of-range offset")
+		}
+	}()
+	MustNewRegex(`a`, NoCompileOpts, SyntaxRuby).MatchAt(`a`, 2, NoMatchOpts)
+}
+
+func TestRegexSearchInto(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Str     string
+		Want    *Match
+	}{
+		{
+			`he(l*)o`,
+			`helllllo world`,
+			mustFakeMatch([]Span{
+				{0, 8},
+				{2, 7},
+			}),
+		},
+		{
+			`hello`,
+			`why hello, world`,
+			mustFakeMatch([]Span{
+				{4, 9},
+			}),
+		},
+		{
+			`he(l*)o`,
+			`goodbye world`,
+			nil,
+		},
+		{
+			`w(or)(ld)`,
+			`hello world`,
+			mustFakeMatch([]Span{
+				{6, 11},
+				{7, 9},
+				{9, 11},
+			}),
+		},
+	}
+
+	// The same match object is reused across all of the tests, to make sure
+	// that nothing from a previous result leaks into the next.
+	m := NewMatch()
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q", test.Pattern, test.Str), func(t *testing.T) {
+			r, err := NewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found := r.SearchInto(m, test.Str, NoMatchOpts)
+			if found != (test.Want != nil) {
+				t.Fatalf("wrong SearchInto result %#v", found)
+			}
+			if found && !m.Equal(test.Want) {
+				t.Errorf(
+					"wrong SearchInto match\npattern: %s\nstring:  %s\ngot:     %#v\nwant:    %#v",
+					test.Pattern, test.Str, m, test.Want,
+				)
+			}
+
+			found = r.SearchBytesInto(m, []byte(test.Str), NoMatchOpts)
+			if found != (test.Want != nil) {
+				t.Fatalf("wrong SearchBytesInto result %#v", found)
+			}
+			if found && !m.Equal(test.Want) {
+				t.Errorf(
+					"wrong SearchBytesInto match\npattern: %s\nstring:  %s\ngot:     %#v\nwant:    %#v",
+					test.Pattern, test.Str, m, test.Want,
+				)
+			}
+		})
+	}
+}
+
+func TestRegexSearchIntoAllocs(t *testing.T) {
+	r, err := NewRegex(`w(or)(ld)`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := []byte(`hello world`)
+	s := `hello world`
+	m := NewMatch()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		r.SearchBytesInto(m, b, NoMatchOpts)
+	})
+	if allocs != 0 {
+		t.Errorf("SearchBytesInto made %v allocations per call; want 0", allocs)
+	}
+	allocs = testing.AllocsPerRun(100, func() {
+		r.SearchInto(m, s, NoMatchOpts)
+	})
+	if allocs != 0 {
+		t.Errorf("SearchInto made %v allocations per call; want 0", allocs)
+	}
+}
+
+func TestMatchReset(t *testing.T) {
+	r, err := NewRegex(`he(l*)o`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMatch()
+	if !r.MatchInto(m, `hello`, NoMatchOpts) {
+		t.Fatal("no match")
+	}
+
+	m.Reset()
+	want := mustFakeMatch([]Span{
+		{-1, -1},
+		{-1, -1},
+	})
+	if !m.Equal(want) {
+		t.Errorf("wrong result after Reset\ngot:  %#v\nwant: %#v", m, want)
+	}
+}
+
 func TestRegexSearchAround(t *testing.T) {
 	tests := []struct {
 		Pattern    string
@@ -233,6 +542,16 @@ func TestRegexMatches(t *testing.T) {
 			`why hello, world`,
 			false,
 		},
+		{
+			`hello`,
+			``,
+			false,
+		},
+		{
+			``,
+			``,
+			true,
+		},
 	}
 
 	for _, test := range tests {
@@ -261,6 +580,176 @@ func TestRegexMatches(t *testing.T) {
 	}
 }
 
+func TestRegexSearchMany(t *testing.T) {
+	r, err := NewRegex(`he(l*)o`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strs := []string{
+		`hello world`,
+		`goodbye world`,
+		``,
+		`why hello, world`,
+		`heo`,
+	}
+	want := []*Match{
+		mustFakeMatch([]Span{{0, 5}, {2, 4}}),
+		nil,
+		nil,
+		mustFakeMatch([]Span{{4, 9}, {6, 8}}),
+		mustFakeMatch([]Span{{0, 3}, {2, 2}}),
+	}
+
+	check := func(t *testing.T, got []*Match) {
+		if len(got) != len(want) {
+			t.Fatalf("wrong number of results %d; want %d", len(got), len(want))
+		}
+		for i := range want {
+			if !got[i].Equal(want[i]) {
+				t.Errorf(
+					"wrong result for %q\ngot:  %#v\nwant: %#v",
+					strs[i], got[i], want[i],
+				)
+			}
+		}
+	}
+
+	t.Run("strings", func(t *testing.T) {
+		check(t, r.SearchMany(strs, NoMatchOpts))
+	})
+	t.Run("bytes", func(t *testing.T) {
+		bs := make([][]byte, len(strs))
+		for i, s := range strs {
+			bs[i] = []byte(s)
+		}
+		check(t, r.SearchManyBytes(bs, NoMatchOpts))
+	})
+	t.Run("empty batch", func(t *testing.T) {
+		if got := r.SearchMany(nil, NoMatchOpts); len(got) != 0 {
+			t.Errorf("wrong result %#v; want empty", got)
+		}
+	})
+}
+
+func TestRegexMatchesMany(t *testing.T) {
+	r, err := NewRegex(`hel*o`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strs := []string{
+		`hello world`,
+		`why hello, world`,
+		``,
+		`hellllllo`,
+	}
+	want := []bool{true, false, false, true}
+
+	got := r.MatchesMany(strs, NoMatchOpts)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong MatchesMany result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	bs := make([][]byte, len(strs))
+	for i, s := range strs {
+		bs[i] = []byte(s)
+	}
+	got = r.MatchesManyBytes(bs, NoMatchOpts)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong MatchesManyBytes result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestRegexScanSpans(t *testing.T) {
+	manyAs := make([]Span, 100)
+	for i := range manyAs {
+		manyAs[i] = Span{i, i + 1}
+	}
+
+	tests := []struct {
+		Pattern string
+		Str     string
+		Want    []Span
+	}{
+		{
+			`l`,
+			`hello world`,
+			[]Span{{2, 3}, {3, 4}, {9, 10}},
+		},
+		{
+			`l+`,
+			`hello world`,
+			[]Span{{2, 4}, {9, 10}},
+		},
+		{
+			`zzz`,
+			`hello world`,
+			nil,
+		},
+		{
+			`l`,
+			``,
+			nil,
+		},
+		{
+			`x*`,
+			``,
+			[]Span{{0, 0}},
+		},
+		{
+			`x*`,
+			`ab`,
+			[]Span{{0, 0}, {1, 1}, {2, 2}},
+		},
+		{
+			`a*`,
+			`baa`,
+			[]Span{{0, 0}, {1, 3}, {3, 3}},
+		},
+		{
+			`x*`,
+			`héllo`,
+			[]Span{{0, 0}, {1, 1}, {3, 3}, {4, 4}, {5, 5}, {6, 6}},
+		},
+		{
+			`a`,
+			strings.Repeat("a", 100),
+			manyAs,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q", test.Pattern, test.Str), func(t *testing.T) {
+			r, err := NewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := r.ScanSpans(test.Str, NoMatchOpts)
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf(
+					"wrong ScanSpans result\npattern: %s\nstring:  %s\ngot:     %#v\nwant:    %#v",
+					test.Pattern, test.Str, got, test.Want,
+				)
+			}
+
+			got = r.ScanSpansBytes([]byte(test.Str), NoMatchOpts)
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf(
+					"wrong ScanSpansBytes result\npattern: %s\nstring:  %s\ngot:     %#v\nwant:    %#v",
+					test.Pattern, test.Str, got, test.Want,
+				)
+			}
+
+			if got, want := r.Count(test.Str, NoMatchOpts), len(test.Want); got != want {
+				t.Errorf("wrong Count result %d; want %d", got, want)
+			}
+			if got, want := r.CountBytes([]byte(test.Str), NoMatchOpts), len(test.Want); got != want {
+				t.Errorf("wrong CountBytes result %d; want %d", got, want)
+			}
+		})
+	}
+}
+
 func TestRegexCaptureCount(t *testing.T) {
 	tests := []struct {
 		Pattern string
@@ -367,3 +856,335 @@ func TestRegexNamedCaptures(t *testing.T) {
 		})
 	}
 }
+
+// The following benchmarks use an anchored pattern that only examines the
+// start of the subject, so the time per operation should not grow with the
+// subject length unless the input is being copied on each call.
+
+func BenchmarkRegexMatchInto(b *testing.B) {
+	r, err := NewRegex(`\Ahello`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, size := range []int{16, 1024, 1024 * 1024} {
+		s := "hello" + strings.Repeat("x", size-5)
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			m := NewMatch()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.MatchInto(m, s, NoMatchOpts)
+			}
+		})
+	}
+}
+
+func BenchmarkRegexMatchBytesInto(b *testing.B) {
+	r, err := NewRegex(`\Ahello`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, size := range []int{16, 1024, 1024 * 1024} {
+		buf := []byte("hello" + strings.Repeat("x", size-5))
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			m := NewMatch()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.MatchBytesInto(m, buf, NoMatchOpts)
+			}
+		})
+	}
+}
+
+func BenchmarkRegexSearchInto(b *testing.B) {
+	r, err := NewRegex(`\Ahello`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, size := range []int{16, 1024, 1024 * 1024} {
+		s := "hello" + strings.Repeat("x", size-5)
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			m := NewMatch()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.SearchInto(m, s, NoMatchOpts)
+			}
+		})
+	}
+}
+
+// benchLines returns a batch of short log-like lines for the batch
+// benchmarks, of which roughly one in ten contains "ERROR".
+func benchLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		level := "INFO"
+		if i%10 == 0 {
+			level = "ERROR"
+		}
+		lines[i] = fmt.Sprintf("2006-01-02T15:04:05Z %s request %d done", level, i)
+	}
+	return lines
+}
+
+func BenchmarkRegexSearchMany(b *testing.B) {
+	r, err := NewRegex(`ERROR (\w+)`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		b.Fatal(err)
+	}
+	lines := benchLines(1000)
+
+	b.Run("Search loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, line := range lines {
+				r.Search(line, NoMatchOpts)
+			}
+		}
+	})
+	b.Run("SearchMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.SearchMany(lines, NoMatchOpts)
+		}
+	})
+}
+
+func BenchmarkRegexMatchesMany(b *testing.B) {
+	r, err := NewRegex(`\d{4}-\d\d-\d\dT`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		b.Fatal(err)
+	}
+	lines := benchLines(1000)
+
+	b.Run("Matches loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, line := range lines {
+				r.Matches(line, NoMatchOpts)
+			}
+		}
+	})
+	b.Run("MatchesMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.MatchesMany(lines, NoMatchOpts)
+		}
+	})
+}
+
+func BenchmarkRegexScanSpans(b *testing.B) {
+	r, err := NewRegex(`\w+`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := strings.Repeat("the quick brown fox jumps over the lazy dog ", 100)
+
+	b.Run("SearchInto loop", func(b *testing.B) {
+		m := NewMatch()
+		for i := 0; i < b.N; i++ {
+			var spans []Span
+			pos := 0
+			for r.SearchInto(m, s[pos:], NoMatchOpts) {
+				span := m.Bounds()
+				spans = append(spans, Span{pos + span.Start, pos + span.End})
+				pos += span.End
+			}
+		}
+	})
+	b.Run("ScanSpans", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.ScanSpans(s, NoMatchOpts)
+		}
+	})
+	b.Run("Count", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.Count(s, NoMatchOpts)
+		}
+	})
+}
+
+// TestRegexConcurrent exercises a shared Regex from many goroutines at once.
+// It is most useful when run with the race detector enabled.
+func TestRegexConcurrent(t *testing.T) {
+	r, err := NewRegex(`(?<user>\w+)@(?<host>\w+)`, NoCompileOpts, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := `contact alice@example or bob@example`
+	want := mustFakeMatch([]Span{
+		{8, 21},
+		{8, 13},
+		{14, 21},
+	})
+	wantSpans := []Span{{8, 21}, {25, 36}}
+	wantNames := map[string][]int{"user": {1}, "host": {2}}
+
+	const goroutines = 16
+	const iterations = 200
+	var wg sync.WaitGroup
+	errs := make(chan string, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := NewMatch()
+			for i := 0; i < iterations; i++ {
+				if got := r.Search(s, NoMatchOpts); !got.Equal(want) {
+					errs <- fmt.Sprintf("wrong Search result %#v", got)
+					return
+				}
+				if !r.SearchInto(m, s, NoMatchOpts) || !m.Equal(want) {
+					errs <- fmt.Sprintf("wrong SearchInto result %#v", m)
+					return
+				}
+				if r.Matches(s, NoMatchOpts) {
+					errs <- "Matches returned true"
+					return
+				}
+				if got := r.SearchMany([]string{s, s}, NoMatchOpts); !got[1].Equal(want) {
+					errs <- fmt.Sprintf("wrong SearchMany result %#v", got[1])
+					return
+				}
+				if got := r.ScanSpans(s, NoMatchOpts); !reflect.DeepEqual(got, wantSpans) {
+					errs <- fmt.Sprintf("wrong ScanSpans result %#v", got)
+					return
+				}
+				if got := r.NamedCaptures(); !reflect.DeepEqual(got, wantNames) {
+					errs <- fmt.Sprintf("wrong NamedCaptures result %#v", got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestRegexIntrospection(t *testing.T) {
+	r, err := NewRegex(`he(l*)o`, OptIgnoreCase|OptExtend, SyntaxPerl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := r.Pattern(), `he(l*)o`; got != want {
+		t.Errorf("wrong Pattern %q; want %q", got, want)
+	}
+	if got, want := r.Options(), OptIgnoreCase|OptExtend; got != want {
+		t.Errorf("wrong Options %#v; want %#v", got, want)
+	}
+	if got, want := r.Syntax(), SyntaxPerl; got != want {
+		t.Errorf("wrong Syntax %s; want %s", got, want)
+	}
+	if got, want := r.Encoding(), EncodingUTF8; got != want {
+		t.Errorf("wrong Encoding %q; want %q", got, want)
+	}
+	if got, want := r.String(), `he(l*)o`; got != want {
+		t.Errorf("wrong String %q; want %q", got, want)
+	}
+	if got, want := fmt.Sprintf("%#v", r), `&onig.Regex{Pattern:"he(l*)o",Options:0x3,Syntax:onig.SyntaxPerl}`; got != want {
+		t.Errorf("wrong GoString\ngot:  %s\nwant: %s", got, want)
+	}
+	if got, want := fmt.Sprintf("%#v", (*Regex)(nil)), `(*onig.Regex)(nil)`; got != want {
+		t.Errorf("wrong GoString for nil\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestRegexCloneEqual(t *testing.T) {
+	r, err := NewRegex(`he(l*)o`, OptIgnoreCase, SyntaxRuby)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := r.Clone()
+	if clone == r {
+		t.Fatalf("Clone returned the receiver")
+	}
+	if !clone.Equal(r) {
+		t.Errorf("clone is not equal to the original")
+	}
+	want := mustFakeMatch([]Span{{0, 5}, {2, 4}})
+	if got := clone.Match(`HELLO`, NoMatchOpts); !got.Equal(want) {
+		t.Errorf("wrong match from clone\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	others := []*Regex{
+		MustNewRegex(`he(l+)o`, OptIgnoreCase, SyntaxRuby),
+		MustNewRegex(`he(l*)o`, NoCompileOpts, SyntaxRuby),
+		MustNewRegex(`he(l*)o`, OptIgnoreCase, SyntaxPerl),
+		nil,
+	}
+	for _, other := range others {
+		if r.Equal(other) {
+			t.Errorf("%#v is equal to %#v", r, other)
+		}
+	}
+	if !(*Regex)(nil).Equal(nil) {
+		t.Errorf("nil is not equal to nil")
+	}
+}
+
+func TestSyntaxString(t *testing.T) {
+	tests := []struct {
+		Syntax       Syntax
+		Want, WantGo string
+	}{
+		{SyntaxRuby, "Ruby", "onig.SyntaxRuby"},
+		{SyntaxPosixBasic, "PosixBasic", "onig.SyntaxPosixBasic"},
+		{SyntaxPerlNG, "PerlNG", "onig.SyntaxPerlNG"},
+		{Syntax(0), "Syntax(0x0)", "onig.Syntax(0x0)"},
+	}
+
+	for _, test := range tests {
+		if got := test.Syntax.String(); got != test.Want {
+			t.Errorf("wrong String %q; want %q", got, test.Want)
+		}
+		if got := test.Syntax.GoString(); got != test.WantGo {
+			t.Errorf("wrong GoString %q; want %q", got, test.WantGo)
+		}
+	}
+}
+
+func TestNewSyntax(t *testing.T) {
+	syntax := NewSyntax(
+		SyntaxPerlNG,
+		OptSingleline|OptIgnoreCase,
+		SynIntervalLowAbbrev|SynUnicodeEscape,
+		SynBufAnchors|SynPerlOptions|SynDuplicateNames,
+	)
+
+	tests := []struct {
+		Pattern string
+		Str     string
+		Want    *Match
+	}{
+		{`a{,2}`, `aaa`, mustFakeMatch([]Span{{0, 2}})},
+		{`\u0041`, `A`, mustFakeMatch([]Span{{0, 1}})},
+		{`\Ab`, `AB`, mustFakeMatch([]Span{{0, 2}})},
+		{`a`, `A`, mustFakeMatch([]Span{{0, 1}})},
+		{`a$`, "a\n", mustFakeMatch([]Span{{0, 1}})},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q in %q", test.Pattern, test.Str), func(t *testing.T) {
+			r, err := NewRegex(test.Pattern, NoCompileOpts, syntax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Search(test.Str, NoMatchOpts); !got.Equal(test.Want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.Want)
+			}
+		})
+	}
+
+	for _, pattern := range []string{`(?s)a`, `(?<n>a)(?<n>b)`} {
+		if _, err := NewRegex(pattern, NoCompileOpts, syntax); err == nil {
+			t.Errorf("unexpected success for %q", pattern)
+		}
+	}
+
+	// The base syntax must be unchanged.
+	if _, err := NewRegex(`(?s)a`, NoCompileOpts, SyntaxPerlNG); err != nil {
+		t.Errorf("base syntax was modified: %s", err)
+	}
+	if got := syntax.String(); !strings.HasPrefix(got, "Syntax(0x") {
+		t.Errorf("wrong String %q for custom syntax", got)
+	}
+}
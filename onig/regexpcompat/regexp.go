@@ -0,0 +1,828 @@
+// Package regexpcompat provides a Regexp type with the same API as the
+// standard library's regexp.Regexp, implemented using Oniguruma, so that
+// existing code can switch engines by changing only its import path.
+//
+// Patterns passed to Compile use onig.SyntaxPerlNG, with "(?P<name>...)"
+// accepted as an alias for "(?<name>...)", which together cover most of the
+// syntax accepted by package regexp. CompilePOSIX uses
+// onig.SyntaxPosixExtended. Both enable onig.OptCaptureGroup so that
+// unnamed groups capture even when named groups are present.
+//
+// There are some differences in behavior from package regexp:
+//
+//   - Oniguruma is a backtracking engine, so it does not share the linear
+//     time guarantee of package regexp. Conversely, it supports constructs
+//     that package regexp does not, such as backreferences and lookaround.
+//   - Without the "m" flag, "$" matches before a final newline as well as
+//     at the end of the text, as in Perl.
+//   - The "U" flag is not supported, and Compile returns an error for a
+//     pattern that uses it.
+//   - In leftmost-longest mode, as selected by Longest or CompilePOSIX,
+//     lookahead assertions, which package regexp does not support, see at
+//     most two characters past the end of a match. Each match also costs
+//     time proportional to the length of the remaining text.
+//   - Input must be valid UTF-8.
+//   - The reader methods such as MatchReader read their whole input before
+//     searching it.
+//   - LiteralPrefix is computed from the pattern text rather than the
+//     compiled program, so it may return a shorter prefix than package
+//     regexp would.
+package regexpcompat
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// Regexp is a compiled regular expression with the same methods as
+// regexp.Regexp.
+//
+// A Regexp is safe for concurrent use by multiple goroutines, except for
+// configuration methods such as Longest.
+type Regexp struct {
+	expr  string
+	re    *onig.Regex
+	names []string
+
+	// longest is set once Longest has been called, and holds the pattern
+	// followed by exactly i characters and then the end of the text, for
+	// each i. It is used to find the longest match at the position found by
+	// re, by truncating the text at successively earlier positions while
+	// leaving up to two characters after each candidate end, so that
+	// assertions such as "$" and "\b" still see the text that follows.
+	// longestShift is the number of capture groups that the anchoring adds
+	// before the pattern's own.
+	longest      [3]*onig.Regex
+	longestShift int
+}
+
+// Compile parses a regular expression and returns, if successful, a Regexp
+// object that can be used to match against text.
+func Compile(expr string) (*Regexp, error) {
+	return compile(expr, translate(expr), onig.SyntaxPerlNG, false)
+}
+
+// CompilePOSIX is like Compile but restricts the regular expression to POSIX
+// ERE (egrep) syntax and changes the match semantics to leftmost-longest.
+func CompilePOSIX(expr string) (*Regexp, error) {
+	return compile(expr, expr, onig.SyntaxPosixExtended, true)
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(str string) *Regexp {
+	re, err := Compile(str)
+	if err != nil {
+		panic(`regexpcompat: Compile(` + quote(str) + `): ` + err.Error())
+	}
+	return re
+}
+
+// MustCompilePOSIX is like CompilePOSIX but panics if the expression cannot
+// be parsed.
+func MustCompilePOSIX(str string) *Regexp {
+	re, err := CompilePOSIX(str)
+	if err != nil {
+		panic(`regexpcompat: CompilePOSIX(` + quote(str) + `): ` + err.Error())
+	}
+	return re
+}
+
+func compile(expr, pattern string, syntax onig.Syntax, longest bool) (*Regexp, error) {
+	re, err := onig.NewRegex(pattern, onig.OptCaptureGroup, syntax)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing regexp: %s: `%s`", err, expr)
+	}
+	ret := &Regexp{
+		expr:  expr,
+		re:    re,
+		names: make([]string, re.CaptureCount()+1),
+	}
+	for name, idxs := range re.NamedCaptures() {
+		for _, idx := range idxs {
+			ret.names[idx] = name
+		}
+	}
+	if longest {
+		ret.Longest()
+	}
+	return ret, nil
+}
+
+// translate rewrites the parts of the given pattern that Oniguruma would
+// interpret differently from package regexp: the named groups "(?P<name>",
+// which become "(?<name>"; the single-letter Unicode classes such as "\pL",
+// which become "\p{L}"; and flag groups that only clear flags, such as
+// "(?-s:", which SyntaxPerlNG would parse as a relative subexpression call
+// and so become "(?s-s:", setting the first flag and then clearing it.
+func translate(expr string) string {
+	if !strings.Contains(expr, "(?") && !strings.Contains(expr, `\p`) && !strings.Contains(expr, `\P`) {
+		return expr
+	}
+	var buf strings.Builder
+	inClass := false
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == '\\' && i+2 < len(expr) && (expr[i+1] == 'p' || expr[i+1] == 'P') && expr[i+2] != '{':
+			buf.WriteString(expr[i:i+2] + "{" + expr[i+2:i+3] + "}")
+			i += 2
+			continue
+		case c == '\\' && i+1 < len(expr):
+			buf.WriteByte(c)
+			i++
+			c = expr[i]
+		case inClass && c == '[' && strings.HasPrefix(expr[i:], "[:"):
+			if end := strings.Index(expr[i+2:], ":]"); end >= 0 {
+				buf.WriteString(expr[i : i+end+4])
+				i += end + 3
+				continue
+			}
+		case inClass && c == ']':
+			inClass = false
+		case !inClass && c == '[':
+			inClass = true
+			buf.WriteByte(c)
+			// A "]" at the start of a class is literal.
+			if strings.HasPrefix(expr[i+1:], "^") {
+				i++
+				buf.WriteByte('^')
+			}
+			if strings.HasPrefix(expr[i+1:], "]") {
+				i++
+				buf.WriteByte(']')
+			}
+			continue
+		case !inClass && strings.HasPrefix(expr[i:], "(?P<"):
+			buf.WriteString("(?<")
+			i += 3
+			continue
+		case !inClass && strings.HasPrefix(expr[i:], "(?-") && i+3 < len(expr) && isASCIILetter(expr[i+3]):
+			buf.WriteString("(?" + expr[i+3:i+4] + "-")
+			i += 2
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// quote is like strconv.Quote but uses backquotes where possible, to match
+// the panic messages from package regexp.
+func quote(s string) string {
+	if !strings.ContainsAny(s, "`\n\r") && utf8.ValidString(s) {
+		return "`" + s + "`"
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// QuoteMeta returns a string that escapes all regular expression
+// metacharacters inside the argument text; the returned string is a regular
+// expression matching the literal text.
+func QuoteMeta(s string) string {
+	var buf strings.Builder
+	buf.Grow(2 * len(s))
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`\.+*?()|[]{}^$`, s[i]) >= 0 {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// MatchString reports whether the string s contains any match of the
+// regular expression pattern.
+func MatchString(pattern string, s string) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// Match reports whether the byte slice b contains any match of the regular
+// expression pattern.
+func Match(pattern string, b []byte) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.Match(b), nil
+}
+
+// MatchReader reports whether the text returned by the RuneReader contains
+// any match of the regular expression pattern.
+func MatchReader(pattern string, r io.RuneReader) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchReader(r), nil
+}
+
+// String returns the source text used to compile the regular expression.
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// Copy returns a new Regexp object copied from re. Calling Longest on one
+// copy does not affect another.
+//
+// Deprecated: In earlier releases of package regexp, when using a Regexp in
+// multiple goroutines, giving each goroutine its own copy helped to avoid
+// lock contention. That has never been necessary for this package.
+func (re *Regexp) Copy() *Regexp {
+	re2 := *re
+	return &re2
+}
+
+// Longest makes future searches prefer leftmost-longest matches. That is,
+// when matching against text, the regexp returns a match that begins as
+// early as possible in the input (leftmost), and among those it chooses a
+// match that is as long as possible.
+//
+// This method modifies the Regexp and may not be called concurrently with
+// any other methods.
+func (re *Regexp) Longest() {
+	if re.longest[0] != nil {
+		return
+	}
+	pattern, syntax := re.re.Pattern(), re.re.Syntax()
+	for i := range re.longest {
+		// In SyntaxPosixExtended, "." matches a newline and "$" only
+		// matches at the end of the text, but there are no non-capturing
+		// groups.
+		anchored, shift := fmt.Sprintf(`(?:%s)(?s:.{%d})\z`, pattern, i), 0
+		if syntax == onig.SyntaxPosixExtended {
+			anchored, shift = fmt.Sprintf(`(%s).{%d}$`, pattern, i), 1
+		}
+		longest, err := onig.NewRegex(anchored, re.re.Options(), syntax)
+		if err != nil {
+			// Should never happen, since the pattern itself already compiled.
+			panic(fmt.Sprintf("failed to compile %q: %s", anchored, err))
+		}
+		re.longest[i] = longest
+		re.longestShift = shift
+	}
+}
+
+// NumSubexp returns the number of parenthesized subexpressions in this
+// Regexp.
+func (re *Regexp) NumSubexp() int {
+	return len(re.names) - 1
+}
+
+// SubexpNames returns the names of the parenthesized subexpressions in this
+// Regexp. The name for the first sub-expression is names[1], so that if m
+// is a match slice, the name for m[i] is SubexpNames()[i]. Since the Regexp
+// as a whole cannot be named, names[0] is always the empty string. The slice
+// should not be modified.
+func (re *Regexp) SubexpNames() []string {
+	return re.names
+}
+
+// SubexpIndex returns the index of the first subexpression with the given
+// name, or -1 if there is no subexpression with that name.
+func (re *Regexp) SubexpIndex(name string) int {
+	if name != "" {
+		for i, s := range re.names {
+			if name == s {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// LiteralPrefix returns a literal string that must begin any match of the
+// regular expression re. It returns the boolean true if the literal string
+// comprises the entire regular expression.
+func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
+	expr := re.expr
+	if hasTopLevelAlt(expr) {
+		return "", false
+	}
+	// An anchor at the start of the text does not prevent a literal prefix,
+	// but the prefix is then not the whole expression.
+	complete = true
+	for _, anchor := range []string{`^`, `\A`} {
+		if strings.HasPrefix(expr, anchor) {
+			expr = expr[len(anchor):]
+			complete = false
+			break
+		}
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(expr); {
+		c, n := utf8.DecodeRuneInString(expr[i:])
+		switch {
+		case c == '\\':
+			if i+1 >= len(expr) || !isASCIIPunct(expr[i+1]) {
+				return buf.String(), false
+			}
+			c, n = rune(expr[i+1]), 2
+		case c < utf8.RuneSelf && strings.IndexByte(`.+*?()|[]{}^$`, byte(c)) >= 0:
+			return buf.String(), false
+		}
+		// A literal that is repeated is not part of the prefix.
+		if i+n < len(expr) && strings.IndexByte(`*+?{`, expr[i+n]) >= 0 {
+			return buf.String(), false
+		}
+		buf.WriteRune(c)
+		i += n
+	}
+	return buf.String(), complete
+}
+
+// hasTopLevelAlt returns true if the given pattern contains an alternation
+// that is not inside a group or bracket expression.
+func hasTopLevelAlt(expr string) bool {
+	depth := 0
+	inClass := false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			// A "]" at the start of a class is literal.
+			if strings.HasPrefix(expr[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(expr[i+1:], "]") {
+				i++
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '|' && depth == 0:
+			return true
+		}
+	}
+	return false
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// MarshalText implements encoding.TextMarshaler. The output matches that of
+// calling the String method.
+func (re *Regexp) MarshalText() ([]byte, error) {
+	return []byte(re.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler by calling Compile on
+// the encoded value.
+func (re *Regexp) UnmarshalText(text []byte) error {
+	newRE, err := Compile(string(text))
+	if err != nil {
+		return err
+	}
+	*re = *newRE
+	return nil
+}
+
+// doExecute finds the first match in either b or s, whichever is in use,
+// beginning at or after pos. It returns the bounds of the match and its
+// first ncap-1 captures in the form used by the regexp API, or nil if there
+// is no match. m is used as scratch space.
+func (re *Regexp) doExecute(b []byte, s string, pos, ncap int, m *onig.Match) []int {
+	var found bool
+	if b != nil {
+		found = re.re.SearchBytesFromInto(m, b, pos, onig.NoMatchOpts)
+	} else {
+		found = re.re.SearchFromInto(m, s, pos, onig.NoMatchOpts)
+	}
+	if !found {
+		return nil
+	}
+	if re.longest[0] != nil {
+		// The trials use their own match so that m still holds the match
+		// found above if none of them succeeds, which can happen when a
+		// lookahead assertion looks further than the truncated text.
+		if a := re.doExecuteLongest(b, s, m.Bounds().Start, ncap, onig.NewMatch()); a != nil {
+			return a
+		}
+	}
+	return indices(m, 0, ncap)
+}
+
+// doExecuteLongest finds the longest match beginning at start, which must
+// be the position of a match already found by re. The end-anchored patterns
+// are matched against successively shorter prefixes of the text, each
+// extending up to two characters past the candidate end e, so that the
+// first match found is the longest. m is used as scratch space, and the
+// result is nil if no prefix matches.
+func (re *Regexp) doExecuteLongest(b []byte, s string, start, ncap int, m *onig.Match) []int {
+	end := len(s)
+	if b != nil {
+		end = len(b)
+	}
+	// next holds the positions of the first n characters after e, most
+	// recent first, so that next[n-1] is where the text is cut.
+	var next [2]int
+	n := 0
+	for e := end; e >= start; e-- {
+		cut := e
+		if n > 0 {
+			cut = next[n-1]
+		}
+		var found bool
+		if b != nil {
+			if e < end && !utf8.RuneStart(b[e]) {
+				continue
+			}
+			found = re.longest[n].MatchBytesAtInto(m, b[:cut], start, onig.NoMatchOpts)
+		} else {
+			if e < end && !utf8.RuneStart(s[e]) {
+				continue
+			}
+			found = re.longest[n].MatchAtInto(m, s[:cut], start, onig.NoMatchOpts)
+		}
+		if found && m.Bounds().End == cut {
+			a := indices(m, re.longestShift, ncap)
+			a[0], a[1] = start, e
+			return a
+		}
+		next[1], next[0] = next[0], e
+		n = min(n+1, len(next))
+	}
+	return nil
+}
+
+// indices returns the given match in the form used by the regexp API,
+// where the first ncap pairs of elements give the bounds of the match and
+// each capture, skipping the first shift captures, and unset captures are
+// represented as -1.
+func indices(m *onig.Match, shift, ncap int) []int {
+	ret := make([]int, 2*ncap)
+	for i := 0; i < ncap; i++ {
+		idx := i
+		if i > 0 {
+			idx += shift
+		}
+		span := m.Capture(idx)
+		ret[2*i] = span.Start
+		ret[2*i+1] = span.End
+	}
+	return ret
+}
+
+// find returns the indices of the first match in b or s, or nil if there is
+// none.
+func (re *Regexp) find(b []byte, s string, ncap int) []int {
+	return re.doExecute(b, s, 0, ncap, onig.NewMatch())
+}
+
+// allMatches calls deliver at most n times with the indices of successive
+// non-overlapping matches in b or s. An empty match that immediately
+// follows a previous match is ignored.
+func (re *Regexp) allMatches(b []byte, s string, n, ncap int, deliver func([]int)) {
+	end := len(s)
+	if b != nil {
+		end = len(b)
+	}
+	m := onig.NewMatch()
+	for pos, i, prevMatchEnd := 0, 0, -1; i < n && pos <= end; {
+		match := re.doExecute(b, s, pos, ncap, m)
+		if match == nil {
+			break
+		}
+		bounds := onig.Span{Start: match[0], End: match[1]}
+
+		accept := true
+		if bounds.End == pos {
+			// An empty match, so we must advance by one character to make
+			// progress.
+			if bounds.Start == prevMatchEnd {
+				accept = false
+			}
+			var width int
+			if b != nil {
+				_, width = utf8.DecodeRune(b[pos:end])
+			} else {
+				_, width = utf8.DecodeRuneInString(s[pos:end])
+			}
+			if width > 0 {
+				pos += width
+			} else {
+				pos = end + 1
+			}
+		} else {
+			pos = bounds.End
+		}
+		prevMatchEnd = bounds.End
+
+		if accept {
+			deliver(match)
+			i++
+		}
+	}
+}
+
+// readAll reads the remaining content of the given reader.
+func readAll(r io.RuneReader) string {
+	var buf strings.Builder
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return buf.String()
+		}
+		buf.WriteRune(c)
+	}
+}
+
+// MatchReader reports whether the text returned by the RuneReader contains
+// any match of the regular expression re.
+func (re *Regexp) MatchReader(r io.RuneReader) bool {
+	return re.MatchString(readAll(r))
+}
+
+// MatchString reports whether the string s contains any match of the
+// regular expression re.
+func (re *Regexp) MatchString(s string) bool {
+	return re.re.SearchFrom(s, 0, onig.NoMatchOpts) != nil
+}
+
+// Match reports whether the byte slice b contains any match of the regular
+// expression re.
+func (re *Regexp) Match(b []byte) bool {
+	return re.re.SearchBytesFrom(b, 0, onig.NoMatchOpts) != nil
+}
+
+// Find returns a slice holding the text of the leftmost match in b of the
+// regular expression. A return value of nil indicates no match.
+func (re *Regexp) Find(b []byte) []byte {
+	a := re.find(b, "", 1)
+	if a == nil {
+		return nil
+	}
+	return b[a[0]:a[1]:a[1]]
+}
+
+// FindIndex returns a two-element slice of integers defining the location
+// of the leftmost match in b of the regular expression. The match itself is
+// at b[loc[0]:loc[1]]. A return value of nil indicates no match.
+func (re *Regexp) FindIndex(b []byte) (loc []int) {
+	return re.find(b, "", 1)
+}
+
+// FindString returns a string holding the text of the leftmost match in s
+// of the regular expression. If there is no match, the return value is an
+// empty string, but it will also be empty if the regular expression
+// successfully matches an empty string. Use FindStringIndex or
+// FindStringSubmatch if it is necessary to distinguish these cases.
+func (re *Regexp) FindString(s string) string {
+	a := re.find(nil, s, 1)
+	if a == nil {
+		return ""
+	}
+	return s[a[0]:a[1]]
+}
+
+// FindStringIndex returns a two-element slice of integers defining the
+// location of the leftmost match in s of the regular expression. The match
+// itself is at s[loc[0]:loc[1]]. A return value of nil indicates no match.
+func (re *Regexp) FindStringIndex(s string) (loc []int) {
+	return re.find(nil, s, 1)
+}
+
+// FindReaderIndex returns a two-element slice of integers defining the
+// location of the leftmost match of the regular expression in text read
+// from the RuneReader. The match text was found in the input stream at byte
+// offset loc[0] through loc[1]-1. A return value of nil indicates no match.
+func (re *Regexp) FindReaderIndex(r io.RuneReader) (loc []int) {
+	return re.find(nil, readAll(r), 1)
+}
+
+// FindSubmatch returns a slice of slices holding the text of the leftmost
+// match of the regular expression in b and the matches, if any, of its
+// subexpressions. A return value of nil indicates no match.
+func (re *Regexp) FindSubmatch(b []byte) [][]byte {
+	a := re.find(b, "", re.NumSubexp()+1)
+	if a == nil {
+		return nil
+	}
+	ret := make([][]byte, 1+re.NumSubexp())
+	for i := range ret {
+		if 2*i < len(a) && a[2*i] >= 0 {
+			ret[i] = b[a[2*i]:a[2*i+1]:a[2*i+1]]
+		}
+	}
+	return ret
+}
+
+// FindSubmatchIndex returns a slice holding the index pairs identifying the
+// leftmost match of the regular expression in b and the matches, if any, of
+// its subexpressions. A return value of nil indicates no match.
+func (re *Regexp) FindSubmatchIndex(b []byte) []int {
+	return re.find(b, "", re.NumSubexp()+1)
+}
+
+// FindStringSubmatch returns a slice of strings holding the text of the
+// leftmost match of the regular expression in s and the matches, if any, of
+// its subexpressions. A return value of nil indicates no match.
+func (re *Regexp) FindStringSubmatch(s string) []string {
+	a := re.find(nil, s, re.NumSubexp()+1)
+	if a == nil {
+		return nil
+	}
+	ret := make([]string, 1+re.NumSubexp())
+	for i := range ret {
+		if 2*i < len(a) && a[2*i] >= 0 {
+			ret[i] = s[a[2*i]:a[2*i+1]]
+		}
+	}
+	return ret
+}
+
+// FindStringSubmatchIndex returns a slice holding the index pairs
+// identifying the leftmost match of the regular expression in s and the
+// matches, if any, of its subexpressions. A return value of nil indicates
+// no match.
+func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	return re.find(nil, s, re.NumSubexp()+1)
+}
+
+// FindReaderSubmatchIndex returns a slice holding the index pairs
+// identifying the leftmost match of the regular expression of text read by
+// the RuneReader, and the matches, if any, of its subexpressions. A return
+// value of nil indicates no match.
+func (re *Regexp) FindReaderSubmatchIndex(r io.RuneReader) []int {
+	return re.find(nil, readAll(r), re.NumSubexp()+1)
+}
+
+// FindAll is the 'All' version of Find; it returns a slice of all
+// successive matches of the expression. A return value of nil indicates no
+// match.
+func (re *Regexp) FindAll(b []byte, n int) [][]byte {
+	if n < 0 {
+		n = len(b) + 1
+	}
+	var result [][]byte
+	re.allMatches(b, "", n, 1, func(match []int) {
+		result = append(result, b[match[0]:match[1]:match[1]])
+	})
+	return result
+}
+
+// FindAllIndex is the 'All' version of FindIndex; it returns a slice of all
+// successive matches of the expression. A return value of nil indicates no
+// match.
+func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
+	if n < 0 {
+		n = len(b) + 1
+	}
+	var result [][]int
+	re.allMatches(b, "", n, 1, func(match []int) {
+		result = append(result, match)
+	})
+	return result
+}
+
+// FindAllString is the 'All' version of FindString; it returns a slice of
+// all successive matches of the expression. A return value of nil indicates
+// no match.
+func (re *Regexp) FindAllString(s string, n int) []string {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	var result []string
+	re.allMatches(nil, s, n, 1, func(match []int) {
+		result = append(result, s[match[0]:match[1]])
+	})
+	return result
+}
+
+// FindAllStringIndex is the 'All' version of FindStringIndex; it returns a
+// slice of all successive matches of the expression. A return value of nil
+// indicates no match.
+func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	var result [][]int
+	re.allMatches(nil, s, n, 1, func(match []int) {
+		result = append(result, match)
+	})
+	return result
+}
+
+// FindAllSubmatch is the 'All' version of FindSubmatch; it returns a slice
+// of all successive matches of the expression. A return value of nil
+// indicates no match.
+func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
+	if n < 0 {
+		n = len(b) + 1
+	}
+	var result [][][]byte
+	re.allMatches(b, "", n, re.NumSubexp()+1, func(match []int) {
+		slice := make([][]byte, len(match)/2)
+		for j := range slice {
+			if match[2*j] >= 0 {
+				slice[j] = b[match[2*j]:match[2*j+1]:match[2*j+1]]
+			}
+		}
+		result = append(result, slice)
+	})
+	return result
+}
+
+// FindAllSubmatchIndex is the 'All' version of FindSubmatchIndex; it
+// returns a slice of all successive matches of the expression. A return
+// value of nil indicates no match.
+func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
+	if n < 0 {
+		n = len(b) + 1
+	}
+	var result [][]int
+	re.allMatches(b, "", n, re.NumSubexp()+1, func(match []int) {
+		result = append(result, match)
+	})
+	return result
+}
+
+// FindAllStringSubmatch is the 'All' version of FindStringSubmatch; it
+// returns a slice of all successive matches of the expression. A return
+// value of nil indicates no match.
+func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	var result [][]string
+	re.allMatches(nil, s, n, re.NumSubexp()+1, func(match []int) {
+		slice := make([]string, len(match)/2)
+		for j := range slice {
+			if match[2*j] >= 0 {
+				slice[j] = s[match[2*j]:match[2*j+1]]
+			}
+		}
+		result = append(result, slice)
+	})
+	return result
+}
+
+// FindAllStringSubmatchIndex is the 'All' version of
+// FindStringSubmatchIndex; it returns a slice of all successive matches of
+// the expression. A return value of nil indicates no match.
+func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	var result [][]int
+	re.allMatches(nil, s, n, re.NumSubexp()+1, func(match []int) {
+		result = append(result, match)
+	})
+	return result
+}
+
+// Split slices s into substrings separated by the expression and returns a
+// slice of the substrings between those expression matches.
+//
+// The count determines the number of substrings to return:
+//
+//	n > 0: at most n substrings; the last substring will be the unsplit remainder.
+//	n == 0: the result is nil (zero substrings)
+//	n < 0: all substrings
+func (re *Regexp) Split(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	if len(re.expr) > 0 && len(s) == 0 {
+		return []string{""}
+	}
+
+	matches := re.FindAllStringIndex(s, n)
+	strs := make([]string, 0, len(matches))
+	beg := 0
+	end := 0
+	for _, match := range matches {
+		if n > 0 && len(strs) == n-1 {
+			break
+		}
+		end = match[0]
+		if match[1] != 0 {
+			strs = append(strs, s[beg:end])
+		}
+		beg = match[1]
+	}
+	if end != len(s) {
+		strs = append(strs, s[beg:])
+	}
+	return strs
+}
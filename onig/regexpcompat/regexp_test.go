@@ -0,0 +1,367 @@
+package regexpcompat
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// regexpAPI is the method set shared by regexp.Regexp and Regexp, which
+// the conformance tests below run against both implementations.
+type regexpAPI interface {
+	encoding.TextMarshaler
+	String() string
+	Longest()
+	NumSubexp() int
+	SubexpNames() []string
+	SubexpIndex(name string) int
+	LiteralPrefix() (prefix string, complete bool)
+	Match(b []byte) bool
+	MatchString(s string) bool
+	MatchReader(r io.RuneReader) bool
+	Find(b []byte) []byte
+	FindIndex(b []byte) []int
+	FindString(s string) string
+	FindStringIndex(s string) []int
+	FindReaderIndex(r io.RuneReader) []int
+	FindSubmatch(b []byte) [][]byte
+	FindSubmatchIndex(b []byte) []int
+	FindStringSubmatch(s string) []string
+	FindStringSubmatchIndex(s string) []int
+	FindReaderSubmatchIndex(r io.RuneReader) []int
+	FindAll(b []byte, n int) [][]byte
+	FindAllIndex(b []byte, n int) [][]int
+	FindAllString(s string, n int) []string
+	FindAllStringIndex(s string, n int) [][]int
+	FindAllSubmatch(b []byte, n int) [][][]byte
+	FindAllSubmatchIndex(b []byte, n int) [][]int
+	FindAllStringSubmatch(s string, n int) [][]string
+	FindAllStringSubmatchIndex(s string, n int) [][]int
+	ReplaceAll(src, repl []byte) []byte
+	ReplaceAllLiteral(src, repl []byte) []byte
+	ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte
+	ReplaceAllString(src, repl string) string
+	ReplaceAllLiteralString(src, repl string) string
+	ReplaceAllStringFunc(src string, repl func(string) string) string
+	Expand(dst []byte, template []byte, src []byte, match []int) []byte
+	ExpandString(dst []byte, template string, src string, match []int) []byte
+	Split(s string, n int) []string
+}
+
+var (
+	_ regexpAPI = (*regexp.Regexp)(nil)
+	_ regexpAPI = (*Regexp)(nil)
+)
+
+var conformanceTests = []struct {
+	Pattern string
+	Inputs  []string
+}{
+	{``, []string{``, `abc`}},
+	{`a`, []string{``, `a`, `bab`, `aaa`}},
+	{`abc`, []string{`abc`, `xabcx`, `ab`}},
+	{`a*`, []string{``, `baaab`, `aaa`, `bb`}},
+	{`a*?`, []string{`aaa`}},
+	{`x*`, []string{`abc`, `xxaxx`, `日本語`}},
+	{`a+b+`, []string{`aabbaab`, `ab`, `ba`}},
+	{`a|b`, []string{`cab`, `ccc`}},
+	{`(a)|(b)`, []string{`cab`, `ba`}},
+	{`(a)|b`, []string{`b`}},
+	{`(\w+)@(\w+)\.com`, []string{`mail bob@example.com and amy@test.com`, `none`}},
+	{`(?P<first>\w+) (?P<last>\w+)`, []string{`Ada Lovelace`, `Alan Turing, Grace Hopper`}},
+	{`(?<year>\d{4})-(?<month>\d{2})`, []string{`on 2006-01 or 2024-12`}},
+	{`(?i)hello`, []string{`HeLLo world`}},
+	{`(?s)a.b`, []string{"a\nb"}},
+	{`a.b`, []string{"a\nb", "axb"}},
+	{`(?m)^\w+`, []string{"one\ntwo\nthree"}},
+	{`^\w+`, []string{"one\ntwo"}},
+	{`\bfoo\b`, []string{`foo food afoo foo`}},
+	{`[[:digit:]]+`, []string{`a12b345`}},
+	{`[^a-c]+`, []string{`abcdefabc`}},
+	{`\p{Greek}+`, []string{`abc αβγ def`}},
+	{`日本`, []string{`日本語の日本`}},
+	{`(a*)+`, []string{`b`}},
+	{`(a|ab)(c|bcd)(d*)`, []string{`abcd`}},
+	{`a{2,3}`, []string{`aaaaaaa`}},
+	{`,`, []string{`a,b,c,,d`, ``, `,`}},
+	{` *`, []string{`a b  c`}},
+	{`\.`, []string{`a.b.c`}},
+	{`(\d)?x`, []string{`x1x`}},
+	{`\b`, []string{`ab cd`, ``}},
+	{`\w+\b`, []string{`ab cd!`}},
+	{`\pL+`, []string{`abc`, `a1β`}},
+	{`[\PL]+`, []string{`ab12cd`}},
+	{`(?s).(?-s:.)`, []string{"\n\n", "\nx"}},
+	{`(?i)a(?-i)b`, []string{`AB Ab aB ab`}},
+	{`ab$|a`, []string{`abc`, `ab`, `ab ab`}},
+	{`(?m)ab$|a`, []string{"abc\nab\nab"}},
+	{`ab\z|a`, []string{`abc`, `ab`}},
+	{`ab\b|a`, []string{`abc`, `ab c`, `ab`}},
+	{`ab\B|a`, []string{`abc`, `ab c`, `ab`}},
+	{`(a|ab)\b(c)?`, []string{`ab c`, `abc`}},
+}
+
+var conformanceRepls = []string{
+	``,
+	`[$0]`,
+	`$1-$2`,
+	`${1}x`,
+	`$1x`,
+	`$first:${last}`,
+	`$$`,
+	`${`,
+	`$99`,
+	`$`,
+}
+
+func TestConformance(t *testing.T) {
+	for _, test := range conformanceTests {
+		t.Run(test.Pattern, func(t *testing.T) {
+			want := regexp.MustCompile(test.Pattern)
+			got, err := Compile(test.Pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkConformance(t, got, want, test.Inputs)
+
+			want.Longest()
+			got.Longest()
+			t.Run("Longest", func(t *testing.T) {
+				checkConformance(t, got, want, test.Inputs)
+			})
+		})
+	}
+}
+
+func TestConformancePOSIX(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Inputs  []string
+	}{
+		{`a+|a+b`, []string{`aab`}},
+		{`(a|ab)(c|bcd)`, []string{`abcd`}},
+		{`[[:alpha:]]+`, []string{`12abc34`}},
+		{`x*`, []string{`axxb`}},
+		{`ab$|a`, []string{`abc`, `xab`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Pattern, func(t *testing.T) {
+			want := regexp.MustCompilePOSIX(test.Pattern)
+			got, err := CompilePOSIX(test.Pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkConformance(t, got, want, test.Inputs)
+		})
+	}
+}
+
+func checkConformance(t *testing.T, got, want regexpAPI, inputs []string) {
+	t.Helper()
+
+	check := func(method string, arg any, g, w any) {
+		t.Helper()
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("wrong %s(%q) result\ngot:  %#v\nwant: %#v", method, arg, g, w)
+		}
+	}
+
+	check("String", nil, got.String(), want.String())
+	check("NumSubexp", nil, got.NumSubexp(), want.NumSubexp())
+	check("SubexpNames", nil, got.SubexpNames(), want.SubexpNames())
+	for _, name := range append(want.SubexpNames(), "nonexistent") {
+		check("SubexpIndex", name, got.SubexpIndex(name), want.SubexpIndex(name))
+	}
+
+	for _, s := range inputs {
+		b := []byte(s)
+		check("Match", s, got.Match(b), want.Match(b))
+		check("MatchString", s, got.MatchString(s), want.MatchString(s))
+		check("MatchReader", s, got.MatchReader(strings.NewReader(s)), want.MatchReader(strings.NewReader(s)))
+		check("Find", s, got.Find(b), want.Find(b))
+		check("FindIndex", s, got.FindIndex(b), want.FindIndex(b))
+		check("FindString", s, got.FindString(s), want.FindString(s))
+		check("FindStringIndex", s, got.FindStringIndex(s), want.FindStringIndex(s))
+		check("FindReaderIndex", s, got.FindReaderIndex(strings.NewReader(s)), want.FindReaderIndex(strings.NewReader(s)))
+		check("FindSubmatch", s, got.FindSubmatch(b), want.FindSubmatch(b))
+		check("FindSubmatchIndex", s, got.FindSubmatchIndex(b), want.FindSubmatchIndex(b))
+		check("FindStringSubmatch", s, got.FindStringSubmatch(s), want.FindStringSubmatch(s))
+		check("FindStringSubmatchIndex", s, got.FindStringSubmatchIndex(s), want.FindStringSubmatchIndex(s))
+		check("FindReaderSubmatchIndex", s, got.FindReaderSubmatchIndex(strings.NewReader(s)), want.FindReaderSubmatchIndex(strings.NewReader(s)))
+
+		for _, n := range []int{-1, 0, 1, 2} {
+			arg := fmt.Sprintf("%s, %d", s, n)
+			check("FindAll", arg, got.FindAll(b, n), want.FindAll(b, n))
+			check("FindAllIndex", arg, got.FindAllIndex(b, n), want.FindAllIndex(b, n))
+			check("FindAllString", arg, got.FindAllString(s, n), want.FindAllString(s, n))
+			check("FindAllStringIndex", arg, got.FindAllStringIndex(s, n), want.FindAllStringIndex(s, n))
+			check("FindAllSubmatch", arg, got.FindAllSubmatch(b, n), want.FindAllSubmatch(b, n))
+			check("FindAllSubmatchIndex", arg, got.FindAllSubmatchIndex(b, n), want.FindAllSubmatchIndex(b, n))
+			check("FindAllStringSubmatch", arg, got.FindAllStringSubmatch(s, n), want.FindAllStringSubmatch(s, n))
+			check("FindAllStringSubmatchIndex", arg, got.FindAllStringSubmatchIndex(s, n), want.FindAllStringSubmatchIndex(s, n))
+			check("Split", arg, got.Split(s, n), want.Split(s, n))
+		}
+
+		for _, repl := range conformanceRepls {
+			arg := fmt.Sprintf("%s, %s", s, repl)
+			check("ReplaceAllString", arg, got.ReplaceAllString(s, repl), want.ReplaceAllString(s, repl))
+			check("ReplaceAllLiteralString", arg, got.ReplaceAllLiteralString(s, repl), want.ReplaceAllLiteralString(s, repl))
+			check("ReplaceAll", arg, got.ReplaceAll(b, []byte(repl)), want.ReplaceAll(b, []byte(repl)))
+			check("ReplaceAllLiteral", arg, got.ReplaceAllLiteral(b, []byte(repl)), want.ReplaceAllLiteral(b, []byte(repl)))
+
+			if match := want.FindStringSubmatchIndex(s); match != nil {
+				check("ExpandString", arg, got.ExpandString(nil, repl, s, match), want.ExpandString(nil, repl, s, match))
+				check("Expand", arg, got.Expand(nil, []byte(repl), b, match), want.Expand(nil, []byte(repl), b, match))
+			}
+		}
+
+		upper := func(s string) string { return "<" + strings.ToUpper(s) + ">" }
+		check("ReplaceAllStringFunc", s, got.ReplaceAllStringFunc(s, upper), want.ReplaceAllStringFunc(s, upper))
+		check("ReplaceAllFunc", s, got.ReplaceAllFunc(b, bytes.ToUpper), want.ReplaceAllFunc(b, bytes.ToUpper))
+	}
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	tests := []string{
+		``,
+		`abc`,
+		`abc.*`,
+		`abc*`,
+		`a\.b`,
+		`a\db`,
+		`ab|cd`,
+		`ab(c|d)`,
+		`[ab]c`,
+		`(?i)abc`,
+		`^abc`,
+		`\Aabc`,
+		`日本.`,
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			wantPrefix, wantComplete := regexp.MustCompile(expr).LiteralPrefix()
+			gotPrefix, gotComplete := MustCompile(expr).LiteralPrefix()
+			if gotPrefix != wantPrefix || gotComplete != wantComplete {
+				t.Errorf("wrong result %q, %#v; want %q, %#v", gotPrefix, gotComplete, wantPrefix, wantComplete)
+			}
+		})
+	}
+}
+
+func TestQuoteMeta(t *testing.T) {
+	tests := []string{
+		``,
+		`hello`,
+		`\.+*?()|[]{}^$`,
+		`1.5-2.0?`,
+		`日本.語`,
+	}
+
+	for _, s := range tests {
+		t.Run(s, func(t *testing.T) {
+			want := regexp.QuoteMeta(s)
+			if got := QuoteMeta(s); got != want {
+				t.Errorf("wrong result %q; want %q", got, want)
+			}
+			if !MustCompile(QuoteMeta(s)).MatchString(s) {
+				t.Errorf("quoted pattern does not match %q", s)
+			}
+		})
+	}
+}
+
+func TestLongestAssertions(t *testing.T) {
+	// Lookahead at the end of a match sees at most two characters past it
+	// when searching for the leftmost-longest match, so the last case finds
+	// "a" rather than "ab". Package regexp does not support lookahead, so
+	// these are not conformance tests.
+	tests := []struct {
+		Pattern string
+		Input   string
+		Want    [][]int
+	}{
+		{`\b`, `ab cd`, [][]int{{0, 0}, {2, 2}, {3, 3}, {5, 5}}},
+		{`a+(?=b)`, `aab ab a`, [][]int{{0, 2}, {4, 5}}},
+		{`(a)(?=.)`, `aaa`, [][]int{{0, 1, 0, 1}, {1, 2, 1, 2}}},
+		{`a(?=bc)|a\w`, `abc`, [][]int{{0, 2}}},
+		{`ab(?=cde)|a`, `abcde`, [][]int{{0, 1}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Pattern, func(t *testing.T) {
+			re := MustCompile(test.Pattern)
+			re.Longest()
+			got := re.FindAllStringSubmatchIndex(test.Input, -1)
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf("wrong result %v; want %v", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	_, err := Compile(`a(b`)
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if got, want := err.Error(), "error parsing regexp: end pattern with unmatched parenthesis: `a(b`"; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("no panic for invalid pattern")
+		}
+	}()
+	MustCompile(`a(b`)
+}
+
+func TestUnmarshalText(t *testing.T) {
+	var re Regexp
+	if err := re.UnmarshalText([]byte(`a(b+)`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := re.FindStringSubmatch(`xabbb`), []string{`abbb`, `bbb`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result %#v; want %#v", got, want)
+	}
+	text, err := re.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(text), `a(b+)`; got != want {
+		t.Errorf("wrong text %q; want %q", got, want)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		Expr string
+		Want string
+	}{
+		{`(?P<a>x)`, `(?<a>x)`},
+		{`\(?P<a>x\)`, `\(?P<a>x\)`},
+		{`[(?P<a>](?P<b>x)`, `[(?P<a>](?<b>x)`},
+		{`[]](?P<b>x)`, `[]](?<b>x)`},
+		{`[^]](?P<b>x)`, `[^]](?<b>x)`},
+		{`[[:alpha:]](?P<b>x)`, `[[:alpha:]](?<b>x)`},
+		{`\pL\PN`, `\p{L}\P{N}`},
+		{`[\pL]\p{Greek}\\pL`, `[\p{L}]\p{Greek}\\pL`},
+		{`(?-s:.)(?i-m)`, `(?s-s:.)(?i-m)`},
+		{`[(?-s:]`, `[(?-s:]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Expr, func(t *testing.T) {
+			if got := translate(test.Expr); got != test.Want {
+				t.Errorf("wrong result %q; want %q", got, test.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package regexpcompat
+
+import (
+	"unicode/utf8"
+
+	"github.com/apparentlymart/go-onig/onig"
+	"github.com/apparentlymart/go-onig/onig/internal/template"
+)
+
+// ReplaceAllString returns a copy of src, replacing matches of the Regexp
+// with the replacement string repl. Inside repl, $ signs are interpreted as
+// in Expand.
+func (re *Regexp) ReplaceAllString(src, repl string) string {
+	b := re.replaceAll(nil, src, func(dst []byte, match []int) []byte {
+		return re.expand(dst, repl, nil, src, match)
+	})
+	return string(b)
+}
+
+// ReplaceAllLiteralString returns a copy of src, replacing matches of the
+// Regexp with the replacement string repl. The replacement repl is
+// substituted directly, without using Expand.
+func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
+	return string(re.replaceAll(nil, src, func(dst []byte, match []int) []byte {
+		return append(dst, repl...)
+	}))
+}
+
+// ReplaceAllStringFunc returns a copy of src in which all matches of the
+// Regexp have been replaced by the return value of function repl applied to
+// the matched substring. The replacement returned by repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	b := re.replaceAll(nil, src, func(dst []byte, match []int) []byte {
+		return append(dst, repl(src[match[0]:match[1]])...)
+	})
+	return string(b)
+}
+
+// ReplaceAll returns a copy of src, replacing matches of the Regexp with
+// the replacement text repl. Inside repl, $ signs are interpreted as in
+// Expand.
+func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
+	srepl := string(repl)
+	return re.replaceAll(src, "", func(dst []byte, match []int) []byte {
+		return re.expand(dst, srepl, src, "", match)
+	})
+}
+
+// ReplaceAllLiteral returns a copy of src, replacing matches of the Regexp
+// with the replacement bytes repl. The replacement repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
+	return re.replaceAll(src, "", func(dst []byte, match []int) []byte {
+		return append(dst, repl...)
+	})
+}
+
+// ReplaceAllFunc returns a copy of src in which all matches of the Regexp
+// have been replaced by the return value of function repl applied to the
+// matched byte slice. The replacement returned by repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	return re.replaceAll(src, "", func(dst []byte, match []int) []byte {
+		return append(dst, repl(src[match[0]:match[1]])...)
+	})
+}
+
+// replaceAll appends to a new buffer the content of either bsrc or src,
+// whichever is in use, with each match replaced by the result of calling
+// repl.
+func (re *Regexp) replaceAll(bsrc []byte, src string, repl func(dst []byte, m []int) []byte) []byte {
+	lastMatchEnd := 0 // end position of the most recent match
+	searchPos := 0    // position where we next look for a match
+	var buf []byte
+	end := len(src)
+	if bsrc != nil {
+		end = len(bsrc)
+	}
+	m := onig.NewMatch()
+	for searchPos <= end {
+		a := re.doExecute(bsrc, src, searchPos, re.NumSubexp()+1, m)
+		if a == nil {
+			break
+		}
+
+		// Copy the unmatched characters before this match.
+		if bsrc != nil {
+			buf = append(buf, bsrc[lastMatchEnd:a[0]]...)
+		} else {
+			buf = append(buf, src[lastMatchEnd:a[0]]...)
+		}
+
+		// Now insert a copy of the replacement, but not for a match of the
+		// empty string immediately after another match, since otherwise
+		// patterns that match both empty and non-empty strings would
+		// replace twice.
+		if a[1] > lastMatchEnd || a[0] == 0 {
+			buf = repl(buf, a)
+		}
+		lastMatchEnd = a[1]
+
+		// Advance past this match, always by at least one character.
+		var width int
+		if bsrc != nil {
+			_, width = utf8.DecodeRune(bsrc[searchPos:end])
+		} else {
+			_, width = utf8.DecodeRuneInString(src[searchPos:end])
+		}
+		switch {
+		case searchPos+width > a[1]:
+			searchPos += width
+		case searchPos+1 > a[1]:
+			// Only at the end of the input, where width is zero.
+			searchPos++
+		default:
+			searchPos = a[1]
+		}
+	}
+
+	// Copy the unmatched characters after the last match.
+	if bsrc != nil {
+		buf = append(buf, bsrc[lastMatchEnd:]...)
+	} else {
+		buf = append(buf, src[lastMatchEnd:]...)
+	}
+	return buf
+}
+
+// Expand appends template to dst and returns the result; during the append,
+// Expand replaces variables in the template with corresponding matches
+// drawn from src. The match slice should have been returned by
+// FindSubmatchIndex.
+//
+// In the template, a variable is denoted by a substring of the form $name
+// or ${name}, where name is a non-empty sequence of letters, digits, and
+// underscores. A purely numeric name like $1 refers to the submatch with
+// the corresponding index; other names refer to capturing parentheses named
+// with the (?P<name>...) syntax. A reference to an out of range or
+// unmatched index or a name that is not present in the regular expression
+// is replaced with an empty slice.
+//
+// In the $name form, name is taken to be as long as possible: $1x is
+// equivalent to ${1x}, not ${1}x, and, $10 is equivalent to ${10}, not
+// ${1}0.
+//
+// To insert a literal $ in the output, use $$ in the template.
+func (re *Regexp) Expand(dst []byte, template []byte, src []byte, match []int) []byte {
+	return re.expand(dst, string(template), src, "", match)
+}
+
+// ExpandString is like Expand but the template and source are strings. It
+// appends to and returns a byte slice in order to give the calling code
+// control over allocation.
+func (re *Regexp) ExpandString(dst []byte, template string, src string, match []int) []byte {
+	return re.expand(dst, template, nil, src, match)
+}
+
+func (re *Regexp) expand(dst []byte, tmpl string, bsrc []byte, src string, match []int) []byte {
+	return template.Expand(dst, tmpl, func(dst []byte, name string, num int) []byte {
+		idx := num
+		if num < 0 {
+			idx = re.SubexpIndex(name)
+		}
+		if idx < 0 || 2*idx+1 >= len(match) || match[2*idx] < 0 {
+			return dst
+		}
+		if bsrc != nil {
+			return append(dst, bsrc[match[2*idx]:match[2*idx+1]]...)
+		}
+		return append(dst, src[match[2*idx]:match[2*idx+1]]...)
+	})
+}
@@ -0,0 +1,270 @@
+package onig
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"github.com/apparentlymart/go-onig/onig/internal/template"
+)
+
+// ReplaceWriter is an io.WriteCloser that replaces the matches of a regex in
+// the data written to it before passing the data on to another writer, as
+// returned by NewReplaceWriter.
+type ReplaceWriter struct {
+	w   io.Writer
+	rep streamReplacer
+	err error
+}
+
+// NewReplaceWriter returns a ReplaceWriter that writes to w the data
+// written to it, with each match of re replaced by the given template.
+//
+// The matches are the same as those found by SearchAll, and the template
+// is expanded as for the Expand method of a regexp.Regexp from the Go
+// standard library: "$1" or "${1}" stands for the text of the numbered
+// capture, "$name" or "${name}" for the named capture, resolved as for
+// NamedCapture, and "$$" for a literal "$".
+//
+// Data is passed on as soon as it is clear that it cannot be part of a
+// match, so only a window of the data is held back, as configured by
+// window, which bounds the length of a match in the same way as for
+// SearchAllReader. A longer match causes a *StreamWindowError, or if
+// window.SkipAhead is set can be missed and its text passed on unchanged,
+// as described for StreamWindow.MaxMatchLen. The remaining data is written
+// when Close is called, which does not close w.
+func NewReplaceWriter(w io.Writer, re *Regex, template string, window StreamWindow) *ReplaceWriter {
+	return &ReplaceWriter{
+		w:   w,
+		rep: newStreamReplacer(re, template, window),
+	}
+}
+
+// Write implements io.Writer. The data in p is always consumed once it has
+// been accepted, so if an error occurs while processing it or passing it on
+// then Write returns len(p) along with the error, and all subsequent calls
+// fail with the same error.
+func (w *ReplaceWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.rep.buf = append(w.rep.buf, p...)
+	if w.err = w.flush(false); w.err != nil {
+		return len(p), w.err
+	}
+	return len(p), nil
+}
+
+// Close writes any remaining data, after making the replacements in it.
+func (w *ReplaceWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.flush(true)
+	if w.err != nil {
+		return w.err
+	}
+	w.err = errWriterClosed
+	return nil
+}
+
+func (w *ReplaceWriter) flush(eof bool) error {
+	if err := w.rep.process(eof); err != nil {
+		return err
+	}
+	if len(w.rep.out) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.rep.out)
+	w.rep.out = w.rep.out[:0]
+	return err
+}
+
+var errWriterClosed = errors.New("write to closed ReplaceWriter")
+
+// ReplaceReader is an io.Reader that replaces the matches of a regex in the
+// data read from another reader, as returned by NewReplaceReader.
+type ReplaceReader struct {
+	r   io.Reader
+	rep streamReplacer
+	eof bool
+	err error
+}
+
+// NewReplaceReader returns a ReplaceReader that reads from r, replacing
+// each match of re with the given template. The matches and the template
+// are as for NewReplaceWriter, and so is the window of data held back.
+func NewReplaceReader(r io.Reader, re *Regex, template string, window StreamWindow) *ReplaceReader {
+	return &ReplaceReader{
+		r:   r,
+		rep: newStreamReplacer(re, template, window),
+	}
+}
+
+// Read implements io.Reader.
+func (r *ReplaceReader) Read(p []byte) (int, error) {
+	for len(r.rep.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.eof {
+			return 0, io.EOF
+		}
+		r.fill()
+	}
+	n := copy(p, r.rep.out)
+	r.rep.out = r.rep.out[:copy(r.rep.out, r.rep.out[n:])]
+	return n, nil
+}
+
+func (r *ReplaceReader) fill() {
+	chunk := max(r.rep.window.MaxMatchLen, 4096)
+	buf := r.rep.buf
+	if cap(buf)-len(buf) < chunk {
+		grown := make([]byte, len(buf), len(buf)+chunk)
+		copy(grown, buf)
+		buf = grown
+	}
+	n, err := r.r.Read(buf[len(buf) : len(buf)+chunk])
+	r.rep.buf = buf[:len(buf)+n]
+	switch {
+	case err == io.EOF:
+		r.eof = true
+	case err != nil:
+		r.err = err
+		return
+	}
+	if err := r.rep.process(r.eof); err != nil {
+		r.err = err
+	}
+}
+
+// streamReplacer makes the replacements for ReplaceWriter and ReplaceReader.
+// Its caller appends input to buf and then calls process, which appends to
+// out the output for as much of the input as can be decided.
+type streamReplacer struct {
+	re       *Regex
+	template string
+	window   StreamWindow
+	m        *Match
+
+	// buf holds the data that has not yet been discarded, which begins
+	// with some text that was already processed, kept for lookbehind.
+	// done is the offset in buf of the first byte that has not been
+	// processed, and pos is the offset at which to search for the next
+	// match, which is after done if the last match was empty.
+	buf       []byte
+	done, pos int
+
+	// base is the offset in the whole input of the start of buf.
+	base int
+
+	out []byte
+}
+
+func newStreamReplacer(re *Regex, template string, window StreamWindow) streamReplacer {
+	return streamReplacer{
+		re:       re,
+		template: template,
+		window:   window.withDefaults(),
+		m:        NewMatch(),
+	}
+}
+
+func (s *streamReplacer) process(eof bool) error {
+	data := s.buf
+	opts := NoMatchOpts
+	if !eof {
+		data = data[:completeLen(data)]
+		opts |= OptNotEOL
+	}
+	if s.base > 0 {
+		opts |= OptNotBOL
+	}
+	maxLen := s.window.MaxMatchLen
+
+	for s.pos <= len(data) {
+		found := s.re.SearchBytesFromInto(s.m, data, s.pos, opts)
+		if !found && eof {
+			break
+		}
+		if found {
+			span := s.m.Bounds()
+			if span.Len() > maxLen {
+				return &StreamWindowError{Offset: s.base + span.Start, MaxMatchLen: maxLen}
+			}
+			if eof || span.Start+maxLen < len(data) {
+				s.out = append(s.out, data[s.done:span.Start]...)
+				s.out = expandTemplate(s.out, s.template, s.re, data, s.m)
+				s.done = span.End
+				s.pos = scanNext(data, span)
+				continue
+			}
+		}
+
+		// No match that fits the window can begin where the window after
+		// it was fully buffered, so the text before there can be passed on
+		// if longer matches may be missed. Otherwise, a match that was
+		// found is kept until it can be confirmed.
+		if skip := len(data) - maxLen; skip > s.pos {
+			if !s.window.SkipAhead {
+				if found {
+					break
+				}
+				return &StreamWindowError{Offset: s.base + s.pos, MaxMatchLen: maxLen, Unfinished: true}
+			}
+			for skip > s.pos && !utf8.RuneStart(data[skip]) {
+				skip--
+			}
+			s.pos = skip
+			s.out = append(s.out, data[s.done:skip]...)
+			s.done = skip
+		}
+		break
+	}
+
+	if eof {
+		s.out = append(s.out, s.buf[min(s.done, len(s.buf)):]...)
+		s.buf = s.buf[:0]
+		s.done, s.pos = 0, 0
+		return nil
+	}
+	s.compact()
+	return nil
+}
+
+// compact discards the data that is no longer needed for lookbehind.
+func (s *streamReplacer) compact() {
+	keep := min(s.done, max(0, s.pos-s.window.Lookbehind))
+	for keep > 0 && !utf8.RuneStart(s.buf[keep]) {
+		keep--
+	}
+	if keep == 0 {
+		return
+	}
+	n := copy(s.buf, s.buf[keep:])
+	s.buf = s.buf[:n]
+	s.done -= keep
+	s.pos -= keep
+	s.base += keep
+}
+
+// expandTemplate appends tmpl to dst with its variables replaced by the
+// captures of m in src, as described for NewReplaceWriter. A reference to a name is
+// resolved as for NamedCapture, and a reference to a capture that does not
+// exist or did not participate in the match is replaced with nothing.
+func expandTemplate(dst []byte, tmpl string, re *Regex, src []byte, m *Match) []byte {
+	return template.Expand(dst, tmpl, func(dst []byte, name string, num int) []byte {
+		span := Span{-1, -1}
+		switch {
+		case num < 0:
+			span = NamedCapture(re, m, name)
+		case num <= m.CaptureCount():
+			span = m.Capture(num)
+		}
+		if span.Start < 0 {
+			return dst
+		}
+		return append(dst, span.Slice(src)...)
+	})
+}
@@ -0,0 +1,184 @@
+package onig
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReplaceWriter(t *testing.T) {
+	tests := []struct {
+		Pattern  string
+		Template string
+		Input    string
+		Want     string
+	}{
+		{`(?<n>\d+)`, `<${n}>`, "a1b22", "a<1>b<22>"},
+		{`x*`, `-`, "abxd", "-a-b--d-"},
+		{`(\w)(\d)?`, `[$2$1]`, "a1b", "[1a][b]"},
+		{`b`, `$$|$x|${1}|$`, "abc", "a$|||$c"},
+		{`^é`, `E`, "éé\né", "Eé\nE"},
+		{`z`, `-`, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Pattern+" "+test.Input, func(t *testing.T) {
+			re := MustNewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+			var buf bytes.Buffer
+			w := NewReplaceWriter(&buf, re, test.Template, StreamWindow{MaxMatchLen: 4, Lookbehind: 2})
+			for i := 0; i < len(test.Input); i++ {
+				if _, err := w.Write([]byte{test.Input[i]}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.Want {
+				t.Errorf("wrong result %q; want %q", got, test.Want)
+			}
+			if _, err := w.Write([]byte("x")); err == nil {
+				t.Errorf("Write after Close succeeded")
+			}
+		})
+	}
+}
+
+func TestReplaceStreams(t *testing.T) {
+	input := streamTestInput()
+	rnd := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		Pattern  string
+		Template string
+	}{
+		{`\bfoo\b`, `[$0]`},
+		{`(?<=a)b`, `B`},
+		{`(\d)(\d+)`, `$2$1`},
+		{`(?<x>x)|(?<e>é+)`, `<$x|$e>`},
+		{`^bar`, `BAR`},
+		{`foo$`, `FOO`},
+		{`x*`, `-`},
+	}
+	window := StreamWindow{MaxMatchLen: 16, Lookbehind: 8, SkipAhead: true}
+
+	for _, test := range tests {
+		re := MustNewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+		var want []byte
+		last := 0
+		for _, m := range re.SearchAll(input, NoMatchOpts) {
+			want = append(want, input[last:m.Bounds().Start]...)
+			want = expandTemplate(want, test.Template, re, []byte(input), m)
+			last = m.Bounds().End
+		}
+		want = append(want, input[last:]...)
+
+		for name, reader := range testReaders {
+			t.Run(test.Pattern+" "+name, func(t *testing.T) {
+				r := NewReplaceReader(reader(input), re, test.Template, window)
+				got, err := io.ReadAll(iotest.HalfReader(r))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("wrong result\ngot:  %q\nwant: %q", got, want)
+				}
+			})
+		}
+		t.Run(test.Pattern+" writer", func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewReplaceWriter(&buf, re, test.Template, window)
+			for rest := input; rest != ""; {
+				n := min(len(rest), 1+rnd.Intn(40))
+				if _, err := io.WriteString(w, rest[:n]); err != nil {
+					t.Fatal(err)
+				}
+				rest = rest[n:]
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("wrong result\ngot:  %q\nwant: %q", buf.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestReplaceStreamErrors(t *testing.T) {
+	re := MustNewRegex(`a+`, NoCompileOpts, SyntaxRuby)
+	input := "xx" + strings.Repeat("a", 100)
+	want := StreamWindowError{Offset: 2, MaxMatchLen: 10}
+	window := StreamWindow{MaxMatchLen: 10}
+
+	w := NewReplaceWriter(io.Discard, re, "b", window)
+	n, err := io.WriteString(w, input)
+	var windowErr *StreamWindowError
+	if !errors.As(err, &windowErr) || *windowErr != want {
+		t.Errorf("wrong error from writer %#v", err)
+	}
+	// The data was consumed even though it caused an error.
+	if n != len(input) {
+		t.Errorf("wrong count %d from writer; want %d", n, len(input))
+	}
+	if n, err := io.WriteString(w, "a"); n != 0 || !errors.As(err, &windowErr) {
+		t.Errorf("wrong result from later write %d, %#v", n, err)
+	}
+
+	r := NewReplaceReader(strings.NewReader(input), re, "b", window)
+	_, err = io.ReadAll(r)
+	if !errors.As(err, &windowErr) || *windowErr != want {
+		t.Errorf("wrong error from reader %#v", err)
+	}
+
+	// Without SkipAhead, a stretch longer than the window without a match
+	// is an error, even where a match is found after it.
+	input = "abcd" + strings.Repeat("x", 100) + "a"
+	want = StreamWindowError{Offset: 1, MaxMatchLen: 10, Unfinished: true}
+	w = NewReplaceWriter(io.Discard, re, "b", window)
+	err = nil
+	for i := 0; i < len(input) && err == nil; i++ {
+		_, err = w.Write([]byte{input[i]})
+	}
+	if !errors.As(err, &windowErr) || *windowErr != want {
+		t.Errorf("wrong error from writer %#v", err)
+	}
+	r = NewReplaceReader(iotest.OneByteReader(strings.NewReader(input)), re, "b", window)
+	_, err = io.ReadAll(r)
+	if !errors.As(err, &windowErr) || *windowErr != want {
+		t.Errorf("wrong error from reader %#v", err)
+	}
+
+	r = NewReplaceReader(iotest.TimeoutReader(strings.NewReader(input)), re, "b", StreamWindow{})
+	if _, err := io.ReadAll(r); err != iotest.ErrTimeout {
+		t.Errorf("wrong error %#v; want ErrTimeout", err)
+	}
+}
+
+func TestReplaceLongMatch(t *testing.T) {
+	// As for TestSearchReaderLongMatch, the first match is longer than the
+	// window, so it causes an error unless SkipAhead is set, in which case
+	// it is missed and its text is passed on unchanged, but the later match
+	// is still replaced.
+	re := MustNewRegex(`x.*y`, NoCompileOpts, SyntaxRuby)
+	long := "x" + strings.Repeat("z", 10000) + "y"
+	r := NewReplaceReader(strings.NewReader(long+"\nxzy"), re, "-", StreamWindow{MaxMatchLen: 16})
+	_, err := io.ReadAll(r)
+	var windowErr *StreamWindowError
+	if !errors.As(err, &windowErr) || *windowErr != (StreamWindowError{Offset: 0, MaxMatchLen: 16, Unfinished: true}) {
+		t.Errorf("wrong error %#v", err)
+	}
+
+	r = NewReplaceReader(strings.NewReader(long+"\nxzy"), re, "-", StreamWindow{MaxMatchLen: 16, SkipAhead: true})
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := long + "\n-"; string(got) != want {
+		t.Errorf("wrong result %q...; want %q...", got[len(got)-10:], want[len(want)-10:])
+	}
+}
@@ -0,0 +1,306 @@
+// Package rubyre provides Regexp and MatchData types with the semantics of
+// Ruby's classes of the same names, for code ported from Ruby.
+//
+// Oniguruma is the regular expression engine of Ruby, and SyntaxRuby is its
+// native syntax, so patterns behave as they do in Ruby. This package adds
+// Ruby's conventions for reporting and replacing matches on top of
+// onig.Regex and onig.Match.
+//
+// Where Ruby would return nil, such as for a group that did not
+// participate in a match, this package returns a nil *string, a negative
+// index or a false boolean result, as described for each method. Offsets
+// are in characters, as in Ruby, except where a method returns an
+// onig.Match or onig.Span.
+package rubyre
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// Regexp is a compiled regular expression with the semantics of Ruby's
+// Regexp class.
+//
+// A Regexp is safe for concurrent use by multiple goroutines.
+type Regexp struct {
+	re    *onig.Regex
+	names []string
+}
+
+// New compiles a regular expression with the given source and Ruby option
+// letters, which are any of "i", "m" and "x".
+func New(source, options string) (*Regexp, error) {
+	opts, err := onig.ParseCompileOptions(options, onig.SyntaxRuby)
+	if err != nil {
+		return nil, err
+	}
+	re, err := onig.NewRegex(source, opts, onig.SyntaxRuby)
+	if err != nil {
+		return nil, err
+	}
+	return newRegexp(re), nil
+}
+
+// MustNew is like New but panics if the regular expression is invalid.
+func MustNew(source, options string) *Regexp {
+	re, err := New(source, options)
+	if err != nil {
+		panic(err.Error())
+	}
+	return re
+}
+
+// Wrap returns a Regexp that uses the given compiled regex, which should
+// have been compiled with onig.SyntaxRuby for Ruby's semantics.
+func Wrap(re *onig.Regex) *Regexp {
+	return newRegexp(re)
+}
+
+func newRegexp(re *onig.Regex) *Regexp {
+	named := re.NamedCapturesFirst()
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return named[names[i]] < named[names[j]]
+	})
+	return &Regexp{re: re, names: names}
+}
+
+// Regex returns the underlying compiled regex.
+func (r *Regexp) Regex() *onig.Regex {
+	return r.re
+}
+
+// Source returns the source text of the regular expression.
+func (r *Regexp) Source() string {
+	return r.re.Pattern()
+}
+
+// Names returns the names of the named groups in the regular expression,
+// in the order they are first defined.
+func (r *Regexp) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Match returns the first match of the regular expression in the given
+// string, or nil if there is none.
+func (r *Regexp) Match(s string) *MatchData {
+	return r.newMatchData(s, r.re.Search(s, onig.NoMatchOpts))
+}
+
+// MatchFrom is like Match but begins searching at the given character
+// offset, counting from the end of the string if it is negative. The text
+// before that offset is still visible to lookbehind, and "\G" matches at
+// that offset. The result is nil if the offset is out of range.
+func (r *Regexp) MatchFrom(s string, pos int) *MatchData {
+	if pos < 0 {
+		pos += utf8.RuneCountInString(s)
+		if pos < 0 {
+			return nil
+		}
+	}
+	start := byteOffset(s, pos)
+	if start < 0 {
+		return nil
+	}
+	return r.newMatchData(s, r.re.SearchFrom(s, start, onig.NoMatchOpts))
+}
+
+// Index returns the character offset of the first match of the regular
+// expression in the given string, as Ruby's =~ operator does, or -1 if
+// there is none.
+func (r *Regexp) Index(s string) int {
+	m := r.re.Search(s, onig.NoMatchOpts)
+	if m == nil {
+		return -1
+	}
+	return charOffset(s, m.Bounds().Start)
+}
+
+// MatchP reports whether the regular expression matches the given string,
+// as Ruby's match? method does.
+func (r *Regexp) MatchP(s string) bool {
+	return r.re.Search(s, onig.NoMatchOpts) != nil
+}
+
+func (r *Regexp) newMatchData(s string, m *onig.Match) *MatchData {
+	if m == nil {
+		return nil
+	}
+	return &MatchData{regexp: r, str: s, m: m}
+}
+
+// MatchData is a single match of a Regexp, with the semantics of Ruby's
+// MatchData class.
+type MatchData struct {
+	regexp *Regexp
+	str    string
+	m      *onig.Match
+}
+
+// Regexp returns the regular expression that produced the match.
+func (md *MatchData) Regexp() *Regexp {
+	return md.regexp
+}
+
+// Input returns the string that was searched, as Ruby's MatchData#string
+// does.
+func (md *MatchData) Input() string {
+	return md.str
+}
+
+// Match returns the underlying match, whose spans are byte offsets into
+// the string that was searched.
+func (md *MatchData) Match() *onig.Match {
+	return md.m
+}
+
+// String returns the matched text.
+func (md *MatchData) String() string {
+	return md.m.Bounds().Substr(md.str)
+}
+
+// PreMatch returns the part of the string before the match.
+func (md *MatchData) PreMatch() string {
+	return md.str[:md.m.Bounds().Start]
+}
+
+// PostMatch returns the part of the string after the match.
+func (md *MatchData) PostMatch() string {
+	return md.str[md.m.Bounds().End:]
+}
+
+// Size returns the number of elements in the match, which is the number
+// of capture groups plus one for the whole match.
+func (md *MatchData) Size() int {
+	return md.m.CaptureCount() + 1
+}
+
+// Get returns the text of the group with the given index, where zero is the
+// whole match and negative indices count back from the last group, as for
+// Ruby's MatchData#[]. The result is false if the index is out of range or
+// the group did not participate in the match.
+func (md *MatchData) Get(index int) (string, bool) {
+	if index < 0 {
+		index += md.Size()
+	}
+	if index < 0 || index >= md.Size() {
+		return "", false
+	}
+	span := md.m.Capture(index)
+	if span.Start < 0 {
+		return "", false
+	}
+	return span.Substr(md.str), true
+}
+
+// Named returns the text of the group with the given name. If more than
+// one group has that name then the result is the last one that participated
+// in the match, as in Ruby. The result is false if there is no such group
+// or it did not participate in the match.
+func (md *MatchData) Named(name string) (string, bool) {
+	span := onig.NamedCapture(md.regexp.re, md.m, name)
+	if span.Start < 0 {
+		return "", false
+	}
+	return span.Substr(md.str), true
+}
+
+// ToA returns the whole match followed by the text of each group, with nil
+// for groups that did not participate, as Ruby's MatchData#to_a does.
+func (md *MatchData) ToA() []*string {
+	ret := make([]*string, md.Size())
+	for i := range ret {
+		ret[i] = md.value(i)
+	}
+	return ret
+}
+
+// Captures returns the text of each group, with nil for groups that did
+// not participate.
+func (md *MatchData) Captures() []*string {
+	return md.ToA()[1:]
+}
+
+// ValuesAt returns the text of the groups with each of the given indices,
+// as for Get, with nil for each that Get would report false.
+func (md *MatchData) ValuesAt(indices ...int) []*string {
+	ret := make([]*string, len(indices))
+	for i, idx := range indices {
+		if idx < 0 {
+			idx += md.Size()
+		}
+		if idx >= 0 && idx < md.Size() {
+			ret[i] = md.value(idx)
+		}
+	}
+	return ret
+}
+
+// NamedCaptures returns the text of each named group, as for Named, with
+// nil for groups that did not participate.
+func (md *MatchData) NamedCaptures() map[string]*string {
+	ret := make(map[string]*string, len(md.regexp.names))
+	for _, name := range md.regexp.names {
+		if text, ok := md.Named(name); ok {
+			ret[name] = &text
+		} else {
+			ret[name] = nil
+		}
+	}
+	return ret
+}
+
+func (md *MatchData) value(index int) *string {
+	text, ok := md.Get(index)
+	if !ok {
+		return nil
+	}
+	return &text
+}
+
+// Begin returns the character offset of the start of the group with the
+// given index, where zero is the whole match, or -1 if the group did not
+// participate in the match. Begin panics if the index is out of range.
+func (md *MatchData) Begin(index int) int {
+	span := md.m.Capture(index)
+	if span.Start < 0 {
+		return -1
+	}
+	return charOffset(md.str, span.Start)
+}
+
+// End returns the character offset of the end of the group with the given
+// index, where zero is the whole match, or -1 if the group did not
+// participate in the match. End panics if the index is out of range.
+func (md *MatchData) End(index int) int {
+	span := md.m.Capture(index)
+	if span.Start < 0 {
+		return -1
+	}
+	return charOffset(md.str, span.End)
+}
+
+// charOffset returns the number of characters in s before the given byte
+// offset.
+func charOffset(s string, b int) int {
+	return utf8.RuneCountInString(s[:b])
+}
+
+// byteOffset returns the byte offset in s of the given character offset,
+// or -1 if it is beyond the end of s.
+func byteOffset(s string, chars int) int {
+	b := 0
+	for ; chars > 0; chars-- {
+		if b == len(s) {
+			return -1
+		}
+		_, size := utf8.DecodeRuneInString(s[b:])
+		b += size
+	}
+	return b
+}
@@ -0,0 +1,269 @@
+package rubyre
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// The expected results in these tests follow the behavior of Ruby 3.
+
+func strs(values ...any) []*string {
+	ret := make([]*string, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			ret[i] = &s
+		}
+	}
+	return ret
+}
+
+func TestMatchData(t *testing.T) {
+	re := MustNew(`(?<first>\w+) (?<last>\w+)`, "")
+	md := re.Match("say: John Smith!")
+	if md == nil {
+		t.Fatal("no match")
+	}
+
+	if got, want := md.String(), "John Smith"; got != want {
+		t.Errorf("wrong String %q; want %q", got, want)
+	}
+	if got, want := md.PreMatch(), "say: "; got != want {
+		t.Errorf("wrong PreMatch %q; want %q", got, want)
+	}
+	if got, want := md.PostMatch(), "!"; got != want {
+		t.Errorf("wrong PostMatch %q; want %q", got, want)
+	}
+	if got, want := md.Captures(), strs("John", "Smith"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong Captures %v; want %v", got, want)
+	}
+	if got, want := md.NamedCaptures(), map[string]*string{"first": strs("John")[0], "last": strs("Smith")[0]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong NamedCaptures %v; want %v", got, want)
+	}
+	if got, want := re.Names(), []string{"first", "last"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong Names %q; want %q", got, want)
+	}
+	if got, ok := md.Named("last"); !ok || got != "Smith" {
+		t.Errorf("wrong Named result %q, %t", got, ok)
+	}
+	if _, ok := md.Named("middle"); ok {
+		t.Errorf("nonexistent group should not be found")
+	}
+	if got, ok := md.Get(-1); !ok || got != "Smith" {
+		t.Errorf("wrong Get(-1) result %q, %t", got, ok)
+	}
+	if got, want := []int{md.Begin(0), md.End(0), md.Begin(2)}, []int{5, 15, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong offsets %v; want %v", got, want)
+	}
+}
+
+func TestMatchDataOffsets(t *testing.T) {
+	md := MustNew(`w(ö)(x)?`, "").Match("héllo wörld")
+	if md == nil {
+		t.Fatal("no match")
+	}
+	got := []int{md.Begin(0), md.End(0), md.Begin(1), md.End(1), md.Begin(2), md.End(2)}
+	if want := []int{6, 8, 7, 8, -1, -1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong offsets %v; want %v", got, want)
+	}
+	if got, want := md.Match().Bounds().Start, 7; got != want {
+		t.Errorf("wrong byte offset %d; want %d", got, want)
+	}
+}
+
+func TestValuesAt(t *testing.T) {
+	md := MustNew(`(a)(b)?(c)`, "").Match("ac")
+	got := md.ValuesAt(0, 1, 2, -1, 4, -5)
+	if want := strs("ac", "a", nil, "c", nil, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong values %v; want %v", got, want)
+	}
+	if got, want := md.Size(), 4; got != want {
+		t.Errorf("wrong Size %d; want %d", got, want)
+	}
+}
+
+func TestNamedOnly(t *testing.T) {
+	// As in Ruby, plain groups do not capture when there are named groups.
+	md := MustNew(`(a)(?<n>b)`, "").Match("ab")
+	if got, want := md.ToA(), strs("ab", "b"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong ToA %v; want %v", got, want)
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	re := MustNew(`(?<a>x)|(?<a>y)`, "")
+	for _, s := range []string{"x", "y"} {
+		md := re.Match(s)
+		if got, ok := md.Named("a"); !ok || got != s {
+			t.Errorf("wrong Named result for %q: %q, %t", s, got, ok)
+		}
+		if got, want := md.NamedCaptures(), map[string]*string{"a": strs(s)[0]}; !reflect.DeepEqual(got, want) {
+			t.Errorf("wrong NamedCaptures for %q: %v; want %v", s, got, want)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	tests := []struct {
+		Source, Options string
+		Str             string
+		Want            int
+	}{
+		{`l`, ``, "héllo", 2},
+		{`z`, ``, "héllo", -1},
+		{`a.b`, `mi`, "xA\nB", 1},
+		{`a.b`, ``, "xa\nb", -1},
+		{`a b`, `x`, "xab", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("/%s/%s =~ %q", test.Source, test.Options, test.Str), func(t *testing.T) {
+			re := MustNew(test.Source, test.Options)
+			if got := re.Index(test.Str); got != test.Want {
+				t.Errorf("wrong index %d; want %d", got, test.Want)
+			}
+			if got, want := re.MatchP(test.Str), test.Want >= 0; got != want {
+				t.Errorf("wrong MatchP result %t; want %t", got, want)
+			}
+		})
+	}
+}
+
+func TestMatchFrom(t *testing.T) {
+	tests := []struct {
+		Source string
+		Pos    int
+		Want   int // -1 if there is no match
+	}{
+		{`\Ga`, 2, 2},
+		{`\Ga`, -1, 2},
+		{`\Ga`, 1, -1},
+		{`\Aa`, 2, -1},
+		{`(?<=é)b`, 1, 1},
+		{`$`, 3, 3},
+		{`a`, 4, -1},
+		{`a`, -4, -1},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("/%s/ at %d", test.Source, test.Pos), func(t *testing.T) {
+			md := MustNew(test.Source, "").MatchFrom("éba", test.Pos)
+			got := -1
+			if md != nil {
+				got = md.Begin(0)
+			}
+			if got != test.Want {
+				t.Errorf("wrong result %d; want %d", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		Source string
+		Str    string
+		Want   [][]*string
+	}{
+		{`\d+`, "a1b22", [][]*string{strs("1"), strs("22")}},
+		{`(\w)=(\d)?`, "a=1,b=", [][]*string{strs("a", "1"), strs("b", nil)}},
+		{`x*`, "axb", [][]*string{strs(""), strs("x"), strs(""), strs("")}},
+		{`z`, "axb", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("/%s/ in %q", test.Source, test.Str), func(t *testing.T) {
+			got := MustNew(test.Source, "").Scan(test.Str)
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf("wrong result %v; want %v", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestGsub(t *testing.T) {
+	tests := []struct {
+		Source      string
+		Str         string
+		Replacement string
+		Want        string
+	}{
+		{`l`, "hello", `L`, "heLLo"},
+		{`(\w+) (\w+)`, "John Smith", `\2, \1`, "Smith, John"},
+		{`(?<f>\w+) (?<l>\w+)`, "John Smith", `\k<l> \k<f>`, "Smith John"},
+		{`b`, "abc", "\\`|\\&|\\'|\\0|\\\\|\\q|\\5|\\k|\\", `aa|b|c|b|\|\q||\k|\c`},
+		{`x*`, "abxd", `-`, "-a-b--d-"},
+		{`(a)|(b)`, "ab", `[\1\2]`, "[a][b]"},
+		{`z`, "ab", `-`, "ab"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("/%s/ with %q in %q", test.Source, test.Replacement, test.Str), func(t *testing.T) {
+			got, err := MustNew(test.Source, "").Gsub(test.Str, test.Replacement)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.Want {
+				t.Errorf("wrong result %q; want %q", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestSub(t *testing.T) {
+	re := MustNew(`o`, "")
+	if got, err := re.Sub("foo", `0`); err != nil || got != "f0o" {
+		t.Errorf("wrong Sub result %q, %v", got, err)
+	}
+	if got := re.SubFunc("foo", func(md *MatchData) string { return md.PreMatch() }); got != "ffo" {
+		t.Errorf("wrong SubFunc result %q", got)
+	}
+}
+
+func TestGsubErrors(t *testing.T) {
+	tests := []struct {
+		Source      string
+		Replacement string
+		Want        string
+	}{
+		{`(?<n>a)`, `\k<z>`, `undefined group name reference: z`},
+		{`(a)`, `\k<z>`, `undefined group name reference: z`},
+		{`(?<n>a)`, `\k<n`, `invalid group name reference format`},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("/%s/ with %q", test.Source, test.Replacement), func(t *testing.T) {
+			_, err := MustNew(test.Source, "").Gsub("a", test.Replacement)
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if err.Error() != test.Want {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", err, test.Want)
+			}
+		})
+	}
+}
+
+func TestGsubFunc(t *testing.T) {
+	re := MustNew(`\d+`, "")
+	got := re.GsubFunc("é1b22", func(md *MatchData) string {
+		return fmt.Sprintf("<%d>", md.Begin(0))
+	})
+	if want := "é<1>b<3>"; got != want {
+		t.Errorf("wrong GsubFunc result %q; want %q", got, want)
+	}
+
+	hash := map[string]string{"cat": "dog"}
+	if got, want := MustNew(`[ch]at`, "").GsubHash("cat hat", hash), "dog "; got != want {
+		t.Errorf("wrong GsubHash result %q; want %q", got, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New(`a`, "q"); err == nil {
+		t.Errorf("unexpected success with invalid options")
+	}
+	if _, err := New(`(`, ""); err == nil {
+		t.Errorf("unexpected success with invalid pattern")
+	}
+}
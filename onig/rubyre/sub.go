@@ -0,0 +1,156 @@
+package rubyre
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apparentlymart/go-onig/onig"
+)
+
+// Scan returns the text of each successive match of the regular expression
+// in the given string, as Ruby's String#scan does. Each result is the whole
+// match if the regular expression has no groups, or otherwise the text of
+// each group, with nil for groups that did not participate.
+func (r *Regexp) Scan(s string) [][]*string {
+	var ret [][]*string
+	r.ScanFunc(s, func(md *MatchData) {
+		if md.Size() == 1 {
+			ret = append(ret, md.ToA())
+		} else {
+			ret = append(ret, md.Captures())
+		}
+	})
+	return ret
+}
+
+// ScanFunc calls fn with each successive match of the regular expression
+// in the given string, as the block form of Ruby's String#scan does.
+func (r *Regexp) ScanFunc(s string, fn func(md *MatchData)) {
+	for _, m := range r.re.SearchAll(s, onig.NoMatchOpts) {
+		fn(r.newMatchData(s, m))
+	}
+}
+
+// Sub returns a copy of the given string with the first match of the
+// regular expression replaced using the given replacement, as Ruby's
+// String#sub does.
+//
+// In the replacement, "\0" and "\&" stand for the whole match, "\1" to "\9"
+// for the numbered groups, "\k<name>" for the named group, "\`" for the text
+// before the match, "\'" for the text after it, and "\\" for a backslash.
+// An error is returned if the replacement refers to a group name that is
+// not in the regular expression.
+func (r *Regexp) Sub(s, replacement string) (string, error) {
+	return r.sub(s, replacement, false)
+}
+
+// Gsub is like Sub but replaces every match, as Ruby's String#gsub does.
+func (r *Regexp) Gsub(s, replacement string) (string, error) {
+	return r.sub(s, replacement, true)
+}
+
+func (r *Regexp) sub(s, replacement string, global bool) (string, error) {
+	var err error
+	ret := r.replace(s, global, func(md *MatchData) string {
+		text, expandErr := md.expand(replacement)
+		if expandErr != nil && err == nil {
+			err = expandErr
+		}
+		return text
+	})
+	if err != nil {
+		return "", err
+	}
+	return ret, nil
+}
+
+// SubFunc is like Sub but replaces the match with the result of calling fn
+// with it, as the block form of Ruby's String#sub does.
+func (r *Regexp) SubFunc(s string, fn func(md *MatchData) string) string {
+	return r.replace(s, false, fn)
+}
+
+// GsubFunc is like Gsub but replaces each match with the result of calling
+// fn with it, as the block form of Ruby's String#gsub does.
+func (r *Regexp) GsubFunc(s string, fn func(md *MatchData) string) string {
+	return r.replace(s, true, fn)
+}
+
+// GsubHash is like Gsub but replaces each match with the value that the
+// given map has for the matched text, or with nothing if it has none, as
+// Ruby's String#gsub does when given a hash.
+func (r *Regexp) GsubHash(s string, hash map[string]string) string {
+	return r.replace(s, true, func(md *MatchData) string {
+		return hash[md.String()]
+	})
+}
+
+func (r *Regexp) replace(s string, global bool, fn func(md *MatchData) string) string {
+	var matches []*onig.Match
+	if global {
+		matches = r.re.SearchAll(s, onig.NoMatchOpts)
+	} else if m := r.re.Search(s, onig.NoMatchOpts); m != nil {
+		matches = []*onig.Match{m}
+	}
+	if matches == nil {
+		return s
+	}
+
+	var buf strings.Builder
+	last := 0
+	for _, m := range matches {
+		bounds := m.Bounds()
+		buf.WriteString(s[last:bounds.Start])
+		buf.WriteString(fn(r.newMatchData(s, m)))
+		last = bounds.End
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+// expand returns the given replacement with its backslash sequences
+// substituted for the receiver, as described for Sub.
+func (md *MatchData) expand(replacement string) (string, error) {
+	var buf strings.Builder
+	for {
+		before, after, ok := strings.Cut(replacement, `\`)
+		if !ok || after == "" {
+			break
+		}
+		buf.WriteString(before)
+		replacement = after[1:]
+
+		switch c := after[0]; {
+		case c >= '0' && c <= '9':
+			if text, ok := md.Get(int(c - '0')); ok {
+				buf.WriteString(text)
+			}
+		case c == '&':
+			buf.WriteString(md.String())
+		case c == '`':
+			buf.WriteString(md.PreMatch())
+		case c == '\'':
+			buf.WriteString(md.PostMatch())
+		case c == '\\':
+			buf.WriteByte('\\')
+		case c == 'k' && strings.HasPrefix(replacement, "<"):
+			end := strings.IndexByte(replacement, '>')
+			if end < 0 {
+				return "", fmt.Errorf("invalid group name reference format")
+			}
+			name := replacement[1:end]
+			if _, exists := md.regexp.re.NamedCapturesFirst()[name]; !exists {
+				return "", fmt.Errorf("undefined group name reference: %s", name)
+			}
+			if text, ok := md.Named(name); ok {
+				buf.WriteString(text)
+			}
+			replacement = replacement[end+1:]
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteString(replacement)
+	return buf.String(), nil
+}
@@ -0,0 +1,220 @@
+package onig
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Scanner matches regular expressions at a position cursor that moves
+// forward through a string or byte slice, in the style of Ruby's
+// StringScanner, for writing hand-written parsers.
+//
+// Each match is anchored at the cursor, but the text before the cursor is
+// still visible to the pattern: "\G" matches at the cursor, while "\A" and
+// "^" and lookbehind assertions see the whole input, as with MatchAt.
+//
+// The methods that return text return strings even for a Scanner created
+// with NewScannerBytes, copying the text from the byte slice.
+//
+// A Scanner is not safe for concurrent use by multiple goroutines.
+type Scanner struct {
+	s       string
+	b       []byte
+	isBytes bool
+	len     int
+
+	pos int
+
+	// prev is the cursor position before the last successful match, for
+	// Unscan, and m is that match, or nil if the last match attempt failed
+	// or there has been none.
+	prev int
+	m    *Match
+}
+
+// NewScanner returns a Scanner over the given string, with its cursor at
+// the start.
+func NewScanner(s string) *Scanner {
+	return &Scanner{s: s, len: len(s)}
+}
+
+// NewScannerBytes returns a Scanner over the given byte slice, with its
+// cursor at the start. The slice must not be modified while the Scanner is
+// in use.
+func NewScannerBytes(b []byte) *Scanner {
+	return &Scanner{b: b, isBytes: true, len: len(b)}
+}
+
+// Pos returns the byte offset of the cursor.
+func (s *Scanner) Pos() int {
+	return s.pos
+}
+
+// SetPos moves the cursor to the given byte offset. It panics if the
+// offset is negative or greater than the length of the input.
+func (s *Scanner) SetPos(pos int) {
+	if pos < 0 || pos > s.len {
+		panic(fmt.Sprintf("onig: position %d out of range [0:%d]", pos, s.len))
+	}
+	s.pos = pos
+}
+
+// EOS returns true if the cursor is at the end of the input.
+func (s *Scanner) EOS() bool {
+	return s.pos == s.len
+}
+
+// Rest returns the text from the cursor to the end of the input.
+func (s *Scanner) Rest() string {
+	return s.text(s.pos, s.len)
+}
+
+// Reset moves the cursor to the start of the input and forgets the last
+// match.
+func (s *Scanner) Reset() {
+	s.pos = 0
+	s.m = nil
+}
+
+// Terminate moves the cursor to the end of the input and forgets the last
+// match.
+func (s *Scanner) Terminate() {
+	s.pos = s.len
+	s.m = nil
+}
+
+// Scan tries to match the given regex at the cursor. If it matches, Scan
+// advances the cursor past the match and returns the matched text and true.
+// Otherwise it returns false and leaves the cursor unchanged.
+func (s *Scanner) Scan(re *Regex) (string, bool) {
+	m := s.try(re, false, true)
+	if m == nil {
+		return "", false
+	}
+	return s.text(m.Bounds().Start, m.Bounds().End), true
+}
+
+// Check is like Scan but does not advance the cursor.
+func (s *Scanner) Check(re *Regex) (string, bool) {
+	m := s.try(re, false, false)
+	if m == nil {
+		return "", false
+	}
+	return s.text(m.Bounds().Start, m.Bounds().End), true
+}
+
+// Skip is like Scan but returns the length of the match in bytes, rather
+// than its text.
+func (s *Scanner) Skip(re *Regex) (int, bool) {
+	m := s.try(re, false, true)
+	if m == nil {
+		return 0, false
+	}
+	return m.Bounds().Len(), true
+}
+
+// ScanUntil searches for the given regex from the cursor onwards. If it
+// finds a match, ScanUntil advances the cursor past the match and returns
+// the text from the old cursor position to the end of the match, and true.
+// Otherwise it returns false and leaves the cursor unchanged.
+func (s *Scanner) ScanUntil(re *Regex) (string, bool) {
+	start := s.pos
+	m := s.try(re, true, true)
+	if m == nil {
+		return "", false
+	}
+	return s.text(start, m.Bounds().End), true
+}
+
+// SkipUntil is like ScanUntil but returns the number of bytes the cursor
+// advanced, rather than the text.
+func (s *Scanner) SkipUntil(re *Regex) (int, bool) {
+	start := s.pos
+	m := s.try(re, true, true)
+	if m == nil {
+		return 0, false
+	}
+	return m.Bounds().End - start, true
+}
+
+// try matches or searches for re at the cursor, records the result as the
+// last match, and advances the cursor past the match if advance is true.
+func (s *Scanner) try(re *Regex, search, advance bool) *Match {
+	m := NewMatch()
+	var ok bool
+	switch {
+	case search && s.isBytes:
+		ok = re.SearchBytesFromInto(m, s.b, s.pos, NoMatchOpts)
+	case search:
+		ok = re.SearchFromInto(m, s.s, s.pos, NoMatchOpts)
+	case s.isBytes:
+		ok = re.MatchBytesAtInto(m, s.b, s.pos, NoMatchOpts)
+	default:
+		ok = re.MatchAtInto(m, s.s, s.pos, NoMatchOpts)
+	}
+	if !ok {
+		s.m = nil
+		return nil
+	}
+	s.m = m
+	s.prev = s.pos
+	if advance {
+		s.pos = m.Bounds().End
+	}
+	return m
+}
+
+// Unscan moves the cursor back to where it was before the last successful
+// match. It returns an error if the last match attempt failed or there has
+// been none.
+func (s *Scanner) Unscan() error {
+	if s.m == nil {
+		return errors.New("no match to unscan")
+	}
+	s.pos = s.prev
+	s.m = nil
+	return nil
+}
+
+// Match returns the last match, or nil if the last match attempt failed or
+// there has been none. Its spans are byte offsets into the whole input.
+func (s *Scanner) Match() *Match {
+	return s.m
+}
+
+// Matched returns the text of the last match, or false if the last match
+// attempt failed or there has been none. For ScanUntil and SkipUntil this
+// is only the text that the regex matched.
+func (s *Scanner) Matched() (string, bool) {
+	if s.m == nil {
+		return "", false
+	}
+	return s.text(s.m.Bounds().Start, s.m.Bounds().End), true
+}
+
+// PreMatch returns the text from the start of the input to the start of the
+// last match, or false if the last match attempt failed or there has been
+// none.
+func (s *Scanner) PreMatch() (string, bool) {
+	if s.m == nil {
+		return "", false
+	}
+	return s.text(0, s.m.Bounds().Start), true
+}
+
+// PostMatch returns the text from the end of the last match to the end of
+// the input, or false if the last match attempt failed or there has been
+// none.
+func (s *Scanner) PostMatch() (string, bool) {
+	if s.m == nil {
+		return "", false
+	}
+	return s.text(s.m.Bounds().End, s.len), true
+}
+
+func (s *Scanner) text(start, end int) string {
+	if s.isBytes {
+		return string(s.b[start:end])
+	}
+	return s.s[start:end]
+}
@@ -0,0 +1,91 @@
+package onig
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	word := MustNewRegex(`\w+`, NoCompileOpts, SyntaxRuby)
+	space := MustNewRegex(`\s+`, NoCompileOpts, SyntaxRuby)
+	r := MustNewRegex(`r`, NoCompileOpts, SyntaxRuby)
+
+	for _, s := range []*Scanner{NewScanner("test string"), NewScannerBytes([]byte("test string"))} {
+		var got []any
+		record := func(results ...any) {
+			got = append(got, results...)
+		}
+		text := func(f func() (string, bool)) string {
+			if s, ok := f(); ok {
+				return s
+			}
+			return "<none>"
+		}
+
+		record(s.Scan(word))
+		record(s.Scan(word))
+		record(text(s.Matched), s.Pos())
+		record(s.Check(space))
+		record(s.Pos())
+		record(s.Skip(space))
+		record(text(s.PreMatch), text(s.PostMatch))
+		record(s.ScanUntil(r))
+		record(text(s.Matched), text(s.PreMatch), s.Pos())
+		record(s.Unscan(), s.Pos())
+		record(s.Unscan() != nil)
+		record(s.SkipUntil(r))
+		record(s.Rest(), s.EOS())
+		record(s.SkipUntil(r))
+		s.Terminate()
+		record(s.Rest(), s.EOS())
+
+		want := []any{
+			"test", true,
+			"", false,
+			"<none>", 4,
+			" ", true,
+			4,
+			1, true,
+			"test", "string",
+			"str", true,
+			"r", "test st", 8,
+			nil, 5,
+			true,
+			3, true,
+			"ing", false,
+			0, false,
+			"", true,
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("wrong results\ngot:  %v\nwant: %v", got, want)
+		}
+	}
+}
+
+func TestScannerAnchors(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Want    string // "<none>" if there is no match
+	}{
+		{`(?<=a)b`, "b"},
+		{`(?<!a)b`, "<none>"},
+		{`\Gb`, "b"},
+		{`\Ab`, "<none>"},
+		{`^b`, "<none>"},
+		{`c`, "<none>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Pattern, func(t *testing.T) {
+			s := NewScanner("abc")
+			s.SetPos(1)
+			got, ok := s.Scan(MustNewRegex(test.Pattern, NoCompileOpts, SyntaxRuby))
+			if !ok {
+				got = "<none>"
+			}
+			if got != test.Want {
+				t.Errorf("wrong result %q; want %q", got, test.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package onig
+
+import (
+	"bufio"
+	"unicode/utf8"
+)
+
+// SplitLookahead is the number of bytes of input that the split functions
+// returned by SplitFunc and TokenFunc require beyond the end of a match
+// before they accept it, so that lookahead assertions and greedy repetition
+// at the end of the match see the same text as they would in the whole
+// input. A pattern that examines more text than this beyond its match can
+// split differently than it would on the whole input.
+const SplitLookahead = 256
+
+// SplitFunc returns a split function for a bufio.Scanner that produces the
+// text between the matches of the given regex, which act as separators.
+// The text after the last separator is the final token, unless it is
+// empty. Empty matches of the regex are ignored.
+//
+// The split function must decide where a separator is before it has seen
+// all of the input, so it requests more data until SplitLookahead bytes
+// are buffered beyond the end of the first separator in the buffered data,
+// and "$" and "\z" do not match at the end of the buffered data until the
+// end of the input. A pattern that could match earlier in the input given
+// text far beyond the first separator, such as "a.*z|,", can therefore
+// split differently than it would on the whole input.
+//
+// Each search begins at the start of a token, so "^", "\A" and lookbehind
+// assertions cannot see the text of earlier tokens.
+func SplitFunc(re *Regex) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if span, ok := splitSearch(re, data, atEOF); ok {
+			return span.End, data[:span.Start], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// TokenFunc returns a split function for a bufio.Scanner that produces
+// each match of the given regex as a token, discarding the text between
+// them. Empty matches of the regex are ignored.
+//
+// The split function requests more data in the same situations as one
+// returned by SplitFunc. The text that precedes a match remains in the
+// Scanner's buffer until the match is found, so it must fit within the
+// Scanner's maximum token size.
+func TokenFunc(re *Regex) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if span, ok := splitSearch(re, data, atEOF); ok {
+			return span.End, span.Slice(data), nil
+		}
+		if atEOF {
+			return len(data), nil, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// splitSearch finds the first non-empty match of re in data, for SplitFunc
+// and TokenFunc. Unless atEOF is true, a match that ends less than
+// SplitLookahead bytes before the end of data, or of its last complete
+// UTF-8 sequence, is not returned, since more data could change it.
+func splitSearch(re *Regex, data []byte, atEOF bool) (Span, bool) {
+	opts := NoMatchOpts
+	if !atEOF {
+		opts = OptNotEOL
+		data = data[:completeLen(data)]
+	}
+	m := NewMatch()
+	for pos := 0; pos <= len(data); {
+		if !re.SearchBytesFromInto(m, data, pos, opts) {
+			return Span{}, false
+		}
+		span := m.Bounds()
+		if span.Len() > 0 {
+			return span, atEOF || span.End+SplitLookahead <= len(data)
+		}
+		pos = scanNext(data, span)
+	}
+	return Span{}, false
+}
+
+// completeLen returns the length of data without any incomplete UTF-8
+// sequence at its end.
+func completeLen(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
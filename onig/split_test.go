@@ -0,0 +1,76 @@
+package onig
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSplitFunc(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Split   func(re *Regex) bufio.SplitFunc
+		Input   string
+		Want    []string
+	}{
+		{
+			`\r?\n(?=\d{4}-)`, SplitFunc,
+			"2024-01 a\ncont\r\n2024-02 b\n2024-03 c\n",
+			[]string{"2024-01 a\ncont", "2024-02 b", "2024-03 c\n"},
+		},
+		{`,+`, SplitFunc, "a,,b,c", []string{"a", "b", "c"}},
+		{`,`, SplitFunc, ",a,,b,", []string{"", "a", "", "b"}},
+		{`,*`, SplitFunc, "ab,c", []string{"ab", "c"}},
+		{`x$`, SplitFunc, "axbx", []string{"axb"}},
+		{`\d+`, TokenFunc, "a12 b345c6", []string{"12", "345", "6"}},
+		{`\d*`, TokenFunc, "a1b22", []string{"1", "22"}},
+		{`é+`, TokenFunc, "aééb", []string{"éé"}},
+		{`\w+(?=;)`, TokenFunc, "ab;cd", []string{"ab"}},
+		{`z`, TokenFunc, "abc", nil},
+		{`\n(?!\d{4})`, SplitFunc, "a\n12345\nb", []string{"a\n12345", "b"}},
+		{
+			`\n(?!\d{4})`, SplitFunc,
+			strings.Repeat("a\n12345\n", 100) + "b",
+			strings.Split(strings.Repeat("a\n12345,", 100)+"b", ","),
+		},
+	}
+
+	for _, test := range tests {
+		for name, reader := range testReaders {
+			t.Run(test.Pattern+" "+name, func(t *testing.T) {
+				sc := bufio.NewScanner(reader(test.Input))
+				sc.Split(test.Split(MustNewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)))
+				var got []string
+				for sc.Scan() {
+					got = append(got, sc.Text())
+				}
+				if err := sc.Err(); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, test.Want) {
+					t.Errorf("wrong tokens %q; want %q", got, test.Want)
+				}
+			})
+		}
+	}
+}
+
+// testReaders gives the ways in which tests of functions that read streams
+// deliver their input, so that reads end at many different positions.
+var testReaders = map[string]func(s string) io.Reader{
+	"whole": func(s string) io.Reader {
+		return strings.NewReader(s)
+	},
+	"OneByteReader": func(s string) io.Reader {
+		return iotest.OneByteReader(strings.NewReader(s))
+	},
+	"HalfReader": func(s string) io.Reader {
+		return iotest.HalfReader(strings.NewReader(s))
+	},
+	"DataErrReader": func(s string) io.Reader {
+		return iotest.DataErrReader(iotest.OneByteReader(strings.NewReader(s)))
+	},
+}
@@ -0,0 +1,251 @@
+package onig
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Default values for the fields of StreamWindow.
+const (
+	DefaultMaxMatchLen = 64 * 1024
+	DefaultLookbehind  = 256
+)
+
+// StreamWindow configures the sliding window used to search a stream by
+// methods such as Regex.SearchReader, which keep only part of the stream in
+// memory at once.
+type StreamWindow struct {
+	// MaxMatchLen is the maximum length in bytes of a match, which must
+	// also bound any text examined by lookahead assertions beyond the
+	// match. If zero, DefaultMaxMatchLen is used.
+	//
+	// A longer match causes a *StreamWindowError. Oniguruma cannot report
+	// that a search stopped at the end of the buffer in the middle of a
+	// possible match, so unless SkipAhead is set, the error is also
+	// returned when more than MaxMatchLen bytes follow a position without
+	// a match having been found, since a match beginning there would be too
+	// long. The gaps between matches must then fit within the window.
+	MaxMatchLen int
+
+	// SkipAhead allows the text in which no match has been found to be
+	// discarded once more than MaxMatchLen bytes after it are buffered, so
+	// that a search can go on through long stretches without a match. A
+	// match longer than MaxMatchLen that begins in the discarded text is
+	// then missed without an error, as is "x.*y" in "x" followed by
+	// MaxMatchLen bytes of "z" and then "y".
+	SkipAhead bool
+
+	// Lookbehind is the number of bytes before each search position that
+	// remain visible to lookbehind assertions and to anchors like "\b" and
+	// "^". If zero, DefaultLookbehind is used.
+	Lookbehind int
+}
+
+func (w StreamWindow) withDefaults() StreamWindow {
+	if w.MaxMatchLen <= 0 {
+		w.MaxMatchLen = DefaultMaxMatchLen
+	}
+	if w.Lookbehind <= 0 {
+		w.Lookbehind = DefaultLookbehind
+	}
+	return w
+}
+
+// StreamWindowError is the error returned when searching a stream finds a
+// match longer than the window allows, or cannot rule one out, as described
+// for StreamWindow.MaxMatchLen.
+type StreamWindowError struct {
+	// Offset is the offset in the stream of the start of the match, or of
+	// the first position at which a match could not be ruled out.
+	Offset int
+
+	MaxMatchLen int
+
+	// Unfinished is true if no match was found, but one could begin at
+	// Offset and end beyond the window.
+	Unfinished bool
+}
+
+func (e *StreamWindowError) Error() string {
+	if e.Unfinished {
+		return fmt.Sprintf("no match found within %d bytes of offset %d, so a match beginning there would exceed the maximum match length", e.MaxMatchLen, e.Offset)
+	}
+	return fmt.Sprintf("match at offset %d exceeds the maximum match length of %d bytes", e.Offset, e.MaxMatchLen)
+}
+
+// StreamMatch is a match found in a stream. Unlike a Match, it holds a copy
+// of the matched text, since the stream cannot be read again.
+type StreamMatch struct {
+	// Spans gives the bounds of the whole match followed by each capture,
+	// as offsets from the start of the stream, with Span{-1, -1} for
+	// captures that did not participate in the match.
+	Spans []Span
+
+	// Groups gives the text of each span in Spans, or nil for captures that
+	// did not participate in the match.
+	Groups [][]byte
+}
+
+// Bounds returns a span describing the whole match.
+func (m *StreamMatch) Bounds() Span {
+	return m.Spans[0]
+}
+
+// SearchReader finds the first match of the receiver in the text read from
+// the given reader, which it reads only as far as necessary, keeping only
+// part of the stream in memory as configured by window.
+//
+// The result is nil if the reader reaches EOF without a match. An error is
+// returned if reading fails or a match is too long for the window, or
+// might be as described for StreamWindow.MaxMatchLen.
+func (r *Regex) SearchReader(rd io.Reader, opts MatchOptions, window StreamWindow) (*StreamMatch, error) {
+	m, err := r.SearchAllReader(rd, opts, window).Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return m, err
+}
+
+// SearchAllReader returns a StreamMatches that finds the successive,
+// non-overlapping matches of the receiver in the text read from the given
+// reader, in the same way as SearchAll, keeping only part of the stream in
+// memory as configured by window.
+func (r *Regex) SearchAllReader(rd io.Reader, opts MatchOptions, window StreamWindow) *StreamMatches {
+	return &StreamMatches{
+		re:     r,
+		rd:     rd,
+		opts:   opts,
+		window: window.withDefaults(),
+		m:      NewMatch(),
+	}
+}
+
+// StreamMatches finds the matches of a regex in a stream one at a time,
+// for Regex.SearchAllReader.
+//
+// A StreamMatches is not safe for concurrent use by multiple goroutines.
+type StreamMatches struct {
+	re     *Regex
+	rd     io.Reader
+	opts   MatchOptions
+	window StreamWindow
+	m      *Match
+
+	// buf holds the part of the stream that begins at offset base, and pos
+	// is the offset at which to search for the next match.
+	buf       []byte
+	base, pos int
+	eof       bool
+
+	err error
+}
+
+// Next returns the next match. When there are no more matches it returns
+// io.EOF, and if reading fails or a match is too long for the window, or
+// might be as described for StreamWindow.MaxMatchLen, it returns that
+// error. After any error, it returns the same error again.
+func (s *StreamMatches) Next() (*StreamMatch, error) {
+	for s.err == nil {
+		if m, ok := s.search(); ok {
+			return m, nil
+		}
+		if s.err == nil {
+			s.fill()
+		}
+	}
+	return nil, s.err
+}
+
+// search looks for the next match in the buffered data. It returns false
+// if more data is needed, or if it has recorded an error.
+func (s *StreamMatches) search() (*StreamMatch, bool) {
+	data := s.buf
+	opts := s.opts
+	if !s.eof {
+		// Until the end of the stream, the end of the buffer is not the
+		// end of the text, and the last character might be incomplete.
+		data = data[:completeLen(data)]
+		opts |= OptNotEOL
+	}
+	if s.base > 0 {
+		opts |= OptNotBOL
+	}
+	start := s.pos - s.base
+	maxLen := s.window.MaxMatchLen
+
+	if start > len(data) || !s.re.SearchBytesFromInto(s.m, data, start, opts) {
+		if s.eof {
+			s.err = io.EOF
+			return nil, false
+		}
+		// No match that fits the window can begin where the window after
+		// it was fully buffered, so the search can resume after those
+		// positions if longer matches may be missed.
+		if skip := len(data) - maxLen; skip > start {
+			if !s.window.SkipAhead {
+				s.err = &StreamWindowError{Offset: s.pos, MaxMatchLen: maxLen, Unfinished: true}
+				return nil, false
+			}
+			for skip > start && !utf8.RuneStart(data[skip]) {
+				skip--
+			}
+			s.pos = s.base + skip
+		}
+		return nil, false
+	}
+
+	span := s.m.Bounds()
+	if span.Len() > maxLen {
+		s.err = &StreamWindowError{Offset: s.base + span.Start, MaxMatchLen: maxLen}
+		return nil, false
+	}
+	if !s.eof && span.Start+maxLen >= len(data) {
+		// The match might have been different with more data.
+		return nil, false
+	}
+
+	ret := &StreamMatch{
+		Spans:  make([]Span, s.m.CaptureCount()+1),
+		Groups: make([][]byte, s.m.CaptureCount()+1),
+	}
+	for i := range ret.Spans {
+		span := s.m.Capture(i)
+		if span.Start < 0 {
+			ret.Spans[i] = span
+			continue
+		}
+		ret.Spans[i] = Span{s.base + span.Start, s.base + span.End}
+		ret.Groups[i] = append([]byte{}, span.Slice(data)...)
+	}
+	s.pos = s.base + scanNext(data, span)
+	return ret, true
+}
+
+// fill discards the buffered data that is no longer needed and then reads
+// more from the stream.
+func (s *StreamMatches) fill() {
+	keep := max(0, s.pos-s.base-s.window.Lookbehind)
+	for keep > 0 && keep < len(s.buf) && !utf8.RuneStart(s.buf[keep]) {
+		keep--
+	}
+	keep = min(keep, len(s.buf))
+	n := copy(s.buf, s.buf[keep:])
+	s.buf = s.buf[:n]
+	s.base += keep
+
+	chunk := max(s.window.MaxMatchLen, 4096)
+	if cap(s.buf)-len(s.buf) < chunk {
+		grown := make([]byte, len(s.buf), len(s.buf)+chunk)
+		copy(grown, s.buf)
+		s.buf = grown
+	}
+	n, err := s.rd.Read(s.buf[len(s.buf) : len(s.buf)+chunk])
+	s.buf = s.buf[:len(s.buf)+n]
+	switch {
+	case err == io.EOF:
+		s.eof = true
+	case err != nil:
+		s.err = err
+	}
+}
@@ -0,0 +1,142 @@
+package onig
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSearchAllReader(t *testing.T) {
+	input := streamTestInput()
+
+	patterns := []string{
+		`\bfoo\b`,
+		`(?<=a)b`,
+		`\d+`,
+		`(x)|(é+)`,
+		`^bar`,
+		`foo$`,
+		`x*`,
+		`(?<=foo\s)bar(?=\s)`,
+	}
+	for _, pattern := range patterns {
+		re := MustNewRegex(pattern, NoCompileOpts, SyntaxRuby)
+		var want []Span
+		for _, m := range re.SearchAll(input, NoMatchOpts) {
+			want = append(want, m.Bounds())
+		}
+
+		for name, reader := range testReaders {
+			t.Run(pattern+" "+name, func(t *testing.T) {
+				it := re.SearchAllReader(reader(input), NoMatchOpts, StreamWindow{MaxMatchLen: 16, Lookbehind: 8, SkipAhead: true})
+				var got []Span
+				for {
+					m, err := it.Next()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					if text := string(m.Groups[0]); text != m.Bounds().Substr(input) {
+						t.Fatalf("wrong text %q at %#v", text, m.Bounds())
+					}
+					got = append(got, m.Bounds())
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("wrong matches\ngot:  %v\nwant: %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestSearchReader(t *testing.T) {
+	re := MustNewRegex(`(\d+)(x)?`, NoCompileOpts, SyntaxRuby)
+	m, err := re.SearchReader(strings.NewReader("ab 12 c"), NoMatchOpts, StreamWindow{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &StreamMatch{
+		Spans:  []Span{{3, 5}, {3, 5}, {-1, -1}},
+		Groups: [][]byte{[]byte("12"), []byte("12"), nil},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("wrong match\ngot:  %#v\nwant: %#v", m, want)
+	}
+
+	m, err = re.SearchReader(strings.NewReader("abc"), NoMatchOpts, StreamWindow{})
+	if m != nil || err != nil {
+		t.Errorf("wrong result without a match: %#v, %v", m, err)
+	}
+}
+
+func TestSearchReaderErrors(t *testing.T) {
+	re := MustNewRegex(`a+`, NoCompileOpts, SyntaxRuby)
+	input := "xx" + strings.Repeat("a", 100)
+	_, err := re.SearchReader(iotest.OneByteReader(strings.NewReader(input)), NoMatchOpts, StreamWindow{MaxMatchLen: 10})
+	var windowErr *StreamWindowError
+	if !errors.As(err, &windowErr) || *windowErr != (StreamWindowError{Offset: 2, MaxMatchLen: 10}) {
+		t.Errorf("wrong error %#v", err)
+	}
+	if got, want := err.Error(), "match at offset 2 exceeds the maximum match length of 10 bytes"; got != want {
+		t.Errorf("wrong error message %q; want %q", got, want)
+	}
+
+	_, err = re.SearchReader(iotest.TimeoutReader(strings.NewReader("xyz"+input)), NoMatchOpts, StreamWindow{MaxMatchLen: 1000})
+	if err != iotest.ErrTimeout {
+		t.Errorf("wrong error %#v; want ErrTimeout", err)
+	}
+}
+
+func TestSearchReaderLongMatch(t *testing.T) {
+	// The match of "x.*y" at the start is longer than the window, but it is
+	// not found while all of it is buffered, so the search can only report
+	// that a match beginning there might be too long.
+	re := MustNewRegex(`x.*y`, NoCompileOpts, SyntaxRuby)
+	long := "x" + strings.Repeat("z", 10000) + "y"
+	input := long + "\nxzy"
+	if got, want := re.Search(input, NoMatchOpts).Bounds(), (Span{0, len(long)}); got != want {
+		t.Fatalf("wrong match from Search %#v; want %#v", got, want)
+	}
+
+	_, err := re.SearchReader(strings.NewReader(input), NoMatchOpts, StreamWindow{MaxMatchLen: 16})
+	var windowErr *StreamWindowError
+	if !errors.As(err, &windowErr) || *windowErr != (StreamWindowError{Offset: 0, MaxMatchLen: 16, Unfinished: true}) {
+		t.Errorf("wrong error %#v", err)
+	}
+	if got, want := err.Error(), "no match found within 16 bytes of offset 0, so a match beginning there would exceed the maximum match length"; got != want {
+		t.Errorf("wrong error message %q; want %q", got, want)
+	}
+
+	// With SkipAhead, the long match is missed as documented for that
+	// field, but the search must still go on to find the later match,
+	// rather than report a partial one.
+	m, err := re.SearchReader(strings.NewReader(input), NoMatchOpts, StreamWindow{MaxMatchLen: 16, SkipAhead: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil {
+		t.Fatal("no match")
+	}
+	if got, want := m.Bounds(), (Span{len(long) + 1, len(input)}); got != want {
+		t.Errorf("wrong match %#v; want %#v", got, want)
+	}
+}
+
+// streamTestInput returns a long input with a random mix of words, so that
+// the window used to search a stream slides many times. The result is the
+// same on every call.
+func streamTestInput() string {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"foo", "bar", "x", "é", "1234", "ab", "\n", " ", "  ", "foobar"}
+	var sb strings.Builder
+	for sb.Len() < 20000 {
+		sb.WriteString(words[rnd.Intn(len(words))])
+	}
+	return sb.String()
+}
@@ -0,0 +1,287 @@
+package onig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Subject is an input string prepared for matching against many regexes,
+// such as when classifying a document with a set of patterns.
+//
+// Each search passes the text to Oniguruma without copying it, as for the
+// methods of Regex. An index of the lines of the text is built the first
+// time Position or Line is called.
+//
+// The matches returned by a Subject remember it, so that the text of their
+// captures can be retrieved without supplying the input again.
+//
+// A Subject is safe for concurrent use by multiple goroutines.
+type Subject struct {
+	s string
+
+	// lines holds the offset of the start of each line, built on first use.
+	linesOnce sync.Once
+	lines     []int
+}
+
+// NewSubject returns a Subject for the given string.
+func NewSubject(s string) *Subject {
+	return &Subject{s: s}
+}
+
+// NewSubjectBytes returns a Subject for a copy of the given byte slice, so
+// the slice may be modified afterwards.
+func NewSubjectBytes(b []byte) *Subject {
+	return NewSubject(string(b))
+}
+
+// NewValidSubject is like NewSubject except that it returns an error if the
+// given string is not valid UTF-8. Oniguruma does not check its input, and
+// its results for invalid UTF-8 are not well defined.
+func NewValidSubject(s string) (*Subject, error) {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return nil, fmt.Errorf("invalid UTF-8 at offset %d", i)
+		}
+		i += size
+	}
+	return NewSubject(s), nil
+}
+
+// String returns the text of the subject.
+func (s *Subject) String() string {
+	return s.s
+}
+
+// Len returns the length of the subject in bytes.
+func (s *Subject) Len() int {
+	return len(s.s)
+}
+
+// Match tests whether the given regex matches the portion of the subject
+// beginning at the given byte offset, in the same way as Regex.MatchAt.
+// The result is nil if there is no match.
+//
+// Match panics if at is negative or greater than the length of the subject.
+func (s *Subject) Match(re *Regex, at int, opts MatchOptions) *SubjectMatch {
+	checkStart(at, len(s.s))
+	m := NewMatch()
+	if !regexMatch(re, s.s, at, opts, m) {
+		return nil
+	}
+	return &SubjectMatch{Match: m, subject: s, re: re}
+}
+
+// Search returns the first match of the given regex in the subject, in the
+// same way as Regex.Search, or nil if there is no match.
+func (s *Subject) Search(re *Regex, opts MatchOptions) *SubjectMatch {
+	return s.SearchFrom(re, 0, opts)
+}
+
+// SearchFrom is like Search except that it only finds matches that begin at
+// or after the given byte offset, in the same way as Regex.SearchFrom.
+//
+// SearchFrom panics if start is negative or greater than the length of the
+// subject.
+func (s *Subject) SearchFrom(re *Regex, start int, opts MatchOptions) *SubjectMatch {
+	checkStart(start, len(s.s))
+	m := NewMatch()
+	if !regexSearch(re, s.s, start, opts, false, m) {
+		return nil
+	}
+	return &SubjectMatch{Match: m, subject: s, re: re}
+}
+
+// SearchAll returns all of the successive, non-overlapping matches of the
+// given regex in the subject, in the same way as Regex.SearchAll. The
+// result is nil if there are no matches.
+func (s *Subject) SearchAll(re *Regex, opts MatchOptions) []*SubjectMatch {
+	var ret []*SubjectMatch
+	matches := s.Matches(re, opts)
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// Matches returns a SubjectMatches that finds the same matches as SearchAll
+// one at a time, so that a caller can stop early without searching the
+// rest of the subject.
+func (s *Subject) Matches(re *Regex, opts MatchOptions) *SubjectMatches {
+	return &SubjectMatches{subject: s, re: re, opts: opts}
+}
+
+// Position returns the line and column of the given byte offset in the
+// subject. Both start at 1, and the column counts bytes, as for the fields
+// of Token. Lines are separated by "\n".
+//
+// Position panics if offset is negative or greater than the length of the
+// subject.
+func (s *Subject) Position(offset int) (line, column int) {
+	if offset < 0 || offset > len(s.s) {
+		panic(fmt.Sprintf("onig: offset %d out of range [0:%d]", offset, len(s.s)))
+	}
+	lines := s.lineStarts()
+	i := sort.SearchInts(lines, offset+1) - 1
+	return i + 1, offset - lines[i] + 1
+}
+
+// Line returns the span of the line with the given number, starting at 1,
+// not including its terminating "\n". It panics if there is no such line.
+func (s *Subject) Line(n int) Span {
+	lines := s.lineStarts()
+	if n < 1 || n > len(lines) {
+		panic(fmt.Sprintf("onig: line %d out of range [1:%d]", n, len(lines)))
+	}
+	end := len(s.s)
+	if n < len(lines) {
+		end = lines[n] - 1
+	}
+	return Span{lines[n-1], end}
+}
+
+func (s *Subject) lineStarts() []int {
+	s.linesOnce.Do(func() {
+		s.lines = []int{0}
+		for i := 0; i < len(s.s); i++ {
+			if s.s[i] == '\n' {
+				s.lines = append(s.lines, i+1)
+			}
+		}
+	})
+	return s.lines
+}
+
+// SubjectMatches finds the matches of a regex in a Subject one at a time,
+// for Subject.Matches.
+//
+// A SubjectMatches is not safe for concurrent use by multiple goroutines.
+type SubjectMatches struct {
+	subject *Subject
+	re      *Regex
+	opts    MatchOptions
+	pos     int
+}
+
+// Next returns the next match, or nil if there are no more matches.
+func (s *SubjectMatches) Next() *SubjectMatch {
+	if s.pos > s.subject.Len() {
+		return nil
+	}
+	m := s.subject.SearchFrom(s.re, s.pos, s.opts)
+	if m == nil {
+		s.pos = s.subject.Len() + 1
+		return nil
+	}
+	s.pos = scanNext(s.subject.s, m.Bounds())
+	return m
+}
+
+// SubjectMatch is a match found in a Subject, which it remembers along with
+// the regex that matched, so that it can return the text of the match and
+// its captures. Match.Bind makes a SubjectMatch from a match found some
+// other way.
+//
+// The methods that return the text of a capture that did not participate in
+// the match return an empty string. Use Substr to tell such a capture apart
+// from one that matched the empty string.
+type SubjectMatch struct {
+	*Match
+
+	subject *Subject
+	re      *Regex
+}
+
+// Regex returns the regex that matched.
+func (m *SubjectMatch) Regex() *Regex {
+	return m.re
+}
+
+// Subject returns the subject the match was found in.
+func (m *SubjectMatch) Subject() *Subject {
+	return m.subject
+}
+
+// Substr returns the text of the capture with the given index, where zero
+// is the whole match, or false if the capture did not participate in the
+// match. It panics if the index is out of range, as for Capture.
+func (m *SubjectMatch) Substr(index int) (string, bool) {
+	span := m.Capture(index)
+	if span.Start < 0 {
+		return "", false
+	}
+	return span.Substr(m.subject.s), true
+}
+
+// Text returns the text of the whole match.
+func (m *SubjectMatch) Text() string {
+	return m.Group(0)
+}
+
+// Group returns the text of the capture with the given index, where zero is
+// the whole match. It panics if the index is out of range, as for Capture.
+func (m *SubjectMatch) Group(index int) string {
+	s, _ := m.Substr(index)
+	return s
+}
+
+// GroupNamed returns the text of the capture with the given name, or the
+// last one that participated in the match if there is more than one, as for
+// NamedCapture. The result is empty if there is no capture with that name.
+func (m *SubjectMatch) GroupNamed(name string) string {
+	span := NamedCapture(m.re, m.Match, name)
+	if span.Start < 0 {
+		return ""
+	}
+	return span.Substr(m.subject.s)
+}
+
+// Groups returns the text of each capture, in order of their indices,
+// starting with the capture at index 1 rather than the whole match.
+func (m *SubjectMatch) Groups() []string {
+	ret := make([]string, m.CaptureCount())
+	for i := range ret {
+		ret[i] = m.Group(i + 1)
+	}
+	return ret
+}
+
+// NamedGroups returns a map from each capture name in the regex to the text
+// of that capture, as returned by GroupNamed.
+func (m *SubjectMatch) NamedGroups() map[string]string {
+	names := m.re.NamedCaptures()
+	ret := make(map[string]string, len(names))
+	for name := range names {
+		ret[name] = m.GroupNamed(name)
+	}
+	return ret
+}
+
+// Pre returns the text of the subject before the match.
+func (m *SubjectMatch) Pre() string {
+	return m.subject.s[:m.Bounds().Start]
+}
+
+// Post returns the text of the subject after the match.
+func (m *SubjectMatch) Post() string {
+	return m.subject.s[m.Bounds().End:]
+}
+
+// Format implements fmt.Formatter. The verbs %v and %s format the text of
+// the whole match, honoring any width and precision, and %q formats it as
+// a quoted string. %#v formats the spans of the match, as for
+// Match.GoString.
+func (m *SubjectMatch) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprint(f, m.Match.GoString())
+	case verb == 'v' || verb == 's' || verb == 'q':
+		fmt.Fprintf(f, fmt.FormatString(f, verb), m.Text())
+	default:
+		fmt.Fprintf(f, "%%!%c(onig.SubjectMatch=%s)", verb, strconv.Quote(m.Text()))
+	}
+}
@@ -0,0 +1,248 @@
+package onig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSubjectSearch(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Input   string
+	}{
+		{`\d+`, "a1b22c333"},
+		{`x*`, "abxd"},
+		{`(?<=a)b|(c)`, "abcab"},
+		{`^.`, "é\nb\n"},
+		{`\0`, "a\x00b\x00"},
+		{`a`, ""},
+		{`$`, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Pattern+" "+test.Input, func(t *testing.T) {
+			re := MustNewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+			subj := NewSubject(test.Input)
+
+			want := re.SearchAll(test.Input, NoMatchOpts)
+			got := subj.SearchAll(re, NoMatchOpts)
+			if len(got) != len(want) {
+				t.Fatalf("wrong number of matches %d; want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !got[i].Equal(want[i]) {
+					t.Errorf("wrong match %d\ngot:  %#v\nwant: %#v", i, got[i].Match, want[i])
+				}
+				text, ok := got[i].Substr(0)
+				if wantText := want[i].Bounds().Substr(test.Input); !ok || text != wantText {
+					t.Errorf("wrong text for match %d %q; want %q", i, text, wantText)
+				}
+				if got[i].Subject() != subj {
+					t.Errorf("wrong subject for match %d", i)
+				}
+			}
+
+			for at := 0; at <= len(test.Input); at++ {
+				wantM := re.MatchAt(test.Input, at, NoMatchOpts)
+				gotM := subj.Match(re, at, NoMatchOpts)
+				if (gotM == nil) != (wantM == nil) || (gotM != nil && !gotM.Equal(wantM)) {
+					t.Errorf("wrong match at %d\ngot:  %#v\nwant: %#v", at, gotM, wantM)
+				}
+			}
+
+			wantS := re.Search(test.Input, NoMatchOpts)
+			gotS := subj.Search(re, NoMatchOpts)
+			if (gotS == nil) != (wantS == nil) || (gotS != nil && !gotS.Equal(wantS)) {
+				t.Errorf("wrong search result\ngot:  %#v\nwant: %#v", gotS, wantS)
+			}
+		})
+	}
+}
+
+func TestSubjectMatchSubstr(t *testing.T) {
+	re := MustNewRegex(`(a)|(b)`, NoCompileOpts, SyntaxRuby)
+	m := NewSubjectBytes([]byte("xb")).Search(re, NoMatchOpts)
+	if got, ok := m.Substr(2); !ok || got != "b" {
+		t.Errorf("wrong capture 2 %q, %v", got, ok)
+	}
+	if got, ok := m.Substr(1); ok || got != "" {
+		t.Errorf("wrong capture 1 %q, %v; want unset", got, ok)
+	}
+}
+
+func TestSubjectMatches(t *testing.T) {
+	re := MustNewRegex(`\w+`, NoCompileOpts, SyntaxRuby)
+	matches := NewSubject("one two three").Matches(re, NoMatchOpts)
+	var got []string
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		text, _ := m.Substr(0)
+		got = append(got, text)
+	}
+	if want := "one,two,three"; strings.Join(got, ",") != want {
+		t.Errorf("wrong matches %q; want %s", got, want)
+	}
+	if m := matches.Next(); m != nil {
+		t.Errorf("Next after end returned %#v", m.Match)
+	}
+}
+
+func TestNewValidSubject(t *testing.T) {
+	if _, err := NewValidSubject("héllo"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	_, err := NewValidSubject("ab\xffc")
+	if err == nil || err.Error() != "invalid UTF-8 at offset 2" {
+		t.Errorf("wrong error %v", err)
+	}
+}
+
+func TestSubjectPosition(t *testing.T) {
+	subj := NewSubject("ab\n\nécd\n")
+	tests := []struct {
+		Offset       int
+		Line, Column int
+	}{
+		{0, 1, 1},
+		{2, 1, 3},
+		{3, 2, 1},
+		{4, 3, 1},
+		{6, 3, 3},
+		{9, 4, 1},
+	}
+	for _, test := range tests {
+		line, col := subj.Position(test.Offset)
+		if line != test.Line || col != test.Column {
+			t.Errorf("wrong position for %d %d:%d; want %d:%d", test.Offset, line, col, test.Line, test.Column)
+		}
+	}
+
+	lines := []string{"ab", "", "écd", ""}
+	for i, want := range lines {
+		if got := subj.Line(i + 1).Substr(subj.String()); got != want {
+			t.Errorf("wrong line %d %q; want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestSubjectConcurrent(t *testing.T) {
+	subj := NewSubject(strings.Repeat("abc 123\n", 100))
+	patterns := []string{`\d+`, `[a-z]+`, `\s`, `c\s1`}
+	var wg sync.WaitGroup
+	for _, pattern := range patterns {
+		re := MustNewRegex(pattern, NoCompileOpts, SyntaxRuby)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			want := re.SearchAll(subj.String(), NoMatchOpts)
+			got := subj.SearchAll(re, NoMatchOpts)
+			if len(got) != len(want) {
+				t.Errorf("wrong number of matches for %s %d; want %d", re, len(got), len(want))
+			}
+			subj.Position(subj.Len())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSubjectMatchGroups(t *testing.T) {
+	tests := []struct {
+		Pattern     string
+		Input       string
+		Text        string
+		Groups      []string
+		NamedGroups map[string]string
+		Pre, Post   string
+	}{
+		{
+			`(?<year>\d{4})-(?<month>\d\d)(?:-(?<day>\d\d))?`, "on 2024-06 at",
+			"2024-06", []string{"2024", "06", ""},
+			map[string]string{"year": "2024", "month": "06", "day": ""},
+			"on ", " at",
+		},
+		{
+			`(?<x>a)|(?<x>b)`, "cba",
+			"b", []string{"", "b"},
+			map[string]string{"x": "b"},
+			"c", "a",
+		},
+		{
+			`é(.)?`, "é",
+			"é", []string{""},
+			map[string]string{},
+			"", "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Pattern, func(t *testing.T) {
+			re := MustNewRegex(test.Pattern, NoCompileOpts, SyntaxRuby)
+			for name, m := range map[string]*SubjectMatch{
+				"subject": NewSubject(test.Input).Search(re, NoMatchOpts),
+				"bind":    re.Search(test.Input, NoMatchOpts).Bind(re, test.Input),
+			} {
+				if got := m.Text(); got != test.Text {
+					t.Errorf("%s: wrong text %q; want %q", name, got, test.Text)
+				}
+				if got := m.Groups(); !reflect.DeepEqual(got, test.Groups) {
+					t.Errorf("%s: wrong groups %q; want %q", name, got, test.Groups)
+				}
+				if got := m.NamedGroups(); !reflect.DeepEqual(got, test.NamedGroups) {
+					t.Errorf("%s: wrong named groups %q; want %q", name, got, test.NamedGroups)
+				}
+				for groupName, want := range test.NamedGroups {
+					if got := m.GroupNamed(groupName); got != want {
+						t.Errorf("%s: wrong group %s %q; want %q", name, groupName, got, want)
+					}
+				}
+				if got := m.GroupNamed("nonexistent"); got != "" {
+					t.Errorf("%s: wrong nonexistent group %q", name, got)
+				}
+				if got := m.Pre(); got != test.Pre {
+					t.Errorf("%s: wrong pre %q; want %q", name, got, test.Pre)
+				}
+				if got := m.Post(); got != test.Post {
+					t.Errorf("%s: wrong post %q; want %q", name, got, test.Post)
+				}
+				if m.Regex() != re {
+					t.Errorf("%s: wrong regex", name)
+				}
+			}
+		})
+	}
+}
+
+func TestSubjectMatchBindSearch(t *testing.T) {
+	re := MustNewRegex(`\d`, NoCompileOpts, SyntaxRuby)
+	s := "a1b2"
+	m := re.Search(s, NoMatchOpts).Bind(re, s)
+	next := m.Subject().SearchFrom(re, m.Bounds().End, NoMatchOpts)
+	if next == nil || next.Text() != "2" {
+		t.Errorf("wrong next match %v", next)
+	}
+}
+
+func TestSubjectMatchFormat(t *testing.T) {
+	re := MustNewRegex(`b(c)`, NoCompileOpts, SyntaxRuby)
+	m := NewSubject("abcd").Search(re, NoMatchOpts)
+	tests := []struct {
+		Format string
+		Want   string
+	}{
+		{"%v", "bc"},
+		{"%s", "bc"},
+		{"[%4s]", "[  bc]"},
+		{"[%-4v]", "[bc  ]"},
+		{"%.1s", "b"},
+		{"%q", `"bc"`},
+		{"%#v", `&onig.Match{Bounds:&onig.Span{1, 3},Captures:[]onig.Span{&onig.Span{2, 3}}}`},
+		{"%d", `%!d(onig.SubjectMatch="bc")`},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprintf(test.Format, m); got != test.Want {
+			t.Errorf("wrong result for %s\ngot:  %s\nwant: %s", test.Format, got, test.Want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package onig
 
+import "fmt"
+
 // Syntax is an enumeration of regex syntaxes that can be passed to NewRegex.
+// Custom syntaxes can be created using NewSyntax.
 type Syntax uintptr
 
 var (
@@ -16,3 +19,167 @@ var (
 	SyntaxPerlNG        Syntax
 	SyntaxRuby          Syntax
 )
+
+// String returns the name of the syntax, such as "Ruby" for SyntaxRuby.
+func (s Syntax) String() string {
+	if name, ok := syntaxName(s); ok {
+		return name
+	}
+	return fmt.Sprintf("Syntax(%#x)", uintptr(s))
+}
+
+// GoString returns a Go-syntax representation of the syntax, naming the
+// package variable that holds it.
+func (s Syntax) GoString() string {
+	if name, ok := syntaxName(s); ok {
+		return "onig.Syntax" + name
+	}
+	return fmt.Sprintf("onig.Syntax(%#x)", uintptr(s))
+}
+
+// syntaxName returns the suffix of the name of the package variable that
+// holds the given syntax, if any.
+func syntaxName(s Syntax) (string, bool) {
+	switch s {
+	case 0:
+		// SyntaxOniguruma is not available in the version of Oniguruma
+		// these bindings are written for, so it remains zero.
+		return "", false
+	case SyntaxAsIs:
+		return "AsIs", true
+	case SyntaxPosixBasic:
+		return "PosixBasic", true
+	case SyntaxPosixExtended:
+		return "PosixExtended", true
+	case SyntaxEmacs:
+		return "Emacs", true
+	case SyntaxGrep:
+		return "Grep", true
+	case SyntaxGNU:
+		return "GNU", true
+	case SyntaxJava:
+		return "Java", true
+	case SyntaxPerl:
+		return "Perl", true
+	case SyntaxPerlNG:
+		return "PerlNG", true
+	case SyntaxRuby:
+		return "Ruby", true
+	default:
+		return "", false
+	}
+}
+
+// syntaxByName is the inverse of syntaxName.
+func syntaxByName(name string) (Syntax, bool) {
+	for _, s := range []Syntax{
+		SyntaxAsIs,
+		SyntaxPosixBasic,
+		SyntaxPosixExtended,
+		SyntaxEmacs,
+		SyntaxGrep,
+		SyntaxGNU,
+		SyntaxJava,
+		SyntaxPerl,
+		SyntaxPerlNG,
+		SyntaxRuby,
+	} {
+		if n, _ := syntaxName(s); n == name {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// SyntaxFeatures is a bitmask type used to select constructs to enable or
+// disable in a syntax created by NewSyntax. Each is named after the
+// construct it controls.
+type SyntaxFeatures uint
+
+const (
+	NoSyntaxFeatures SyntaxFeatures = 0
+
+	// SynBufAnchors enables the anchors "\A", "\Z" and "\z".
+	SynBufAnchors SyntaxFeatures = 1 << iota
+
+	// SynSearchStartAnchor enables the anchor "\G".
+	SynSearchStartAnchor
+
+	// SynWordBeginEnd enables the anchors "\<" and "\>".
+	SynWordBeginEnd
+
+	// SynPosixBracket enables POSIX classes such as "[:alpha:]" inside
+	// a character class.
+	SynPosixBracket
+
+	// SynHexBrace enables code point escapes such as "\x{263a}".
+	SynHexBrace
+
+	// SynQuote enables quoting of literal text with "\Q...\E".
+	SynQuote
+
+	// SynPerlOptions enables the inline options "s" and "m" with their
+	// meanings in Perl, as in "(?s)" and "(?s-m:...)". The options "i"
+	// and "x" are always available.
+	SynPerlOptions
+
+	// SynRubyOptions enables the inline option "m" with its meaning in
+	// Ruby, as in "(?m)" and "(?m-x:...)".
+	SynRubyOptions
+
+	// SynPossessive enables the possessive quantifiers "?+", "*+" and
+	// "++".
+	SynPossessive
+
+	// SynPossessiveInterval enables possessive interval quantifiers such as
+	// "{1,2}+".
+	SynPossessiveInterval
+
+	// SynClassSetOps enables set operations such as "&&" inside a character
+	// class.
+	SynClassSetOps
+
+	// SynNamedGroups enables named groups "(?<name>...)".
+	SynNamedGroups
+
+	// SynNamedBackrefs enables named backreferences "\k<name>".
+	SynNamedBackrefs
+
+	// SynSubexpCalls enables subexpression calls such as "\g<name>".
+	SynSubexpCalls
+
+	// SynUnicodeEscape enables code point escapes such as "\u263a".
+	SynUnicodeEscape
+
+	// SynVerticalTab enables the escape "\v" for a vertical tab.
+	SynVerticalTab
+
+	// SynHexDigitClass enables the classes "\h" and "\H" for hexadecimal
+	// digits.
+	SynHexDigitClass
+
+	// SynCharProperties enables character properties such as "\p{Alpha}".
+	SynCharProperties
+
+	// SynIntervalLowAbbrev allows the minimum of an interval to be omitted,
+	// as in "{,2}".
+	SynIntervalLowAbbrev
+
+	// SynCaptureOnlyNamed makes unnamed groups non-capturing when named
+	// groups are present, unless OptCaptureGroup is used.
+	SynCaptureOnlyNamed
+
+	// SynDuplicateNames allows more than one group to have the same name.
+	SynDuplicateNames
+)
+
+// NewSyntax returns a new syntax that is a copy of base with the given
+// features enabled and disabled, and with the given default options, which
+// NewRegex combines with the options it is given.
+//
+// The new syntax is never freed, so NewSyntax is intended to be called
+// only a fixed number of times, such as to initialize a package-level
+// variable.
+func NewSyntax(base Syntax, options CompileOptions, enable, disable SyntaxFeatures) Syntax {
+	return syntaxNew(base, options, enable, disable)
+}